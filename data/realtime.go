@@ -1,11 +1,14 @@
 package data
 
 import (
+	"fmt"
 	"log"
 
+	"github.com/Semantics3/go-crawler/fixture"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 	rdutils "github.com/Semantics3/sem3-go-crawl-utils/rdstore"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 )
 
 // RealtimeActions will decide if product is old or new by rdstore lookup
@@ -45,7 +48,11 @@ func RealtimeActions(url string, workflow *types.CrawlWorkflow, appC *types.Conf
 	// If a parent sku is present in rdstore
 	// we can safely assume the product (and variations) is present in Skus and ES aswell
 	if workflow.RdstoreData == nil {
-		rdstoreData, err := rdutils.FetchParentSKU(url, site, di.ParentSKU, appC.ConfigData.RestRdstoreUpdate)
+		var rdstoreData *ctypes.RdstoreParentSKU
+		err := fixture.Replay(fixture.KindRdstore, fmt.Sprintf("GET %s %s", site, di.ParentSKU), &rdstoreData, func() (err error) {
+			rdstoreData, err = rdutils.FetchParentSKU(url, site, di.ParentSKU, appC.ConfigData.RestRdstoreUpdate)
+			return err
+		})
 		if err != nil {
 			log.Printf("RDSTORE_READ_FAILED: Performing rdstore read for SITE: %s, PARENT_SKU: %s, URL: %s failed with error: %v\n", site, di.ParentSKU, url, err)
 			return "", err
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-pg/pg"
 
+	"github.com/Semantics3/go-crawler/fixture"
 	"github.com/Semantics3/go-crawler/types"
 	redisutils "github.com/Semantics3/sem3-go-crawl-utils/redis"
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
@@ -209,7 +210,11 @@ func TranslateRPC(key, src, targetlang string, appC *types.Config) (string, erro
 
 	method := "translate"
 
-	r, err := appC.TranslateRPCClient.Call(method, key, targetlang)
+	var r interface{}
+	err := fixture.Replay(fixture.KindRPC, fmt.Sprintf("%s %s %s", method, targetlang, key), &r, func() (err error) {
+		r, err = appC.TranslateRPCClient.Call(method, key, targetlang)
+		return err
+	})
 	if err != nil {
 		log.Printf("TRANSLATE_KEY_RPC_ERROR: Method: %s, Target Language: %s, Key: %s, Error: %#v\n", method, targetlang, key, err)
 		return "", err
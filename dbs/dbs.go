@@ -14,7 +14,11 @@ import (
 	mongo "go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/Semantics3/go-crawler/fixture"
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/sources/apisource"
 	"github.com/Semantics3/go-crawler/stats"
+	"github.com/Semantics3/go-crawler/storage"
 	"github.com/Semantics3/go-crawler/types"
 
 	// jobutils "github.com/Semantics3/sem3-go-crawl-utils/jobs"
@@ -44,6 +48,9 @@ func ParseCliArgs() (cliArgs *types.CliArgs) {
 	consume := flag.Bool("consume", false, "Whether to run in rabbitmq consumer mode")
 	workerIDPtr := flag.String("worker_id", "", "Worker ID (required by jobserver)")
 	jobServerPtr := flag.String("jobserver", "", "Worker ID (required by jobserver)")
+	fixtureMode := flag.String("fixture-mode", "", "Record external interactions to fixtures or play them back (record|playback)")
+	fixtureDir := flag.String("fixture-dir", "tests/fixtures", "Directory to record fixtures to or play them back from")
+	warcInput := flag.String("warc", "", "Comma separated WARC files to re-extract pages from (all archived urls unless -test/-test-file)")
 
 	flag.Parse()
 	cliArgs = &types.CliArgs{
@@ -59,6 +66,9 @@ func ParseCliArgs() (cliArgs *types.CliArgs) {
 		Filename:       *filename,
 		WorkerID:       *workerIDPtr,
 		JobServerURL:   *jobServerPtr,
+		FixtureMode:    *fixtureMode,
+		FixtureDir:     *fixtureDir,
+		WarcInput:      *warcInput,
 	}
 	return cliArgs
 }
@@ -99,15 +109,25 @@ func LoadConfig(cliArgs *types.CliArgs) (appC *types.Config, err error) {
 
 	// cutils.PrintJson(configData)
 
-	// 3. Create a s3 client to read cache
-	s3ClientOptions := &s3Cache.ClientOpts{
-		BucketName:        "sem3-html-cache-us-east",
-		GetCredsFromVault: true,
-		VaultKey:          "engineering/s3/crawl-user",
-	}
-	s3Client, err := s3Cache.MakeS3Client(s3ClientOptions)
+	// 2.1 Record or play back external interactions from fixtures
+	err = fixture.Init(cliArgs.FixtureMode, cliArgs.FixtureDir)
 	if err != nil {
-		return appC, fmt.Errorf("Creating s3 client failed with error %s", err)
+		return appC, err
+	}
+
+	// 3. Create a s3 client to read cache
+	// Fixture playback runs offline, so vault credentials are not looked up
+	var s3Client *s3Cache.Client
+	if !fixture.IsPlayback() {
+		s3ClientOptions := &s3Cache.ClientOpts{
+			BucketName:        "sem3-html-cache-us-east",
+			GetCredsFromVault: true,
+			VaultKey:          "engineering/s3/crawl-user",
+		}
+		s3Client, err = s3Cache.MakeS3Client(s3ClientOptions)
+		if err != nil {
+			return appC, fmt.Errorf("Creating s3 client failed with error %s", err)
+		}
 	}
 
 	// 4. Create and rpc client for extraction service
@@ -226,8 +246,30 @@ func LoadConfig(cliArgs *types.CliArgs) (appC *types.Config, err error) {
 		appC.UnsupervisedRPCClient = unsupervisedRPCClient
 	}
 
+	// Storage for screenshots captured through the screenshot service
+	// Fixture playback runs offline, so s3 storage is not created
+	if configData.ScreenshotStorage != nil && !(fixture.IsPlayback() && configData.ScreenshotStorage.Backend == storage.BackendS3) {
+		appC.ScreenshotStore, err = storage.NewStore(configData.ScreenshotStorage)
+		if err != nil {
+			return appC, cutils.PrintErr("DBS_STORAGEERR", "failed to create screenshot storage", err)
+		}
+	}
+
+	// Storage for WARC exports of crawled responses
+	if configData.Warc != nil && configData.Warc.Storage != nil && !(fixture.IsPlayback() && configData.Warc.Storage.Backend == storage.BackendS3) {
+		appC.WarcStore, err = storage.NewStore(configData.Warc.Storage)
+		if err != nil {
+			return appC, cutils.PrintErr("DBS_STORAGEERR", "failed to create warc storage", err)
+		}
+	}
+
+	// Data sources backed by JSON product APIs, reloaded on live updates
+	apisource.Load(appC)
+
 	// Listen for wrapper/sitedetails live updates on redis pubsub
-	go listenWrapperPubSubChannels(os.Getenv("REDIS_HOST_ADDR"))
+	if !fixture.IsPlayback() {
+		go listenWrapperPubSubChannels(os.Getenv("REDIS_HOST_ADDR"))
+	}
 
 	// Connect to mongo-crawl only for discovery_crawl and wraperqa types
 	mongoURI := os.Getenv("MONGO_URI")
@@ -271,6 +313,17 @@ func LoadConfig(cliArgs *types.CliArgs) (appC *types.Config, err error) {
 	appC.ConfigData.Influx.Server = os.Getenv("INFLUXDB_ADDR")
 	appC.ConfigData.WrapperServiceURI = os.Getenv("WRAPPER_SERVICE_URI")
 
+	// Page cache of the environment (cache service unless configured otherwise)
+	appC.PageCache, err = pagecache.New(configData.PageCache, pagecache.Deps{
+		CacheServiceHost: appC.ConfigData.CacheService,
+		S3Client:         s3Client,
+		Redis:            appC.RedisCrawl,
+	})
+	if err != nil {
+		return appC, cutils.PrintErr("DBS_PAGECACHEERR", "failed to create page cache", err)
+	}
+	log.Printf("CONFIG_LOAD: Page cache backend %s\n", appC.PageCache.Name())
+
 	go stats.CollectStats(env, &statsManager, appC)
 	return appC, nil
 }
@@ -5,11 +5,13 @@ import (
 	"log"
 	"time"
 
+	"github.com/Semantics3/go-crawler/merge"
+	"github.com/Semantics3/go-crawler/sources/apisource"
 	"github.com/Semantics3/sem3-go-crawl-utils/sitedetails"
 	"github.com/gomodule/redigo/redis"
 )
 
-// LiveUpdateRequest will define type for wrapper/sitedetails realtime updates using redis pubsub
+// LiveUpdateRequest will define type for wrapper/sitedetails/merge config/api source realtime updates using redis pubsub
 type LiveUpdateRequest struct {
 	Site string `json:"site"`
 	ID   string `json:"id"`
@@ -31,6 +33,18 @@ func onReceivePubSubMessage(channel string, data []byte) error {
 		log.Printf("REDIS_PUBSUB: Unmarshalling message: %s failed with error: %v\n", string(data), err)
 		return nil
 	}
+	// Merge configs are dropped by domain, or all of them when the message doesn't have a site
+	if channel == merge.MergeConfigChannel {
+		log.Println("REDIS_PUBSUB: Message received: ", string(data))
+		merge.RemoveMergeConfigFromCache(liveRequest.Site)
+		return nil
+	}
+	// API sources are all loaded again
+	if channel == apisource.APISourceChannel {
+		log.Println("REDIS_PUBSUB: Message received: ", string(data))
+		apisource.Reload()
+		return nil
+	}
 	if liveRequest.ID == "" || liveRequest.Site == "" {
 		log.Printf("REDIS_PUBSUB: Skipping, Missing id or site in message: %s\n", string(data))
 		return nil
@@ -47,7 +61,7 @@ func onReceivePubSubMessage(channel string, data []byte) error {
 }
 
 func listenWrapperPubSubChannels(redisServerAddr string) {
-	channels := []string{"sitedetail_live_updates", "wrapper_live_updates"}
+	channels := []string{"sitedetail_live_updates", "wrapper_live_updates", merge.MergeConfigChannel, apisource.APISourceChannel}
 
 	// Infinite loop
 	// Logic mostly from https://godoc.org/github.com/gomodule/redigo/redis#PubSubConn
@@ -0,0 +1,171 @@
+package fixture
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Supported fixture modes
+// ModeRecord   - every external interaction of a live run is captured to the fixture directory
+// ModePlayback - external interactions are served from the fixture directory, nothing hits the network
+const (
+	ModeOff      = ""
+	ModeRecord   = "record"
+	ModePlayback = "playback"
+)
+
+// Kinds of external interactions captured as fixtures
+// Each kind is stored in its own sub directory of the fixture directory
+const (
+	KindHTTP    = "http"
+	KindRPC     = "rpc"
+	KindService = "service"
+	KindCache   = "cache"
+	KindRdstore = "rdstore"
+)
+
+// Store reads and writes fixtures from a directory
+type Store struct {
+	Mode string
+	Dir  string
+	mu   sync.Mutex
+}
+
+// entry is the on-disk representation of a single recorded interaction
+type entry struct {
+	Kind   string          `json:"kind"`
+	Key    string          `json:"key"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error,omitempty"`
+}
+
+var active *Store
+
+// Init activates the fixture store for the process in the given mode
+// In record and playback modes, the default http transport is swapped so that
+// every http.Client without a custom transport goes through the store
+func Init(mode, dir string) error {
+	if mode == ModeOff {
+		active = nil
+		return nil
+	}
+	if mode != ModeRecord && mode != ModePlayback {
+		return fmt.Errorf("FIXTURE_INVALID_MODE: unknown fixture mode %s", mode)
+	}
+	if dir == "" {
+		return fmt.Errorf("FIXTURE_INVALID_DIR: fixture directory is mandatory for %s mode", mode)
+	}
+	if mode == ModeRecord {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("FIXTURE_INVALID_DIR: creating %s failed with error %v", dir, err)
+		}
+	} else if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("FIXTURE_INVALID_DIR: reading %s failed with error %v", dir, err)
+	}
+
+	active = &Store{Mode: mode, Dir: dir}
+	if _, ok := http.DefaultTransport.(*Transport); !ok {
+		http.DefaultTransport = &Transport{Base: http.DefaultTransport}
+	}
+	log.Printf("FIXTURE_INIT: Running in %s mode with fixtures at %s\n", mode, dir)
+	return nil
+}
+
+// Mode returns the mode of the active fixture store
+func Mode() string {
+	if active == nil {
+		return ModeOff
+	}
+	return active.Mode
+}
+
+// IsPlayback tells if external interactions are being served from fixtures
+func IsPlayback() bool {
+	return Mode() == ModePlayback
+}
+
+// Replay routes a call to an external dependency through the active fixture store
+// 1. Without an active store, call is made as is
+// 2. In record mode, call is made and its result (out) and error are saved against (kind, key)
+// 3. In playback mode, call is never made and out is filled from the saved fixture
+// out has to be a pointer and call has to populate it
+func Replay(kind, key string, out interface{}, call func() error) error {
+	if active == nil {
+		return call()
+	}
+	return active.replay(kind, key, out, call)
+}
+
+func (s *Store) replay(kind, key string, out interface{}, call func() error) error {
+	path := s.path(kind, key)
+
+	if s.Mode == ModePlayback {
+		e, err := s.read(path)
+		if err != nil {
+			return fmt.Errorf("FIXTURE_MISS: (%s) no fixture found for %s: %v", kind, key, err)
+		}
+		if len(e.Result) > 0 && string(e.Result) != "null" {
+			if err = json.Unmarshal(e.Result, out); err != nil {
+				return fmt.Errorf("FIXTURE_DECODE_ERR: (%s) decoding fixture %s failed: %v", kind, path, err)
+			}
+		}
+		if e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return nil
+	}
+
+	callErr := call()
+	e := entry{Kind: kind, Key: key}
+	if callErr != nil {
+		e.Error = callErr.Error()
+	}
+	result, err := json.Marshal(out)
+	if err != nil {
+		log.Printf("FIXTURE_ENCODE_ERR: (%s) encoding result for %s failed: %v\n", kind, key, err)
+		return callErr
+	}
+	e.Result = result
+	if err = s.write(path, &e); err != nil {
+		log.Printf("FIXTURE_WRITE_ERR: (%s) writing fixture %s failed: %v\n", kind, path, err)
+	}
+	return callErr
+}
+
+// Fixtures are named after the sha1 of the key, the key itself is stored inside for readability
+func (s *Store) path(kind, key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(s.Dir, kind, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *Store) read(path string) (*entry, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{}
+	err = json.Unmarshal(b, e)
+	return e, err
+}
+
+func (s *Store) write(path string, e *entry) error {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
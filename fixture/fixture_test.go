@@ -0,0 +1,141 @@
+package fixture
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FixtureSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupTest - Called before each test
+func (suite *FixtureSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "fixtures")
+	suite.Nil(err)
+	suite.dir = dir
+}
+
+// TearDownTest - Called after each test
+func (suite *FixtureSuite) TearDownTest() {
+	Init(ModeOff, "")
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *FixtureSuite) Test_01_ReplayWithoutStore() {
+	calls := 0
+	var out string
+	err := Replay(KindRPC, "key", &out, func() error {
+		calls++
+		out = "live"
+		return nil
+	})
+	suite.Nil(err)
+	suite.Equal(1, calls)
+	suite.Equal("live", out)
+}
+
+func (suite *FixtureSuite) Test_02_ReplayRecordAndPlayback() {
+	suite.Nil(Init(ModeRecord, suite.dir))
+	var out map[string]interface{}
+	err := Replay(KindRPC, "WRAPPER extractWithWrapper https://example.com/p/1", &out, func() error {
+		out = map[string]interface{}{"status": float64(1), "products": []interface{}{"p1"}}
+		return nil
+	})
+	suite.Nil(err)
+	err = Replay(KindRdstore, "GET example.com sku1", &out, func() error {
+		return errors.New("rdstore unreachable")
+	})
+	suite.EqualError(err, "rdstore unreachable")
+
+	suite.Nil(Init(ModePlayback, suite.dir))
+	var played map[string]interface{}
+	err = Replay(KindRPC, "WRAPPER extractWithWrapper https://example.com/p/1", &played, func() error {
+		suite.Fail("call must not be made in playback mode")
+		return nil
+	})
+	suite.Nil(err)
+	suite.Equal(float64(1), played["status"])
+	suite.Equal([]interface{}{"p1"}, played["products"])
+
+	err = Replay(KindRdstore, "GET example.com sku1", &played, func() error { return nil })
+	suite.EqualError(err, "rdstore unreachable")
+
+	err = Replay(KindRPC, "WRAPPER extractWithWrapper https://example.com/p/2", &played, func() error { return nil })
+	suite.NotNil(err)
+	suite.Contains(err.Error(), "FIXTURE_MISS")
+}
+
+func (suite *FixtureSuite) Test_03_TransportRecordAndPlayback() {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Node-Pool", "internal")
+		fmt.Fprintf(w, "echo:%s", body)
+	}))
+	defer server.Close()
+
+	fetch := func(body string) (*http.Response, string, error) {
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Post(server.URL+"/crawl/url", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(b), nil
+	}
+
+	suite.Nil(Init(ModeRecord, suite.dir))
+	resp, content, err := fetch(`{"url":"https://example.com/p/1","sleep":3,"request_policy":"render:1;cache_key:CE/recrawl/a;"}`)
+	suite.Nil(err)
+	suite.Equal(200, resp.StatusCode)
+	suite.Equal(1, hits)
+
+	// Volatile fields differ between the recorded and played back requests
+	suite.Nil(Init(ModePlayback, suite.dir))
+	resp, played, err := fetch(`{"url":"https://example.com/p/1","sleep":7,"request_policy":"render:1;cache_key:CE/recrawl/b;"}`)
+	suite.Nil(err)
+	suite.Equal(1, hits)
+	suite.Equal(200, resp.StatusCode)
+	suite.Equal("internal", resp.Header.Get("X-Node-Pool"))
+	suite.Equal(content, played)
+
+	_, _, err = fetch(`{"url":"https://example.com/p/2"}`)
+	suite.NotNil(err)
+	suite.Equal(1, hits)
+}
+
+func (suite *FixtureSuite) Test_04_InvalidMode() {
+	suite.NotNil(Init("replay", suite.dir))
+	suite.NotNil(Init(ModePlayback, ""))
+	suite.Equal(ModeOff, Mode())
+}
+
+func (suite *FixtureSuite) Test_05_ArgsKey() {
+	recorded := map[string]interface{}{"url": "https://example.com/p/1", "request_id": "r1",
+		"webResponse": map[string]interface{}{"content": "<html>1</html>", "time": 1, "timeTaken": 0.2}}
+	played := map[string]interface{}{"url": "https://example.com/p/1", "request_id": "r2",
+		"webResponse": map[string]interface{}{"content": "<html>1</html>", "time": 2, "timeTaken": 0.7}}
+	suite.Equal(ArgsKey(recorded), ArgsKey(played))
+
+	// Calls for the same url with other arguments have their own fixture
+	played["webResponse"].(map[string]interface{})["content"] = "<html>2</html>"
+	suite.NotEqual(ArgsKey(recorded), ArgsKey(played))
+	suite.NotEqual(ArgsKey(recorded), ArgsKey(recorded, "en"))
+}
+
+func TestFixtureTestSuite(t *testing.T) {
+	suite.Run(t, new(FixtureSuite))
+}
@@ -0,0 +1,139 @@
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+)
+
+// VolatileFields are dropped from JSON request bodies before keying a fixture
+// These change between two runs of the same crawl (random sleeps, request ids, timestamps)
+var VolatileFields = []string{"crumb", "sleep", "request_id", "time"}
+
+// VolatileArgFields are dropped, at any depth, from the arguments of rpc calls before keying a fixture
+// Extraction calls are sent the crawl workflow, which carries the timings, ids and cache keys of the run
+var VolatileArgFields = []string{"request_id", "time", "timeTaken", "crawl_time", "cache_key", "unsupervised_cache_key",
+	"product_metrics", "extraction_metrics", "session", "warc", "warc_record_id", "warc_file"}
+
+// Request policy components which embed request ids or timestamps
+var volatilePolicyRegex = regexp.MustCompile(`(?i)(cache_key|cache_expiry|cache_event|screenshot):[^;]*;?`)
+
+// Transport records or plays back http round trips through the active fixture store
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// recordedResponse is the subset of an http response saved as a fixture
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if active == nil {
+		return base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	var rec recordedResponse
+	err := Replay(KindHTTP, RequestKey(req.Method, req.URL.String(), body), &rec, func() error {
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		rec = recordedResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(b)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
+
+// RequestKey constructs the fixture key of an http request
+// JSON bodies are stripped of volatile fields so that recorded runs can be replayed
+func RequestKey(method, url string, body []byte) string {
+	return fmt.Sprintf("%s %s %s", method, url, normalizeBody(body))
+}
+
+func normalizeBody(body []byte) string {
+	payload := make(map[string]interface{})
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return string(body)
+	}
+	for _, f := range VolatileFields {
+		delete(payload, f)
+	}
+	if policy, ok := payload["request_policy"].(string); ok {
+		payload["request_policy"] = volatilePolicyRegex.ReplaceAllString(policy, "")
+	}
+	b, _ := json.Marshal(payload)
+	return string(b)
+}
+
+// ArgsKey constructs the fixture key of the arguments of an rpc call, the sha1 of their JSON stripped of volatile fields
+func ArgsKey(args ...interface{}) string {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	var payload interface{}
+	json.Unmarshal(b, &payload)
+	b, _ = json.Marshal(dropVolatileArgs(payload))
+	sum := sha1.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func dropVolatileArgs(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, f := range VolatileArgFields {
+			delete(v, f)
+		}
+		for key, field := range v {
+			v[key] = dropVolatileArgs(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = dropVolatileArgs(item)
+		}
+	}
+	return value
+}
@@ -24,6 +24,7 @@ require (
 	github.com/valyala/fasttemplate v1.1.0 // indirect
 	github.com/xeipuuv/gojsonschema v1.2.0 // indirect
 	go.mongodb.org/mongo-driver v1.4.6
+	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
 	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22
 	mellium.im/sasl v0.2.1 // indirect
 )
@@ -14,6 +14,7 @@ import (
 	_ "net/http/pprof"
 
 	"github.com/Semantics3/go-crawler/dbs"
+	"github.com/Semantics3/go-crawler/request"
 	"github.com/Semantics3/go-crawler/service"
 	servicehelper "github.com/Semantics3/go-crawler/service/helper"
 	"github.com/Semantics3/go-crawler/types"
@@ -82,14 +83,18 @@ func main() {
 		}()
 	}
 
-	// If test or test-file mode requested
-	if cliArgs.IsTestMode || cliArgs.IsTestFileMode {
-		urls, err := GetTestUrls(cliArgs)
+	// If test, test-file or warc mode requested
+	if cliArgs.IsTestMode || cliArgs.IsTestFileMode || cliArgs.WarcInput != "" {
+		urls, err := GetTestUrls(cliArgs, appC)
 		if err != nil {
 			log.Printf("CRAWLCLI_QUIT: Quitting on err\n")
 			os.Exit(1)
 		}
-		jobInput := servicehelper.JobBatchFromUrls(urls, cliArgs.JobType, "", nil)
+		var jobParams map[string]interface{}
+		if cliArgs.WarcInput != "" {
+			jobParams = map[string]interface{}{request.WarcInputJobParam: cliArgs.WarcInput}
+		}
+		jobInput := servicehelper.JobBatchFromUrls(urls, cliArgs.JobType, "", jobParams)
 		_, crawlResults, err := servicehelper.CrawlJobBatchExecute(jobInput, appC, "")
 		for u, w := range crawlResults {
 			if w.DomainInfo != nil {
@@ -102,7 +107,7 @@ func main() {
 		utils.PrintResults(crawlResults)
 	}
 
-	if !cliArgs.IsTestMode && !cliArgs.IsTestFileMode {
+	if !cliArgs.IsTestMode && !cliArgs.IsTestFileMode && cliArgs.WarcInput == "" {
 		sigInt := make(chan os.Signal, 1)
 		signal.Notify(sigInt, os.Interrupt)
 		for range sigInt {
@@ -115,7 +120,8 @@ func main() {
 }
 
 // Get test urls from cli arg or filename mentioned in cliarg
-func GetTestUrls(cliArgs *types.CliArgs) (urls []string, err error) {
+// Without them, all the urls archived in the warc files are re-extracted
+func GetTestUrls(cliArgs *types.CliArgs, appC *types.Config) (urls []string, err error) {
 	if cliArgs.IsTestMode {
 		if cliArgs.Url == "" {
 			err = cutils.PrintErr("CLITESTURL_ERR", fmt.Sprintf("no url sent"), err)
@@ -136,6 +142,12 @@ func GetTestUrls(cliArgs *types.CliArgs) (urls []string, err error) {
 				urls = append(urls, u)
 			}
 		}
+	} else if cliArgs.WarcInput != "" {
+		archive, err := request.LoadWarcArchive(strings.Split(cliArgs.WarcInput, ","), appC)
+		if err != nil {
+			return nil, err
+		}
+		urls = archive.URLs()
 	}
 	return urls, nil
 }
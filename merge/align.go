@@ -0,0 +1,217 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// Scores of the signals matching two variations, pairs scoring below minAlignScore are never aligned
+const (
+	scoreIdentifier = 100
+	scoreCode       = 90
+	scoreAttributes = 50
+	// Names as similar as minNameSimilarity score scoreName, identical names scoreName+scoreNameBonus
+	scoreName      = 40
+	scoreNameBonus = 10
+	minAlignScore  = 40
+	// Names sharing fewer tokens than this are not similar
+	minNameSimilarity = 0.6
+)
+
+// Fields identifying a variation within a site, and product codes shared across sites
+var (
+	identifierFields = []string{"sku", "asin", "variation_id"}
+	codeFields       = []string{"upc", "ean", "gtin", "upc14", "isbn13", "isbn10", "isbn"}
+	attributeFields  = []string{"color", "size"}
+)
+
+// alignedVariation holds the products of all the sources describing the same variation
+type alignedVariation map[string]hash
+
+// alignVariations lines up products of the sources with the products of base (the source with most products)
+// Products of other sources are matched one to one on sku/asin, upc/ean/gtin, color/size and name similarity
+// A source returning a single product without any variation specific fields (parent level data) is
+// aligned with all the variations. Products which can't be matched are returned as unmatched
+func alignVariations(data map[string][]hash, base string) (aligned []alignedVariation, unmatched []types.UnmatchedVariation) {
+	baseProducts := data[base]
+	aligned = make([]alignedVariation, len(baseProducts))
+	for i, product := range baseProducts {
+		aligned[i] = alignedVariation{base: product}
+	}
+
+	sources := make([]string, 0, len(data))
+	for source := range data {
+		if source != base {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		products := data[source]
+		if len(products) == 1 && (len(baseProducts) == 1 || isParentLevel(products[0])) {
+			for i := range aligned {
+				aligned[i][source] = products[0]
+			}
+			continue
+		}
+
+		// Greedy one to one assignment, best scoring pairs first
+		type pair struct {
+			base, other, score int
+		}
+		pairs := make([]pair, 0)
+		for i, baseProduct := range baseProducts {
+			for j, product := range products {
+				if score := variationScore(baseProduct, product); score >= minAlignScore {
+					pairs = append(pairs, pair{i, j, score})
+				}
+			}
+		}
+		sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].score > pairs[b].score })
+
+		matchedBase := make(map[int]bool)
+		matched := make(map[int]bool)
+		for _, p := range pairs {
+			if matchedBase[p.base] || matched[p.other] {
+				continue
+			}
+			aligned[p.base][source] = products[p.other]
+			matchedBase[p.base] = true
+			matched[p.other] = true
+		}
+		for j, product := range products {
+			if !matched[j] {
+				unmatched = append(unmatched, unmatchedVariation(source, j, product))
+			}
+		}
+	}
+	return aligned, unmatched
+}
+
+// variationScore scores how likely two products describe the same variation
+// Conflicting codes or attributes rule out a match, skus may differ across sites
+func variationScore(a, b hash) int {
+	if equal, present := compareFields(a, b, identifierFields, normalizeText); present && equal {
+		return scoreIdentifier
+	}
+	if ca, cb := productCodes(a), productCodes(b); len(ca) > 0 && len(cb) > 0 {
+		for code := range ca {
+			if cb[code] {
+				return scoreCode
+			}
+		}
+		return 0
+	}
+
+	score := 0
+	attributes := 0
+	for _, field := range attributeFields {
+		va, vb := normalizeText(a[field]), normalizeText(b[field])
+		if va == "" || vb == "" {
+			continue
+		}
+		if va != vb {
+			return 0
+		}
+		attributes++
+	}
+	if attributes > 0 {
+		score += scoreAttributes
+	}
+	if similarity := nameSimilarity(normalizeText(a["name"]), normalizeText(b["name"])); similarity >= minNameSimilarity {
+		score += scoreName + int((similarity-minNameSimilarity)/(1-minNameSimilarity)*scoreNameBonus)
+	}
+	return score
+}
+
+// compareFields compares the first of fields present in both products
+func compareFields(a, b hash, fields []string, normalize func(interface{}) string) (equal bool, present bool) {
+	for _, field := range fields {
+		va, vb := normalize(a[field]), normalize(b[field])
+		if va != "" && vb != "" {
+			return va == vb, true
+		}
+	}
+	return false, false
+}
+
+// productCodes collects the codes of a product, a upc matches the same code sent as ean or gtin
+func productCodes(product hash) map[string]bool {
+	codes := make(map[string]bool)
+	for _, field := range codeFields {
+		if code := normalizeCode(product[field]); code != "" {
+			codes[code] = true
+		}
+	}
+	return codes
+}
+
+// isParentLevel is true for products without any variation specific field
+func isParentLevel(product hash) bool {
+	for _, fields := range [][]string{identifierFields, codeFields, attributeFields} {
+		for _, field := range fields {
+			if !isEmptyValue(product[field]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func normalizeText(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", value)))
+}
+
+// normalizeCode compares UPC-A, EAN-13 and GTIN-14 forms of a code (digits without leading zeros)
+func normalizeCode(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, fmt.Sprintf("%v", value))
+	return strings.TrimLeft(digits, "0")
+}
+
+// nameSimilarity is the dice coefficient of the name tokens
+func nameSimilarity(a, b string) float64 {
+	ta, tb := strings.Fields(a), strings.Fields(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	tokens := make(map[string]int)
+	for _, t := range ta {
+		tokens[t]++
+	}
+	common := 0
+	for _, t := range tb {
+		if tokens[t] > 0 {
+			tokens[t]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(ta)+len(tb))
+}
+
+func unmatchedVariation(source string, index int, product hash) types.UnmatchedVariation {
+	u := types.UnmatchedVariation{Source: source, Index: index}
+	for _, field := range identifierFields {
+		if v := normalizeText(product[field]); v != "" {
+			u.Sku = fmt.Sprintf("%v", product[field])
+			break
+		}
+	}
+	u.Name, _ = product["name"].(string)
+	return u
+}
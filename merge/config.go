@@ -0,0 +1,133 @@
+package merge
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Merge configs are stored in a redis hash, fields from least to most specific:
+// default, customer:{customer}, {domain}, customer:{customer}:{domain}
+const (
+	MergeConfigRedisKey = "merge_config"
+	// Messages {"site": "example.com"} drop the cached configs of a domain, messages without a site drop all of them
+	MergeConfigChannel = "merge_config_live_updates"
+	// Configs are re-read after this long even without a live update
+	mergeConfigTTL = 10 * time.Minute
+)
+
+type mergeConfigEntry struct {
+	// nil when the field isn't present in redis
+	config  *types.MergeConfig
+	fetched time.Time
+}
+
+var mergeConfigs = struct {
+	sync.RWMutex
+	entries map[string]mergeConfigEntry
+}{entries: make(map[string]mergeConfigEntry)}
+
+// mergeConfigFields returns the redis hash fields a crawl reads its config from, least specific first
+func mergeConfigFields(domain, customer string) []string {
+	fields := []string{"default"}
+	if customer != "" {
+		fields = append(fields, fmt.Sprintf("customer:%s", customer))
+	}
+	fields = append(fields, domain)
+	if customer != "" {
+		fields = append(fields, fmt.Sprintf("customer:%s:%s", customer, domain))
+	}
+	return fields
+}
+
+// GetMergeConfig combines the merge configs of a domain and customer, more specific configs override
+// settings (and merge preferences of single fields) of less specific ones
+// Returns the fields of the configs which were found
+func GetMergeConfig(domain, customer string, appC *types.Config) (config types.MergeConfig, layers []string) {
+	for _, field := range mergeConfigFields(domain, customer) {
+		layer := getMergeConfigLayer(field, appC)
+		if layer == nil {
+			continue
+		}
+		layers = append(layers, field)
+		if layer.MergeMode != "" {
+			config.MergeMode = layer.MergeMode
+		}
+		if len(layer.DataSources) > 0 {
+			config.DataSources = layer.DataSources
+		}
+		if len(layer.RequiredKeys) > 0 {
+			config.RequiredKeys = layer.RequiredKeys
+		}
+		if layer.Conflicts != nil {
+			config.Conflicts = layer.Conflicts
+		}
+		for key, preference := range layer.MergePreference {
+			if config.MergePreference == nil {
+				config.MergePreference = make(hash)
+			}
+			config.MergePreference[key] = preference
+		}
+	}
+	return config, layers
+}
+
+// getMergeConfigLayer reads a field of the merge config hash, from the process cache when fresh
+func getMergeConfigLayer(field string, appC *types.Config) *types.MergeConfig {
+	mergeConfigs.RLock()
+	entry, ok := mergeConfigs.entries[field]
+	mergeConfigs.RUnlock()
+	if ok && time.Since(entry.fetched) < mergeConfigTTL {
+		return entry.config
+	}
+	if appC == nil || appC.RedisRdstore == nil {
+		return nil
+	}
+
+	conn := appC.RedisRdstore.Get()
+	defer conn.Close()
+	raw, err := redis.String(conn.Do("HGET", MergeConfigRedisKey, field))
+	if err != nil && err != redis.ErrNil {
+		log.Printf("MERGE_CONFIG_READ_FAILED: field %s: %v\n", field, err)
+		return entry.config
+	}
+	entry = mergeConfigEntry{fetched: time.Now()}
+	if raw != "" {
+		config := &types.MergeConfig{}
+		if err = json.Unmarshal([]byte(raw), config); err != nil {
+			log.Printf("MERGE_CONFIG_INVALID: field %s: %v\n", field, err)
+		} else {
+			entry.config = config
+		}
+	}
+	mergeConfigs.Lock()
+	mergeConfigs.entries[field] = entry
+	mergeConfigs.Unlock()
+	return entry.config
+}
+
+// RemoveMergeConfigFromCache drops the cached configs of a domain (of all customers), or all of them without a domain
+func RemoveMergeConfigFromCache(domain string) {
+	mergeConfigs.Lock()
+	defer mergeConfigs.Unlock()
+	for field := range mergeConfigs.entries {
+		if domain == "" || field == domain || strings.HasSuffix(field, ":"+domain) {
+			delete(mergeConfigs.entries, field)
+		}
+	}
+}
+
+// ConfigMergePreference returns the default merge preference of the sources with the fields of a merge config overriding it
+func ConfigMergePreference(preference hash, dataSources []string) hash {
+	merged := generateDefaultMergePreference(nil, dataSources)
+	for key, value := range preference {
+		merged[key] = value
+	}
+	return merged
+}
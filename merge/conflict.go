@@ -0,0 +1,101 @@
+package merge
+
+import (
+	"sort"
+
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// Actions on products with conflicting fields (conflicts.action in the merge config)
+const (
+	ConflictActionReport = "report"
+	ConflictActionFlag   = "flag"
+	ConflictActionFail   = "fail"
+)
+
+// ConflictsKey lists the conflicting fields of a product flagged for QA
+const ConflictsKey = "_conflicts"
+
+// DefaultConflictConfig is used for domains without conflicts in their merge config
+var DefaultConflictConfig = types.ConflictConfig{
+	Numeric:     map[string]float64{"listprice": 0.25},
+	Categorical: []string{"listprice_currency"},
+	Action:      ConflictActionReport,
+}
+
+// detectConflicts compares the fields of a variation supplied by more than one source
+// Numeric fields conflict when they differ by more than their tolerance relative to the smallest value
+// (non positive values are ignored), categorical fields when they have different values
+func detectConflicts(index int, dfs alignedVariation, fieldSources hash, config types.ConflictConfig) []types.FieldConflict {
+	sources := make([]string, 0, len(dfs))
+	for source := range dfs {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	conflicts := make([]types.FieldConflict, 0)
+	fields := make([]string, 0, len(config.Numeric))
+	for field := range config.Numeric {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		values := make(hash)
+		min, max := 0.0, 0.0
+		for _, source := range sources {
+			v, ok := toNumber(dfs[source][field])
+			if !ok || v <= 0 {
+				continue
+			}
+			if len(values) == 0 || v < min {
+				min = v
+			}
+			if len(values) == 0 || v > max {
+				max = v
+			}
+			values[source] = dfs[source][field]
+		}
+		if len(values) < 2 {
+			continue
+		}
+		if deviation := (max - min) / min; deviation > config.Numeric[field] {
+			conflicts = append(conflicts, newConflict(index, field, values, fieldSources, deviation))
+		}
+	}
+
+	for _, field := range config.Categorical {
+		values := make(hash)
+		distinct := make(map[string]bool)
+		for _, source := range sources {
+			if value := dfs[source][field]; !isEmptyValue(value) {
+				values[source] = value
+				distinct[voteKey(value)] = true
+			}
+		}
+		if len(distinct) > 1 {
+			conflicts = append(conflicts, newConflict(index, field, values, fieldSources, 0))
+		}
+	}
+	return conflicts
+}
+
+func newConflict(index int, field string, values hash, fieldSources hash, deviation float64) types.FieldConflict {
+	conflict := types.FieldConflict{Product: index, Field: field, Values: values, Deviation: deviation}
+	switch picked := fieldSources[field].(type) {
+	case string:
+		conflict.Picked = picked
+	case []string:
+		if len(picked) > 0 {
+			conflict.Picked = picked[0]
+		}
+	}
+	return conflict
+}
+
+// conflictConfig returns the conflict config of the merge, the default one when not configured
+func (mg *Merge) conflictConfig() types.ConflictConfig {
+	if mg.Conflicts == nil {
+		return DefaultConflictConfig
+	}
+	return *mg.Conflicts
+}
@@ -1,54 +1,64 @@
 package merge
 
 import (
+	"fmt"
 	"log"
 	"reflect"
 	"sync"
 	"time"
 
-	"github.com/Semantics3/go-crawler/sources/amazon"
-	"github.com/Semantics3/go-crawler/sources/diffbot"
-	"github.com/Semantics3/go-crawler/sources/m101"
-	"github.com/Semantics3/go-crawler/sources/supervised"
-	"github.com/Semantics3/go-crawler/sources/unsupervised"
+	"github.com/Semantics3/go-crawler/sources"
+	_ "github.com/Semantics3/go-crawler/sources/all"
 	"github.com/Semantics3/go-crawler/stats"
 	"github.com/Semantics3/go-crawler/types"
-	// cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+	"github.com/Semantics3/go-crawler/utils"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
 )
 
 type hash = map[string]interface{}
 
+// FieldSourcesKey holds the source of each field of a product, when requested through job param field_sources
+const FieldSourcesKey = "_field_sources"
+
 type Merge struct {
 	MergePreference hash
 	DataSources     []string
 	MergeMode       string
-	DataMutex       *sync.RWMutex
 	Data            map[string][]hash
+	// CASCADE merges move on to the next source until every required key has a value (see isMergeComplete)
+	RequiredKeys []string
+	// Return the source of each field in the products (_field_sources)
+	FieldSources bool
+	// Number of fields supplied by each source across products
+	FieldSourceCounts map[string]int
+	// Fields compared across sources and what to do with conflicting products (DefaultConflictConfig when nil)
+	Conflicts *types.ConflictConfig
+	// Number of conflicts of each field across products
+	ConflictCounts map[string]int
 }
 
 // Merge - Entry point from executor to perform merging of data from multiple sources.
 // Internally it invokes source.Request, source.Extract and source.Normalize
 func (mg *Merge) Merge(workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
 	mg.Data = make(map[string][]hash, 0)
-	mg.DataMutex = &sync.RWMutex{}
 	sourceObjs := make([]types.Sources, 0)
 	for _, source := range mg.DataSources {
-		var sourceObj types.Sources
-		if source == "M101" {
-			sourceObj = &m101.M101{Name: source}
-		} else if source == "WRAPPER" {
-			sourceObj = &supervised.Supervised{Name: source}
-		} else if source == "UNSUPERVISED" {
-			sourceObj = &unsupervised.Unsupervised{Name: source}
-		} else if source == "DIFFBOT" {
-			sourceObj = &diffbot.Diffbot{Name: source}
-		} else if source == "AMAZON" {
-			sourceObj = &amazon.Amazon{Name: source}
-		} else {
+		sourceObj, err := sources.New(source)
+		if err != nil {
 			log.Printf("UNKNOWN_DATA_SOURCE: Source %s, URL %s", source, workflow.URL)
+			continue
+		}
+		if info, _ := sources.Lookup(source); len(info.MissingEnv()) > 0 {
+			log.Printf("DATA_SOURCE_NOT_CONFIGURED: Source %s is missing %v, URL %s", source, info.MissingEnv(), workflow.URL)
 		}
 		sourceObjs = append(sourceObjs, sourceObj)
 	}
+	if workflow.JobInput != nil {
+		if fieldSources, _ := cutils.GetIntKey(workflow.JobInput.JobParams, "field_sources"); fieldSources == 1 {
+			mg.FieldSources = true
+		}
+	}
+
 	// Normalize each data source in for loop
 	// Implement merge logic
 	if mg.MergeMode == "MERGE_ALL" {
@@ -56,33 +66,76 @@ func (mg *Merge) Merge(workflow *types.CrawlWorkflow, pipeline types.Pipeline, a
 	} else {
 		code, err = mg.InitiateSeq(sourceObjs, workflow, pipeline, appC)
 	}
+
+	// Products extracted from a single source are entirely supplied by it
+	if mg.FieldSourceCounts == nil && len(mg.Data) == 1 {
+		mg.attributeToSingleSource(workflow)
+	}
+	if len(mg.FieldSourceCounts) > 0 && workflow.DomainInfo != nil {
+		stats.WriteFieldSourceMetricsToDatadog(mg.FieldSourceCounts, workflow, appC)
+	}
+	if len(mg.ConflictCounts) > 0 {
+		if workflow.DomainInfo != nil {
+			stats.WriteConflictMetricsToDatadog(mg.ConflictCounts, workflow, appC)
+		}
+		if err == nil && mg.conflictConfig().Action == ConflictActionFail {
+			return "MERGE_CONFLICT", fmt.Errorf("sources disagree on %d fields of %s", len(workflow.Data.Conflicts), workflow.URL)
+		}
+	}
 	return code, err
 }
 
+// sourceResult is the outcome of a source run against its own copy of the workflow
+type sourceResult struct {
+	source    string
+	workflow  *types.CrawlWorkflow
+	code      string
+	err       error
+	extracted bool
+}
+
 // InitiateConcurrently - Initiate all extract functions concurrently
-// Store all data into an array and call merge data
+// Each source runs against its own copy of the workflow (see isolatedWorkflow), results are combined
+// in the order of data sources once all of them are done and merged
 func (mg *Merge) InitiateConcurrently(dataSources []types.Sources, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
 	var wg sync.WaitGroup
-	for _, dataSource := range dataSources {
+	results := make([]*sourceResult, len(dataSources))
+	// Sources extracting from the html of the page download it once
+	page := &types.SharedPage{}
+	for i, dataSource := range dataSources {
+		if dataSource == nil {
+			continue
+		}
 		wg.Add(1)
-		go func(ds types.Sources, wc types.CrawlWorkflow) {
+		go func(i int, ds types.Sources, wc *types.CrawlWorkflow) {
 			defer wg.Done()
-			canExtract, code, err := ds.Request(workflow.URL, workflow, pipeline, appC)
+			start := time.Now()
+			result := &sourceResult{source: ds.GetName(), workflow: wc}
+			results[i] = result
+			canExtract, code, err := ds.Request(wc.URL, wc, pipeline, appC)
 			log.Printf("CONCURRENT_REQUEST_RESULT: source %s, canExtract %v, code %s, error %v", ds.GetName(), canExtract, code, err)
 			if err == nil && canExtract {
-				code, err = ds.Extract(workflow.URL, workflow, pipeline, appC)
+				code, err = ds.Extract(wc.URL, wc, pipeline, appC)
 				log.Printf("CONCURRENT_EXTRACT_RESULT: source %s, code %s, error %v", ds.GetName(), code, err)
 				if err == nil {
-					ds.Normalize(workflow, appC)
-					mg.DataMutex.Lock()
-					mg.Data[ds.GetName()] = wc.Data.Products
-					mg.DataMutex.Unlock()
+					ds.Normalize(wc, appC)
 				}
+			} else if err == nil {
+				code, err = "CANNOT_EXTRACT", fmt.Errorf("%s could not extract data from the response", ds.GetName())
 			}
-		}(dataSource, *workflow)
+			if err == nil && len(wc.Data.Products) == 0 {
+				code, err = "NO_PRODUCT_FROM_SOURCE", fmt.Errorf("%s resulted in an empty products response", ds.GetName())
+			}
+			result.code, result.err, result.extracted = code, err, err == nil
+			observeSource(ds.GetName(), start, result.extracted)
+		}(i, dataSource, isolatedWorkflow(workflow, page))
 	}
-
 	wg.Wait()
+
+	code, err = mg.combineSourceResults(results, workflow)
+	if len(mg.Data) == 0 {
+		return code, err
+	}
 	if mg.MergePreference == nil {
 		for source := range mg.Data {
 			mg.MergePreference = generateDefaultMergePreference(mg.Data[source], mg.DataSources)
@@ -92,14 +145,151 @@ func (mg *Merge) InitiateConcurrently(dataSources []types.Sources, workflow *typ
 	// Individual product/variation merging logic
 	// Merge individual products into an array and overwrite the workflow.Data.Products
 	mg.TransformProductsAndMerge(workflow)
-	return
+	return "", nil
+}
+
+// isolatedWorkflow copies the workflow for a source running concurrently with other sources
+// Fields written by the sources start afresh, shared structs written by them are copied
+func isolatedWorkflow(workflow *types.CrawlWorkflow, page *types.SharedPage) *types.CrawlWorkflow {
+	wc := *workflow
+	wc.SharedPage = page
+	wc.Data = types.ExtractionResponse{}
+	wc.AjaxFailedStatusMap = nil
+	if workflow.DomainInfo != nil {
+		domainInfo := *workflow.DomainInfo
+		wc.DomainInfo = &domainInfo
+	}
+	if workflow.Warc != nil {
+		warc := *workflow.Warc
+		wc.Warc = &warc
+	}
+	return &wc
+}
+
+// combineSourceResults collects the products of the sources which extracted data and records the failures of the rest
+// The workflow takes the response of the most preferred source which extracted data
+// When none of them did, the failure of the most preferred source is returned and fails the workflow
+// Metrics of all the sources are added up, each of them crawled and extracted for the workflow
+func (mg *Merge) combineSourceResults(results []*sourceResult, workflow *types.CrawlWorkflow) (code string, err error) {
+	var primary, failed *sourceResult
+	failures := make([]types.SourceFailure, 0)
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if result.extracted {
+			mg.Data[result.source] = result.workflow.Data.Products
+			if primary == nil {
+				primary = result
+			}
+			continue
+		}
+		failure := types.SourceFailure{Source: result.source, Code: result.code}
+		if result.err != nil {
+			failure.Message = result.err.Error()
+		}
+		failures = append(failures, failure)
+		if failed == nil {
+			failed = result
+		}
+	}
+
+	if primary == nil {
+		primary = failed
+	}
+	if primary != nil {
+		wc := primary.workflow
+		workflow.Data = wc.Data
+		workflow.WebResponse = wc.WebResponse
+		workflow.DomainInfo = wc.DomainInfo
+		workflow.Session = wc.Session
+		workflow.Warc = wc.Warc
+		workflow.AjaxFailedStatusMap = wc.AjaxFailedStatusMap
+		workflow.CacheKey = wc.CacheKey
+		workflow.CacheExpiry = wc.CacheExpiry
+		workflow.UnsupervisedCacheKey = wc.UnsupervisedCacheKey
+		workflow.CrawlTime = wc.CrawlTime
+		workflow.ProductMetrics, workflow.ExtractionMetrics = aggregateSourceMetrics(results, primary, workflow)
+		workflow.Status = wc.Status
+		workflow.FailureType = wc.FailureType
+		workflow.FailureMessage = wc.FailureMessage
+		workflow.Data.ExtractionDataSource = primary.source
+	}
+	if len(failures) > 0 {
+		log.Printf("MERGE_SOURCE_FAILURES: (%s) %v\n", workflow.URL, failures)
+		workflow.Data.SourceFailures = failures
+	}
+	if len(mg.Data) == 0 && failed != nil {
+		failureType, failureMessage := failed.code, ""
+		if failed.err != nil {
+			failureMessage = failed.err.Error()
+		}
+		workflow.Status = 0
+		workflow.FailureType = &failureType
+		workflow.FailureMessage = &failureMessage
+		return failed.code, failed.err
+	}
+	return "", nil
+}
+
+// aggregateSourceMetrics adds up the metrics of every source which ran to the metrics of the primary source
+// Sources start from a copy of the metrics of the workflow, only what each of them collected on top of it is added
+func aggregateSourceMetrics(results []*sourceResult, primary *sourceResult, workflow *types.CrawlWorkflow) (pm types.ProductMetrics, em types.ExtractionMetrics) {
+	base, baseEm := workflow.ProductMetrics, workflow.ExtractionMetrics
+	pm, em = primary.workflow.ProductMetrics, primary.workflow.ExtractionMetrics
+	for _, result := range results {
+		if result == nil || result == primary {
+			continue
+		}
+		rpm, rem := result.workflow.ProductMetrics, result.workflow.ExtractionMetrics
+		pm.Extraction += rpm.Extraction - base.Extraction
+		pm.Latency += rpm.Latency - base.Latency
+		pm.UrlCount += rpm.UrlCount - base.UrlCount
+		pm.RetryCount += rpm.RetryCount - base.RetryCount
+
+		em.S3 += rem.S3 - baseEm.S3
+		em.Products += rem.Products - baseEm.Products
+		em.Links += rem.Links - baseEm.Links
+		em.Preprocess += rem.Preprocess - baseEm.Preprocess
+		em.UrlCount += rem.UrlCount - baseEm.UrlCount
+		em.Iterations += rem.Iterations - baseEm.Iterations
+		em.AjaxCacheHits += rem.AjaxCacheHits - baseEm.AjaxCacheHits
+		em.AjaxCacheMisses += rem.AjaxCacheMisses - baseEm.AjaxCacheMisses
+		em.AjaxDropped += rem.AjaxDropped - baseEm.AjaxDropped
+	}
+	em.Total = em.S3 + em.Products + em.Links + em.Preprocess
+	return pm, em
 }
 
 // InitiateSeq - Initiate all extract functions sequentially (to save M101 API call costs- avoid if wrapper extracts data)
+// Sources are tried in the order planned against the budget of the crawl (see planCascade), sources which
+// don't fit in the budget left are skipped
 // Store all data into an array and call merge data
 func (mg *Merge) InitiateSeq(dataSources []types.Sources, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
-	var canExtract bool
+	names := make([]string, 0, len(dataSources))
 	for _, ds := range dataSources {
+		if ds != nil {
+			names = append(names, ds.GetName())
+		}
+	}
+	plan := planCascade(names, cascadeBudget(workflow.JobInput), appC)
+	workflow.CascadePlan = &plan
+	start := time.Now()
+	defer func() {
+		plan.Latency = utils.ComputeDuration(start) * 1000
+		log.Printf("CASCADE_PLAN: (%s) sources %v, tried %v, skipped %v, cost %.2f, latency %.0fms\n", workflow.URL, plan.Sources, plan.Tried, plan.Skipped, plan.Cost, plan.Latency)
+	}()
+
+	var canExtract bool
+	// Response of the first source which extracted products
+	var extracted *types.ExtractionResponse
+	for _, ds := range orderSources(dataSources, plan) {
+		if reason := skipReason(&plan, ds.GetName(), utils.ComputeDuration(start)*1000, appC); reason != "" {
+			plan.Skipped[ds.GetName()] = reason
+			continue
+		}
+		plan.Tried = append(plan.Tried, ds.GetName())
+		plan.Cost += sourceCost(ds.GetName(), appC)
 
 		// Track request metrics in Datadog for each data source
 		ddMetrics := stats.DatadogMetrics{
@@ -116,6 +306,7 @@ func (mg *Merge) InitiateSeq(dataSources []types.Sources, workflow *types.CrawlW
 		log.Printf("SEQUENTIAL_REQUEST_RESULT: source %s, canExtract %v, code %s, error %v", ds.GetName(), canExtract, code, err)
 		// check if it is permanent failure
 		if err != nil && (code == "NOT_PRODUCT_PAGE" || code == "DOES_NOT_EXIST") {
+			observeSource(ds.GetName(), ddMetrics.Start, true)
 			workflow.Data.Code = code
 			workflow.Data.Status = 0
 			workflow.Data.Message = err.Error()
@@ -129,29 +320,50 @@ func (mg *Merge) InitiateSeq(dataSources []types.Sources, workflow *types.CrawlW
 			// If the url is not a product page as detected by WRAPPER/SITEDETAILS we do not want
 			// to fall back to other data sources
 			if err != nil && (code == "NOT_PRODUCT_PAGE" || code == "DOES_NOT_EXIST") {
+				observeSource(ds.GetName(), ddMetrics.Start, true)
 				workflow.Data.Code = code
 				workflow.Data.Status = 0
 				workflow.Data.Message = err.Error()
 				break
 			}
 			if err == nil && len(workflow.Data.Products) > 0 {
+				observeSource(ds.GetName(), ddMetrics.Start, true)
 				ds.Normalize(workflow, appC)
 				mg.Data[ds.GetName()] = workflow.Data.Products
-				// Send name of data source used
-				workflow.Data.ExtractionDataSource = ds.GetName()
+				if extracted == nil {
+					// Send name of data source used
+					workflow.Data.ExtractionDataSource = ds.GetName()
+					data := workflow.Data
+					extracted = &data
+				}
 				// exiting loop to avoid making other API network calls
-				break
+				if mg.isMergeComplete() {
+					break
+				}
+				log.Printf("MERGE_INCOMPLETE: (%s) required keys %v are missing after %s, trying next source\n", workflow.URL, mg.RequiredKeys, ds.GetName())
+				continue
 			}
 		}
+		observeSource(ds.GetName(), ddMetrics.Start, false)
+	}
+	// Sources tried after the first one extracted products only add to its products, their failures are not returned
+	if extracted != nil {
+		workflow.Data = *extracted
+		code, err = "", nil
+	}
+	if len(plan.Tried) == 0 && len(plan.Skipped) > 0 {
+		return "SOURCE_BUDGET_EXCEEDED", cutils.PrintErr("SOURCE_BUDGET_EXCEEDED", fmt.Sprintf("none of the sources %v fit in the budget %+v", plan.Sources, plan.Budget), workflow.URL)
 	}
 
 	// Only when the data was extracted from multiple sources, perform MERGING else return the result directly
 	if len(mg.Data) > 1 {
 		// Send in the first source's products array to generate mergePreference,
 		// assuming the other sources products array is matching in order and normalized successfully
-		for source := range mg.Data {
-			mg.MergePreference = generateDefaultMergePreference(mg.Data[source], mg.DataSources)
-			break
+		if mg.MergePreference == nil {
+			for source := range mg.Data {
+				mg.MergePreference = generateDefaultMergePreference(mg.Data[source], mg.DataSources)
+				break
+			}
 		}
 		// Merge individual products into an array and overwrite the workflow.Data.Products
 		mg.TransformProductsAndMerge(workflow)
@@ -159,38 +371,117 @@ func (mg *Merge) InitiateSeq(dataSources []types.Sources, workflow *types.CrawlW
 	return
 }
 
-// TransformProductsAndMerge - creates a local variable dfs to match the first variable parameter of function mergeData
-// Assuming that all sources other than WRAPPER returns a single product array
+// isMergeComplete is true when every required key has a value in the products extracted so far
+func (mg *Merge) isMergeComplete() bool {
+	for _, key := range mg.RequiredKeys {
+		found := false
+		for _, products := range mg.Data {
+			for _, product := range products {
+				if !isEmptyValue(product[key]) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TransformProductsAndMerge - aligns the variations of all the sources and merges each of them (see mergeData)
+// Variations which couldn't be aligned are not merged, they are reported in unmatched_variations
 func (mg *Merge) TransformProductsAndMerge(workflow *types.CrawlWorkflow) {
 	// Fetching source with max products crawled to iterate over
 	log.Printf("Transforming Products and Merging...\n")
 	source := getMaxLengthKey(mg.Data)
-	products := mg.Data[source]
-	for i := 0; i < len(products); i++ {
-		// To store a single product at a time but from multiple sources, e.g. WRAPPER,M101,etc.
-		// dfs = {"WRAPPER":PRODUCT_1,"M101":PRODUCT_1,"UNSUPERVISED":PRODUCT_1}
-		dfs := make(map[string]hash, 0)
-		// Looping over all sources to fetch the product at index i
-		for src := range mg.Data {
-			if src != "WRAPPER" {
-				// Since DIFFBOT, UCE and M101 returns a product response of length 1
-				dfs[src] = mg.Data[src][0]
-			} else {
-				dfs[src] = mg.Data[src][i]
-			}
-		}
+	// dfs = {"WRAPPER":PRODUCT_1,"M101":PRODUCT_1,"UNSUPERVISED":PRODUCT_1} for each variation
+	aligned, unmatched := alignVariations(mg.Data, source)
+	if len(unmatched) > 0 {
+		log.Printf("MERGE_UNMATCHED_VARIATIONS: (%s) %d variations of other sources couldn't be aligned with %s: %v\n", workflow.URL, len(unmatched), source, unmatched)
+		workflow.Data.UnmatchedVariations = unmatched
+	}
+	products := make([]hash, 0, len(aligned))
+	conflictConfig := mg.conflictConfig()
+	for i, dfs := range aligned {
 		merged, fieldSources := mergeData(dfs, mg.MergePreference)
 		log.Printf("Product %d has the following fieldSources %v\n", i, fieldSources)
-		workflow.Data.Products[i] = merged
+		mg.collectFieldSources(merged, fieldSources)
+		conflicts := detectConflicts(i, dfs, fieldSources, conflictConfig)
+		mg.collectConflicts(merged, conflicts, conflictConfig.Action)
+		workflow.Data.Conflicts = append(workflow.Data.Conflicts, conflicts...)
+		products = append(products, merged)
+	}
+	if len(workflow.Data.Conflicts) > 0 {
+		log.Printf("MERGE_CONFLICTS: (%s) sources disagree on %d fields: %v\n", workflow.URL, len(workflow.Data.Conflicts), workflow.Data.Conflicts)
+	}
+	workflow.Data.Products = products
+}
+
+// collectConflicts counts the conflicts of each field and flags the product for QA when configured
+func (mg *Merge) collectConflicts(product hash, conflicts []types.FieldConflict, action string) {
+	if len(conflicts) == 0 {
+		return
+	}
+	if mg.ConflictCounts == nil {
+		mg.ConflictCounts = make(map[string]int)
+	}
+	fields := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		mg.ConflictCounts[conflict.Field]++
+		fields = append(fields, conflict.Field)
+	}
+	if action == ConflictActionFlag && product != nil {
+		product[ConflictsKey] = fields
+	}
+}
+
+// attributeToSingleSource marks every non empty field of the products as supplied by the only source which extracted them
+func (mg *Merge) attributeToSingleSource(workflow *types.CrawlWorkflow) {
+	for source := range mg.Data {
+		for _, product := range workflow.Data.Products {
+			fieldSources := make(hash, len(product))
+			for key, value := range product {
+				if key != FieldSourcesKey && !isEmptyValue(value) {
+					fieldSources[key] = source
+				}
+			}
+			mg.collectFieldSources(product, fieldSources)
+		}
+	}
+}
+
+// collectFieldSources counts the fields supplied by each source and adds them to the product when requested
+func (mg *Merge) collectFieldSources(product hash, fieldSources hash) {
+	if product == nil {
+		return
+	}
+	if mg.FieldSourceCounts == nil {
+		mg.FieldSourceCounts = make(map[string]int)
+	}
+	for _, source := range fieldSources {
+		switch s := source.(type) {
+		case string:
+			mg.FieldSourceCounts[s]++
+		case []string:
+			for _, src := range s {
+				mg.FieldSourceCounts[src]++
+			}
+		}
+	}
+	if mg.FieldSources {
+		product[FieldSourcesKey] = fieldSources
 	}
 }
 
 // getMaxLengthKey - Takes in all source's normalized products and returns the source name with maximum number of products in the array.
+// Ties go to WRAPPER, then to the source name in alphabetical order
 func getMaxLengthKey(data map[string][]hash) (key string) {
-	maxLen := 0
+	maxLen := -1
 	for src, products := range data {
-		if len(products) >= maxLen {
+		if len(products) > maxLen || (len(products) == maxLen && key != "WRAPPER" && (src == "WRAPPER" || src < key)) {
 			key = src
+			maxLen = len(products)
 		}
 	}
 	return key
@@ -213,6 +504,8 @@ func generateDefaultMergePreference(products []hash, dataSources []string) (merg
 }
 
 // mergeData - dataFromSources {"WRAPPER":{name, desc}, "M101": {name, desc}} [data of 1 child variation from all sources]
+// Each field is merged with the strategy configured in mergePreference (first non empty value by default)
+// Values are ordered by the confidence of their source in them (see types.ConfidenceKey), then preference order
 func mergeData(dataFromSources map[string]hash, mergePreference hash) (merged hash, fieldSources hash) {
 	// In case of Sequential, No need to merge since the data is fetched from a single source by avoiding other API calls
 	if mergePreference == nil {
@@ -220,24 +513,32 @@ func mergeData(dataFromSources map[string]hash, mergePreference hash) (merged ha
 	}
 	merged = make(hash, 0)
 	fieldSources = make(hash, 0)
-	for key, sources := range mergePreference {
-		switch v := sources.(type) {
-		case []string:
-			for _, source := range sources.([]string) {
-				if !isEmptyValue(dataFromSources[source][key]) {
-					merged[key] = dataFromSources[source][key]
-					fieldSources[key] = source
-					break
-				}
-			}
-		case string:
-			source, _ := sources.(string)
+	for key, preference := range mergePreference {
+		strategyName, sources, ok := parseFieldPreference(preference, dataFromSources)
+		if !ok {
+			log.Printf("MERGE_UNKNOWN_SOURCE_TYPE_SKIP: Source %v, Type %T", preference, preference)
+			continue
+		}
+		candidates := make([]Candidate, 0, len(sources))
+		for _, source := range sources {
 			if !isEmptyValue(dataFromSources[source][key]) {
-				merged[key] = dataFromSources[source][key]
-				fieldSources[key] = source
+				product := dataFromSources[source]
+				candidates = append(candidates, Candidate{Source: source, Value: product[key], Time: productTime(product), Confidence: fieldConfidence(product, key)})
 			}
-		default:
-			log.Printf("MERGE_UNKNOWN_SOURCE_TYPE_SKIP: Source %v, Type %v", sources, v)
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		byConfidence(candidates)
+		value, picked := getStrategy(strategyName).Merge(key, candidates)
+		if len(picked) == 0 {
+			continue
+		}
+		merged[key] = value
+		if len(picked) == 1 {
+			fieldSources[key] = picked[0]
+		} else {
+			fieldSources[key] = picked
 		}
 	}
 	return
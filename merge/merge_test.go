@@ -1,9 +1,15 @@
 package merge
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/tests/pagetest"
+	"github.com/Semantics3/go-crawler/tests/redistest"
 	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -113,6 +119,430 @@ func (suite *MergeSuite) Test_03_TransformProductsAndMerge() {
 	suite.Assert().Equal(nil, data["description"])
 }
 
+// Test_04_FieldSources - tests provenance of merged fields
+func (suite *MergeSuite) Test_04_FieldSources() {
+	mg := Merge{Data: suite.merge.Data, MergePreference: suite.merge.MergePreference, FieldSources: true}
+	workflow := &types.CrawlWorkflow{}
+	workflow.Data.Products = []hash{map[string]interface{}{}}
+	mg.TransformProductsAndMerge(workflow)
+
+	fieldSources, ok := workflow.Data.Products[0][FieldSourcesKey].(hash)
+	suite.Assert().Equal(true, ok)
+	suite.Assert().Equal("WRAPPER", fieldSources["sku"])
+	suite.Assert().Equal("M101", fieldSources["listprice"])
+	suite.Assert().Equal(map[string]int{"WRAPPER": 2, "M101": 2}, mg.FieldSourceCounts)
+
+	// case: provenance is not returned unless requested
+	mg = Merge{Data: suite.merge.Data, MergePreference: suite.merge.MergePreference}
+	workflow.Data.Products = []hash{map[string]interface{}{}}
+	mg.TransformProductsAndMerge(workflow)
+	suite.Assert().Equal(nil, workflow.Data.Products[0][FieldSourcesKey])
+	suite.Assert().Equal(4, mg.FieldSourceCounts["WRAPPER"]+mg.FieldSourceCounts["M101"])
+}
+
+// Test_05_MergeStrategies - tests per field merge strategies
+func (suite *MergeSuite) Test_05_MergeStrategies() {
+	dataFromSources := map[string]hash{
+		"WRAPPER": {"brand": "Nike", "name": "Air Max", "listprice": "100.00", "images": []interface{}{"a.jpg", "b.jpg"}, "color": "Red", "time": 1760000000},
+		"M101":    {"brand": "NIKE", "name": "Nike Air Max 90 Sneakers", "listprice": "102.00", "images": []interface{}{"b.jpg", "c.jpg"}, "color": "Blue", "time": 1760000500},
+		"AMAZON":  {"brand": "Adidas", "name": "Air Max 90", "listprice": "$1,020.00", "images": []string{"d.jpg"}, "color": "Green", "time": 1750000000},
+	}
+	sources := []interface{}{"WRAPPER", "M101", "AMAZON"}
+	mergePreference := hash{
+		"brand":     hash{"strategy": "majority", "sources": sources},
+		"name":      hash{"strategy": "longest"},
+		"listprice": hash{"strategy": "median", "sources": sources},
+		"images":    hash{"strategy": "union", "sources": sources},
+		"color":     hash{"strategy": "most_recent", "sources": sources},
+		"time":      []interface{}{"AMAZON", "WRAPPER"},
+	}
+	merged, fieldSources := mergeData(dataFromSources, mergePreference)
+
+	// case: majority vote is case insensitive and keeps the preferred spelling
+	suite.Assert().Equal("Nike", merged["brand"])
+	suite.Assert().Equal([]string{"WRAPPER", "M101"}, fieldSources["brand"])
+	suite.Assert().Equal("Nike Air Max 90 Sneakers", merged["name"])
+	// case: outlier is rejected, value closest to the median is returned as is
+	suite.Assert().Equal("100.00", merged["listprice"])
+	suite.Assert().Equal("WRAPPER", fieldSources["listprice"])
+	suite.Assert().Equal([]interface{}{"a.jpg", "b.jpg", "c.jpg", "d.jpg"}, merged["images"])
+	suite.Assert().Equal("Blue", merged["color"])
+	// case: lists decoded from json keep the default behaviour
+	suite.Assert().Equal(1750000000, merged["time"])
+	suite.Assert().Equal("AMAZON", fieldSources["time"])
+}
+
+// Test_06_AlignVariations - tests alignment of variations across sources
+func (suite *MergeSuite) Test_06_AlignVariations() {
+	mg := Merge{
+		Data: map[string][]hash{
+			"WRAPPER": {
+				{"sku": "W-1", "upc": "012345678905", "color": "Red", "size": "M", "name": "Tee Red M"},
+				{"sku": "W-2", "upc": "012345678912", "color": "Blue", "size": "M", "name": "Tee Blue M"},
+				{"sku": "W-3", "color": "Green", "size": "L", "name": "Tee Green L"},
+				{"sku": "W-4", "name": "Classic Cotton Tee Black XL"},
+				{"sku": "W-5", "color": "Yellow", "size": "XS", "name": "Tee Yellow XS"},
+			},
+			// Listed in a different order, matched on upc (ean form), color/size and name
+			"M101": {
+				{"ean": "0012345678912", "listprice": "12.00"},
+				{"color": "green", "size": "L", "listprice": "13.00"},
+				{"name": "classic cotton tee black xl", "listprice": "14.00"},
+				{"upc": "012345678905", "listprice": "11.00"},
+				{"sku": "M-5", "color": "Purple", "size": "S", "name": "Tee Purple S"},
+			},
+			// Parent level data applies to all the variations
+			"AMAZON": {{"brand": "Acme"}},
+		},
+		MergePreference: hash{
+			"sku":       []string{"WRAPPER", "M101"},
+			"listprice": []string{"M101", "WRAPPER"},
+			"brand":     []string{"WRAPPER", "AMAZON"},
+		},
+	}
+	workflow := &types.CrawlWorkflow{}
+	mg.TransformProductsAndMerge(workflow)
+
+	// case: ties on number of products go to WRAPPER, its variations are kept in order
+	suite.Assert().Equal(5, len(workflow.Data.Products))
+	suite.Assert().Equal(nil, workflow.Data.Products[4]["listprice"])
+	for i, listprice := range []string{"11.00", "12.00", "13.00", "14.00"} {
+		suite.Assert().Equal(fmt.Sprintf("W-%d", i+1), workflow.Data.Products[i]["sku"])
+		suite.Assert().Equal(listprice, workflow.Data.Products[i]["listprice"])
+		suite.Assert().Equal("Acme", workflow.Data.Products[i]["brand"])
+	}
+
+	// case: variation without a counterpart is reported, not merged
+	suite.Assert().Equal([]types.UnmatchedVariation{{Source: "M101", Index: 4, Sku: "M-5", Name: "Tee Purple S"}}, workflow.Data.UnmatchedVariations)
+
+	// case: near-identical names align variations without identifiers or attributes
+	suite.Assert().Equal(true, variationScore(hash{"name": "Linen Tee White Medium"}, hash{"name": "linen tee white m"}) >= minAlignScore)
+	suite.Assert().Equal(true, variationScore(hash{"name": "Linen Tee White Medium"}, hash{"name": "Linen Tee White"}) >= minAlignScore)
+	suite.Assert().Equal(0, variationScore(hash{"name": "Linen Tee White Medium"}, hash{"name": "Wool Scarf White"}))
+	mg = Merge{
+		Data: map[string][]hash{
+			"WRAPPER": {{"sku": "W-1", "name": "Linen Tee White Medium"}, {"sku": "W-2", "name": "Linen Tee Black Large"}},
+			"M101":    {{"name": "Linen Tee Black", "listprice": "21.00"}, {"name": "Linen Tee White", "listprice": "19.00"}},
+		},
+		MergePreference: hash{"sku": []string{"WRAPPER", "M101"}, "listprice": []string{"M101", "WRAPPER"}},
+	}
+	workflow = &types.CrawlWorkflow{}
+	mg.TransformProductsAndMerge(workflow)
+	suite.Require().Equal(2, len(workflow.Data.Products))
+	suite.Assert().Equal("19.00", workflow.Data.Products[0]["listprice"])
+	suite.Assert().Equal("21.00", workflow.Data.Products[1]["listprice"])
+	suite.Assert().Empty(workflow.Data.UnmatchedVariations)
+
+	// case: conflicting codes are never aligned
+	suite.Assert().Equal(0, variationScore(hash{"upc": "012345678905", "name": "Tee"}, hash{"upc": "012345678912", "name": "Tee"}))
+}
+
+// fakeSource writes its products, response status and metrics to the workflow like the real sources do
+type fakeSource struct {
+	name     string
+	products []hash
+	code     string
+}
+
+func (f *fakeSource) GetName() string      { return f.name }
+func (f *fakeSource) GetErrorCode() string { return f.code }
+func (f *fakeSource) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (bool, string, error) {
+	workflow.Data = types.ExtractionResponse{Products: f.products, Status: 1}
+	workflow.ProductMetrics.UrlCount++
+	workflow.ProductMetrics.Latency += 0.5
+	workflow.ExtractionMetrics.Iterations++
+	if workflow.DomainInfo != nil {
+		workflow.DomainInfo.IsProductUrl = len(f.products) > 0
+	}
+	if f.code != "" {
+		workflow.Data.Status = 0
+		workflow.Data.Code = f.code
+		return false, f.code, fmt.Errorf("%s failed", f.name)
+	}
+	return true, "", nil
+}
+func (f *fakeSource) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (string, error) {
+	workflow.CacheKey = f.name
+	workflow.ExtractionMetrics.Products += 0.25
+	return "", nil
+}
+func (f *fakeSource) Normalize(workflow *types.CrawlWorkflow, appC *types.Config) {}
+
+// Test_07_InitiateConcurrently - tests sources run against isolated workflows (run with -race)
+func (suite *MergeSuite) Test_07_InitiateConcurrently() {
+	sources := []types.Sources{
+		&fakeSource{name: "AMAZON", code: "AMAZON_THROTTLED"},
+		&fakeSource{name: "WRAPPER", products: []hash{{"sku": "W-1", "name": "Tee"}}},
+		&fakeSource{name: "M101", products: []hash{{"sku": "W-1", "listprice": "10.00"}}},
+		&fakeSource{name: "DIFFBOT", code: "NO_PRODUCT_FROM_SOURCE"},
+	}
+	for run := 0; run < 10; run++ {
+		mg := Merge{
+			Data:            make(map[string][]hash),
+			DataSources:     []string{"AMAZON", "WRAPPER", "M101", "DIFFBOT"},
+			MergePreference: hash{"sku": []string{"WRAPPER", "M101"}, "name": []string{"WRAPPER", "M101"}, "listprice": []string{"M101", "WRAPPER"}},
+		}
+		workflow := &types.CrawlWorkflow{URL: "https://example.com/p/1", DomainInfo: &ctypes.DomainInfo{}, ProductMetrics: types.ProductMetrics{DomainInfo: 0.1, UrlCount: 1}}
+		code, err := mg.InitiateConcurrently(sources, workflow, nil, nil)
+		suite.Assert().Equal("", code)
+		suite.Assert().Nil(err)
+
+		// case: response of the most preferred source which extracted data
+		suite.Assert().Equal("WRAPPER", workflow.Data.ExtractionDataSource)
+		suite.Assert().Equal("WRAPPER", workflow.CacheKey)
+		suite.Assert().Equal(true, workflow.DomainInfo.IsProductUrl)
+		suite.Assert().Equal(1, workflow.Data.Status)
+		suite.Assert().Equal([]hash{{"sku": "W-1", "name": "Tee", "listprice": "10.00"}}, workflow.Data.Products)
+		suite.Assert().Equal(0, workflow.Status)
+		suite.Assert().Nil(workflow.FailureType)
+
+		// case: metrics of every source are added up, metrics collected before the sources ran are kept once
+		suite.Assert().Equal(5, workflow.ProductMetrics.UrlCount)
+		suite.Assert().Equal(2.0, workflow.ProductMetrics.Latency)
+		suite.Assert().Equal(0.1, workflow.ProductMetrics.DomainInfo)
+		suite.Assert().Equal(4, workflow.ExtractionMetrics.Iterations)
+		suite.Assert().Equal(0.5, workflow.ExtractionMetrics.Products)
+		suite.Assert().Equal(0.5, workflow.ExtractionMetrics.Total)
+
+		// case: failures are kept per source, in the order of data sources
+		suite.Assert().Equal([]types.SourceFailure{
+			{Source: "AMAZON", Code: "AMAZON_THROTTLED", Message: "AMAZON failed"},
+			{Source: "DIFFBOT", Code: "NO_PRODUCT_FROM_SOURCE", Message: "DIFFBOT failed"},
+		}, workflow.Data.SourceFailures)
+	}
+
+	// case: failure of the most preferred source when none extracted data
+	mg := Merge{Data: make(map[string][]hash), DataSources: []string{"AMAZON", "DIFFBOT"}}
+	workflow := &types.CrawlWorkflow{DomainInfo: &ctypes.DomainInfo{}}
+	code, err := mg.InitiateConcurrently([]types.Sources{sources[0], sources[3]}, workflow, nil, nil)
+	suite.Assert().Equal("AMAZON_THROTTLED", code)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal(2, len(workflow.Data.SourceFailures))
+	suite.Assert().Equal(0, workflow.Status)
+	suite.Require().NotNil(workflow.FailureType)
+	suite.Assert().Equal("AMAZON_THROTTLED", *workflow.FailureType)
+	suite.Assert().Equal("AMAZON failed", *workflow.FailureMessage)
+	suite.Assert().Equal(2, workflow.ProductMetrics.UrlCount)
+}
+
+// Test_08_PlanCascade - tests ordering of sources against the budget of a crawl
+func (suite *MergeSuite) Test_08_PlanCascade() {
+	previous := estimator
+	defer func() { estimator = previous }()
+	estimator = &sourceEstimator{estimates: make(map[string]SourceEstimate)}
+	estimator.observe("WRAPPER", 8000, true)
+	estimator.observe("M101", 500, true)
+	estimator.observe("AMAZON", 400, false)
+	sources := []string{"WRAPPER", "AMAZON", "M101"}
+
+	// case: listed order without a budget
+	plan := planCascade(sources, types.CascadeBudget{}, nil)
+	suite.Assert().Equal(sources, plan.Sources)
+
+	// case: slow free source goes after a fast paid source when latency is tight
+	plan = planCascade(sources, types.CascadeBudget{Cost: 10, Latency: 3000}, nil)
+	suite.Assert().Equal([]string{"M101", "WRAPPER", "AMAZON"}, plan.Sources)
+
+	// case: cheap sources first when money is tight
+	appC := &types.Config{ConfigData: &types.ConfigData{SourceCosts: map[string]float64{"M101": 5}}}
+	plan = planCascade(sources, types.CascadeBudget{Cost: 5}, appC)
+	suite.Assert().Equal([]string{"WRAPPER", "M101", "AMAZON"}, plan.Sources)
+	plan.Cost = 1
+	suite.Assert().Equal("cost_budget", skipReason(&plan, "M101", 0, appC))
+	suite.Assert().Equal("", skipReason(&plan, "AMAZON", 0, appC))
+
+	// case: rolling estimates
+	estimator.observe("M101", 1500, false)
+	suite.Assert().Equal(SourceEstimate{Latency: 600, SuccessRate: 0.9, Requests: 2}, SourceEstimates()["M101"])
+}
+
+// Test_09_MergeConfig - tests layering of domain and customer merge configs
+func (suite *MergeSuite) Test_09_MergeConfig() {
+	entries := mergeConfigs.entries
+	defer func() { mergeConfigs.entries = entries }()
+	now := time.Now()
+	mergeConfigs.entries = map[string]mergeConfigEntry{
+		"default":                    {config: &types.MergeConfig{MergeMode: "CASCADE", DataSources: []string{"WRAPPER"}}, fetched: now},
+		"customer:acme":              {fetched: now},
+		"example.com":                {config: &types.MergeConfig{DataSources: []string{"WRAPPER", "M101"}, MergePreference: hash{"name": []string{"M101", "WRAPPER"}}}, fetched: now},
+		"customer:acme:example.com":  {config: &types.MergeConfig{RequiredKeys: []string{"listprice"}, MergePreference: hash{"brand": []string{"M101"}}}, fetched: now},
+		"customer:other:example.com": {config: &types.MergeConfig{MergeMode: "MERGE_ALL"}, fetched: now},
+	}
+
+	config, layers := GetMergeConfig("example.com", "acme", nil)
+	suite.Assert().Equal([]string{"default", "example.com", "customer:acme:example.com"}, layers)
+	suite.Assert().Equal("CASCADE", config.MergeMode)
+	suite.Assert().Equal([]string{"WRAPPER", "M101"}, config.DataSources)
+	suite.Assert().Equal([]string{"listprice"}, config.RequiredKeys)
+	suite.Assert().Equal(hash{"name": []string{"M101", "WRAPPER"}, "brand": []string{"M101"}}, config.MergePreference)
+
+	// case: fields missing in the config take the default preference
+	preference := ConfigMergePreference(config.MergePreference, config.DataSources)
+	suite.Assert().Equal([]string{"M101", "WRAPPER"}, preference["name"])
+	suite.Assert().Equal([]string{"WRAPPER", "M101"}, preference["sku"])
+
+	// case: live updates drop the configs of a domain for all customers
+	RemoveMergeConfigFromCache("example.com")
+	suite.Assert().Equal(2, len(mergeConfigs.entries))
+	RemoveMergeConfigFromCache("")
+	suite.Assert().Equal(0, len(mergeConfigs.entries))
+
+	// case: required keys
+	mg := Merge{RequiredKeys: []string{"listprice"}, Data: map[string][]hash{"WRAPPER": {{"name": "Tee"}}}}
+	suite.Assert().Equal(false, mg.isMergeComplete())
+	mg.Data["M101"] = []hash{{"listprice": "10.00"}}
+	suite.Assert().Equal(true, mg.isMergeComplete())
+}
+
+// Test_10_Conflicts - tests detection of fields on which sources disagree
+func (suite *MergeSuite) Test_10_Conflicts() {
+	data := map[string][]hash{
+		"WRAPPER": {{"sku": "W-1", "listprice": "19.99", "listprice_currency": "USD", "brand": "Acme"}},
+		"AMAZON":  {{"listprice": "199.90", "listprice_currency": "usd", "brand": "ACME Corp"}},
+		"M101":    {{"listprice": "20.50", "listprice_currency": "EUR"}},
+	}
+	preference := hash{
+		"sku":                []string{"WRAPPER"},
+		"listprice":          []string{"WRAPPER", "AMAZON", "M101"},
+		"listprice_currency": []string{"WRAPPER", "AMAZON", "M101"},
+	}
+
+	// case: default config reports price and currency conflicts
+	mg := Merge{Data: data, MergePreference: preference}
+	workflow := &types.CrawlWorkflow{}
+	mg.TransformProductsAndMerge(workflow)
+	suite.Assert().Equal(2, len(workflow.Data.Conflicts))
+	price := workflow.Data.Conflicts[0]
+	suite.Assert().Equal("listprice", price.Field)
+	suite.Assert().Equal("WRAPPER", price.Picked)
+	suite.Assert().Equal(hash{"WRAPPER": "19.99", "AMAZON": "199.90", "M101": "20.50"}, price.Values)
+	suite.Assert().InDelta(9.0, price.Deviation, 0.01)
+	suite.Assert().Equal("listprice_currency", workflow.Data.Conflicts[1].Field)
+	suite.Assert().Equal(nil, workflow.Data.Products[0][ConflictsKey])
+	suite.Assert().Equal(map[string]int{"listprice": 1, "listprice_currency": 1}, mg.ConflictCounts)
+
+	// case: configured tolerances, conflicting products flagged for QA
+	mg = Merge{
+		Data:            map[string][]hash{"WRAPPER": data["WRAPPER"], "M101": data["M101"], "AMAZON": {{"brand": "ACME Corp"}}},
+		MergePreference: preference,
+		Conflicts:       &types.ConflictConfig{Numeric: map[string]float64{"listprice": 0.05}, Categorical: []string{"brand"}, Action: ConflictActionFlag},
+	}
+	workflow = &types.CrawlWorkflow{}
+	mg.TransformProductsAndMerge(workflow)
+	suite.Assert().Equal(1, len(workflow.Data.Conflicts))
+	suite.Assert().Equal("brand", workflow.Data.Conflicts[0].Field)
+	suite.Assert().Equal([]string{"brand"}, workflow.Data.Products[0][ConflictsKey])
+
+	// case: tolerances and action loaded from the merge configs of the domain and customer
+	entries := mergeConfigs.entries
+	defer func() { mergeConfigs.entries = entries }()
+	mergeConfigs.entries = make(map[string]mergeConfigEntry)
+	redis := redistest.NewServer()
+	conn := redis.Pool().Get()
+	conn.Do("HSET", MergeConfigRedisKey, "example.com", `{"conflicts": {"numeric": {"listprice": 0.5}, "action": "fail"}}`)
+	conn.Do("HSET", MergeConfigRedisKey, "customer:acme:example.com", `{"conflicts": {"numeric": {"listprice": 0.05}, "categorical": ["brand"], "action": "flag"}}`)
+	conn.Close()
+	config, _ := GetMergeConfig("example.com", "acme", &types.Config{RedisRdstore: redis.Pool()})
+	suite.Require().NotNil(config.Conflicts)
+	suite.Assert().Equal(ConflictActionFlag, config.Conflicts.Action)
+	suite.Assert().Equal([]string{"brand"}, config.Conflicts.Categorical)
+	config, _ = GetMergeConfig("example.com", "", nil)
+	suite.Require().NotNil(config.Conflicts)
+	suite.Assert().Equal(ConflictActionFail, config.Conflicts.Action)
+}
+
+// Test_11_SourceRegistry - tests sources are created by name from the registry
+func (suite *MergeSuite) Test_11_SourceRegistry() {
+	sources.Register(sources.Info{Name: "FAKE_A", Cost: 3}, func(name string) types.Sources {
+		return &fakeSource{name: name, products: []hash{{"sku": "A-1", "name": "Tee"}}}
+	})
+	sources.Register(sources.Info{Name: "FAKE_B"}, func(name string) types.Sources {
+		return &fakeSource{name: name, products: []hash{{"sku": "A-1", "listprice": "10.00"}}}
+	})
+	defer sources.Unregister("FAKE_A")
+	defer sources.Unregister("FAKE_B")
+
+	// case: built-in sources are registered
+	info, ok := sources.Lookup("WRAPPER")
+	suite.Assert().Equal(true, ok)
+	suite.Assert().Equal(true, info.Supervised)
+	suite.Assert().Equal(true, sources.OnlySupervised([]string{"WRAPPER"}))
+	suite.Assert().Equal(false, sources.OnlySupervised([]string{"WRAPPER", "FAKE_A"}))
+	suite.Assert().Equal(3.0, sourceCost("FAKE_A", nil))
+
+	// case: unknown sources are skipped
+	mg := Merge{
+		MergeMode:       "MERGE_ALL",
+		DataSources:     []string{"FAKE_A", "UNKNOWN", "FAKE_B"},
+		MergePreference: hash{"sku": []string{"FAKE_A"}, "name": []string{"FAKE_A"}, "listprice": []string{"FAKE_B"}},
+	}
+	workflow := &types.CrawlWorkflow{}
+	code, err := mg.Merge(workflow, nil, &types.Config{})
+	suite.Assert().Equal("", code)
+	suite.Assert().Nil(err)
+	suite.Assert().Equal([]hash{{"sku": "A-1", "name": "Tee", "listprice": "10.00"}}, workflow.Data.Products)
+}
+
+// Test_12_Confidence - tests values of sources more confident in them are preferred
+func (suite *MergeSuite) Test_12_Confidence() {
+	dfs := map[string]hash{
+		"METADATA": {
+			"name":              "Canvas Bag | Harbor Goods",
+			"description":       "Waxed canvas bag.",
+			"listprice":         "148.00",
+			types.ConfidenceKey: hash{"name": 0.4, "description": 0.4, "listprice": 0.6},
+		},
+		"WRAPPER": {"name": "Canvas Bag", "listprice": "150.00"},
+		"JSONLD":  {"listprice": "148.00"},
+	}
+	preference := hash{
+		"name":        []string{"METADATA", "WRAPPER"},
+		"description": []string{"METADATA", "WRAPPER"},
+		"listprice":   hash{"strategy": "majority", "sources": []interface{}{"METADATA", "WRAPPER", "JSONLD"}},
+	}
+	merged, fieldSources := mergeData(dfs, preference)
+
+	// case: values without a confidence outrank less confident ones regardless of preference
+	suite.Assert().Equal("Canvas Bag", merged["name"])
+	suite.Assert().Equal("WRAPPER", fieldSources["name"])
+	// case: less confident values are used when no other source has one
+	suite.Assert().Equal("Waxed canvas bag.", merged["description"])
+	// case: strategies get candidates in confidence order
+	suite.Assert().Equal("148.00", merged["listprice"])
+	suite.Assert().Equal([]string{"JSONLD", "METADATA"}, fieldSources["listprice"])
+	suite.Assert().Nil(merged[types.ConfidenceKey])
+}
+
+// Test_13_SharedPage - tests sources extracting concurrently from the html of the page download it once (run with -race)
+func (suite *MergeSuite) Test_13_SharedPage() {
+	url := "https://shop.example.com/p/1"
+	proxy := pagetest.NewProxy(map[string]string{url: `<html><head>
+		<meta property="og:type" content="product"><meta property="og:title" content="Desk Lamp">
+		<meta property="product:retailer_item_id" content="LAMP-1"><meta property="product:price:amount" content="24.00">
+		<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Product", "name": "Desk Lamp", "sku": "LAMP-1",
+			"offers": {"@type": "Offer", "price": "24.00", "priceCurrency": "USD"}}</script>
+		</head></html>`})
+	defer proxy.Close()
+	names := []string{"JSONLD", "METADATA", "SPA_STATE"}
+	dataSources := make([]types.Sources, 0, len(names))
+	for _, name := range names {
+		source, err := sources.New(name)
+		suite.Require().Nil(err)
+		dataSources = append(dataSources, source)
+	}
+
+	mg := Merge{Data: make(map[string][]hash), DataSources: names}
+	workflow := pagetest.Workflow(url)
+	code, err := mg.InitiateConcurrently(dataSources, workflow, pagetest.Pipeline{}, proxy.Config())
+	suite.Assert().Equal("", code)
+	suite.Assert().Nil(err)
+	suite.Assert().Equal(1, proxy.Crawls(url))
+	suite.Assert().Equal("LAMP-1", mg.Data["JSONLD"][0]["sku"])
+	suite.Assert().Equal("LAMP-1", mg.Data["METADATA"][0]["sku"])
+	suite.Assert().Equal([]types.SourceFailure{{Source: "SPA_STATE", Code: "SPA_STATE_NOT_FOUND", Message: fmt.Sprintf("%s: no state blob found in the page", url)}}, workflow.Data.SourceFailures)
+	suite.Assert().Equal(1, workflow.ProductMetrics.UrlCount)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestMergeTestSuite(t *testing.T) {
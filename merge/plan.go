@@ -0,0 +1,173 @@
+package merge
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+)
+
+// Estimates of sources which haven't been tried yet
+const (
+	defaultSourceLatency     = 2000.0
+	defaultSourceSuccessRate = 0.8
+	// Weight of the latest request in the rolling estimates
+	estimateSmoothing = 0.1
+	// Success rates are floored so that failing sources are tried last, not never
+	minSourceSuccessRate = 0.05
+)
+
+// SourceEstimate is the rolling latency (milliseconds) and success rate of a data source
+type SourceEstimate struct {
+	Latency     float64 `json:"latency"`
+	SuccessRate float64 `json:"success_rate"`
+	Requests    int     `json:"requests"`
+}
+
+type sourceEstimator struct {
+	mutex     sync.Mutex
+	estimates map[string]SourceEstimate
+}
+
+var estimator = &sourceEstimator{estimates: make(map[string]SourceEstimate)}
+
+// observe updates the estimate of a source with the outcome of a request
+func (e *sourceEstimator) observe(source string, latency float64, success bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	outcome := 0.0
+	if success {
+		outcome = 1
+	}
+	estimate, ok := e.estimates[source]
+	if !ok {
+		estimate = SourceEstimate{Latency: latency, SuccessRate: outcome}
+	} else {
+		estimate.Latency += estimateSmoothing * (latency - estimate.Latency)
+		estimate.SuccessRate += estimateSmoothing * (outcome - estimate.SuccessRate)
+	}
+	estimate.Requests++
+	e.estimates[source] = estimate
+}
+
+func (e *sourceEstimator) get(source string) SourceEstimate {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if estimate, ok := e.estimates[source]; ok {
+		return estimate
+	}
+	return SourceEstimate{Latency: defaultSourceLatency, SuccessRate: defaultSourceSuccessRate}
+}
+
+// SourceEstimates returns the rolling estimates of the data sources tried by this process
+func SourceEstimates() map[string]SourceEstimate {
+	estimator.mutex.Lock()
+	defer estimator.mutex.Unlock()
+	estimates := make(map[string]SourceEstimate, len(estimator.estimates))
+	for source, estimate := range estimator.estimates {
+		estimates[source] = estimate
+	}
+	return estimates
+}
+
+// observeSource records the outcome of a request to a source started at start
+func observeSource(source string, start time.Time, success bool) {
+	estimator.observe(source, float64(time.Since(start))/float64(time.Millisecond), success)
+}
+
+// sourceCost returns the cost of a request to a source from source_costs (config), or as registered by the source
+func sourceCost(source string, appC *types.Config) float64 {
+	if appC != nil && appC.ConfigData != nil {
+		if cost, ok := appC.ConfigData.SourceCosts[source]; ok {
+			return cost
+		}
+	}
+	info, _ := sources.Lookup(source)
+	return info.Cost
+}
+
+// cascadeBudget reads the budget of a crawl from job params source_budget_cost and source_budget_ms
+func cascadeBudget(jobInput *ctypes.Batch) (budget types.CascadeBudget) {
+	if jobInput == nil {
+		return
+	}
+	budget.Cost, _ = cutils.GetFloatKey(jobInput.JobParams, "source_budget_cost")
+	if latency, ok := cutils.GetIntKey(jobInput.JobParams, "source_budget_ms"); ok {
+		budget.Latency = float64(latency)
+	}
+	return
+}
+
+// planCascade orders the sources of a CASCADE merge
+// Without a budget the sources are tried in the order they are listed in. With a budget, sources are
+// ordered by their expected spend (cost and latency relative to the budget) per successful request,
+// ties keep the listed order
+func planCascade(sources []string, budget types.CascadeBudget, appC *types.Config) types.CascadePlan {
+	plan := types.CascadePlan{
+		Sources: append([]string(nil), sources...),
+		Budget:  budget,
+		Tried:   make([]string, 0),
+		Skipped: make(map[string]string),
+	}
+	if budget.Cost <= 0 && budget.Latency <= 0 {
+		return plan
+	}
+
+	score := make(map[string]float64, len(sources))
+	for _, source := range sources {
+		estimate := estimator.get(source)
+		spend := 0.0
+		if budget.Cost > 0 {
+			spend += sourceCost(source, appC) / budget.Cost
+		}
+		if budget.Latency > 0 {
+			spend += estimate.Latency / budget.Latency
+		}
+		successRate := estimate.SuccessRate
+		if successRate < minSourceSuccessRate {
+			successRate = minSourceSuccessRate
+		}
+		score[source] = spend / successRate
+	}
+	sort.SliceStable(plan.Sources, func(i, j int) bool {
+		return score[plan.Sources[i]] < score[plan.Sources[j]]
+	})
+	return plan
+}
+
+// skipReason returns why a source doesn't fit in the budget left, empty when it does
+func skipReason(plan *types.CascadePlan, source string, elapsed float64, appC *types.Config) string {
+	if plan.Budget.Cost > 0 && plan.Cost+sourceCost(source, appC) > plan.Budget.Cost {
+		return "cost_budget"
+	}
+	if plan.Budget.Latency > 0 && elapsed+estimator.get(source).Latency > plan.Budget.Latency {
+		return "latency_budget"
+	}
+	return ""
+}
+
+// PreviewCascadePlan returns the order the sources of a CASCADE merge would be tried in with the budget of a crawl
+func PreviewCascadePlan(sources []string, jobInput *ctypes.Batch, appC *types.Config) types.CascadePlan {
+	return planCascade(sources, cascadeBudget(jobInput), appC)
+}
+
+// orderSources returns the sources in the order of the plan
+func orderSources(dataSources []types.Sources, plan types.CascadePlan) []types.Sources {
+	byName := make(map[string]types.Sources, len(dataSources))
+	for _, ds := range dataSources {
+		if ds != nil {
+			byName[ds.GetName()] = ds
+		}
+	}
+	ordered := make([]types.Sources, 0, len(dataSources))
+	for _, source := range plan.Sources {
+		if ds, ok := byName[source]; ok {
+			ordered = append(ordered, ds)
+		}
+	}
+	return ordered
+}
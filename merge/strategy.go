@@ -0,0 +1,360 @@
+package merge
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// Built-in merge strategies, configured per field in MergePreference:
+// {"listprice": {"strategy": "median", "sources": ["WRAPPER", "M101", "AMAZON"]}}
+// Fields configured with a list (or a single source) use StrategyFirst
+const (
+	StrategyFirst      = "first"
+	StrategyMajority   = "majority"
+	StrategyLongest    = "longest"
+	StrategyUnion      = "union"
+	StrategyMedian     = "median"
+	StrategyMostRecent = "most_recent"
+)
+
+// Candidate is a non empty value of a field supplied by a source
+// Strategies get candidates ordered by confidence, then preference order
+type Candidate struct {
+	Source string
+	Value  interface{}
+	// Crawl time of the product the value was taken from (0 when unknown)
+	Time int64
+	// Confidence of the source in the value (see types.ConfidenceKey)
+	Confidence float64
+}
+
+// Strategy picks the merged value of a field from candidates (in preference order)
+// Returns the sources the value was taken from, no sources when nothing was picked
+type Strategy interface {
+	Merge(field string, candidates []Candidate) (value interface{}, sources []string)
+}
+
+// StrategyFunc adapts a function to the Strategy interface
+type StrategyFunc func(field string, candidates []Candidate) (interface{}, []string)
+
+// Merge calls f
+func (f StrategyFunc) Merge(field string, candidates []Candidate) (interface{}, []string) {
+	return f(field, candidates)
+}
+
+var strategies = map[string]Strategy{
+	StrategyFirst:      StrategyFunc(mergeFirst),
+	StrategyMajority:   StrategyFunc(mergeMajority),
+	StrategyLongest:    StrategyFunc(mergeLongest),
+	StrategyUnion:      StrategyFunc(mergeUnion),
+	StrategyMedian:     StrategyFunc(mergeMedian),
+	StrategyMostRecent: StrategyFunc(mergeMostRecent),
+}
+
+// RegisterStrategy makes a strategy available to MergePreference under name
+// Not safe for concurrent use, register strategies on startup
+func RegisterStrategy(name string, strategy Strategy) {
+	strategies[name] = strategy
+}
+
+// getStrategy returns the strategy registered under name, falling back to StrategyFirst
+func getStrategy(name string) Strategy {
+	if strategy, ok := strategies[name]; ok {
+		return strategy
+	}
+	log.Printf("MERGE_UNKNOWN_STRATEGY: %s, falling back to %s\n", name, StrategyFirst)
+	return strategies[StrategyFirst]
+}
+
+// parseFieldPreference reads the strategy and sources of a field from MergePreference
+// Sources default to all the sources (sorted) when a strategy doesn't list them
+func parseFieldPreference(preference interface{}, dataFromSources map[string]hash) (strategy string, sources []string, ok bool) {
+	switch p := preference.(type) {
+	case []string:
+		return StrategyFirst, p, true
+	case string:
+		return StrategyFirst, []string{p}, true
+	case []interface{}:
+		return StrategyFirst, toStringSlice(p), true
+	case hash:
+		strategy, _ = p["strategy"].(string)
+		if strategy == "" {
+			strategy = StrategyFirst
+		}
+		switch s := p["sources"].(type) {
+		case []string:
+			sources = s
+		case []interface{}:
+			sources = toStringSlice(s)
+		case string:
+			sources = []string{s}
+		default:
+			for source := range dataFromSources {
+				sources = append(sources, source)
+			}
+			sort.Strings(sources)
+		}
+		return strategy, sources, true
+	}
+	return "", nil, false
+}
+
+func toStringSlice(values []interface{}) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		if str, ok := v.(string); ok {
+			s = append(s, str)
+		}
+	}
+	return s
+}
+
+// mergeFirst picks the first non empty value in preference order (default)
+func mergeFirst(field string, candidates []Candidate) (interface{}, []string) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	return candidates[0].Value, []string{candidates[0].Source}
+}
+
+// mergeMajority picks the value supplied by most sources, ties go to the preferred source
+// Strings are compared case insensitively, the preferred spelling is returned
+func mergeMajority(field string, candidates []Candidate) (interface{}, []string) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	order := make([]string, 0)
+	votes := make(map[string][]Candidate)
+	for _, c := range candidates {
+		key := voteKey(c.Value)
+		if _, ok := votes[key]; !ok {
+			order = append(order, key)
+		}
+		votes[key] = append(votes[key], c)
+	}
+	winner := order[0]
+	for _, key := range order[1:] {
+		if len(votes[key]) > len(votes[winner]) {
+			winner = key
+		}
+	}
+	sources := make([]string, 0, len(votes[winner]))
+	for _, c := range votes[winner] {
+		sources = append(sources, c.Source)
+	}
+	return votes[winner][0].Value, sources
+}
+
+// mergeLongest picks the longest string (or list), ties go to the preferred source
+func mergeLongest(field string, candidates []Candidate) (interface{}, []string) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	longest, maxLen := candidates[0], valueLength(candidates[0].Value)
+	for _, c := range candidates[1:] {
+		if l := valueLength(c.Value); l > maxLen {
+			longest, maxLen = c, l
+		}
+	}
+	return longest.Value, []string{longest.Source}
+}
+
+// mergeUnion concatenates lists of all the sources in preference order, dropping duplicates
+func mergeUnion(field string, candidates []Candidate) (interface{}, []string) {
+	union := make([]interface{}, 0)
+	seen := make(map[string]bool)
+	sources := make([]string, 0)
+	for _, c := range candidates {
+		contributed := false
+		for _, item := range toList(c.Value) {
+			key := voteKey(item)
+			if isEmptyValue(item) || seen[key] {
+				continue
+			}
+			seen[key] = true
+			union = append(union, item)
+			contributed = true
+		}
+		if contributed {
+			sources = append(sources, c.Source)
+		}
+	}
+	if len(union) == 0 {
+		return nil, nil
+	}
+	return union, sources
+}
+
+// Values further than outlierMADs scaled median absolute deviations from the median are outliers
+const outlierMADs = 3.0
+
+// mergeMedian picks the value closest to the median of numeric values after rejecting outliers
+// The original value (eg. "80.00") is returned, so that formatting and provenance are preserved
+func mergeMedian(field string, candidates []Candidate) (interface{}, []string) {
+	numeric := make([]Candidate, 0, len(candidates))
+	values := make([]float64, 0, len(candidates))
+	for _, c := range candidates {
+		if v, ok := toNumber(c.Value); ok {
+			numeric = append(numeric, c)
+			values = append(values, v)
+		}
+	}
+	if len(numeric) == 0 {
+		return mergeFirst(field, candidates)
+	}
+
+	m := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - m)
+	}
+	mad := median(deviations) * 1.4826
+	inliers := make([]float64, 0, len(values))
+	for i, v := range values {
+		if deviations[i] <= outlierMADs*mad {
+			inliers = append(inliers, v)
+		}
+	}
+	if len(inliers) < len(values) {
+		log.Printf("MERGE_OUTLIERS_REJECTED: field %s, values %v, kept %v\n", field, values, inliers)
+	}
+	m = median(inliers)
+
+	closest := 0
+	for i, v := range values {
+		if math.Abs(v-m) < math.Abs(values[closest]-m) {
+			closest = i
+		}
+	}
+	return numeric[closest].Value, []string{numeric[closest].Source}
+}
+
+// mergeMostRecent picks the value of the most recently crawled product, ties go to the preferred source
+func mergeMostRecent(field string, candidates []Candidate) (interface{}, []string) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	recent := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.Time > recent.Time {
+			recent = c
+		}
+	}
+	return recent.Value, []string{recent.Source}
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// toNumber parses numbers and numeric strings (eg. "$1,299.00")
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		cleaned := strings.Map(func(r rune) rune {
+			if (r >= '0' && r <= '9') || r == '.' || r == '-' {
+				return r
+			}
+			return -1
+		}, v)
+		f, err := strconv.ParseFloat(cleaned, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func valueLength(value interface{}) int {
+	if s, ok := value.(string); ok {
+		return utf8.RuneCountInString(s)
+	}
+	return len(toList(value))
+}
+
+// toList returns the items of a list, other values are a list of one item
+func toList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list
+	case []hash:
+		list := make([]interface{}, len(v))
+		for i, h := range v {
+			list[i] = h
+		}
+		return list
+	}
+	return []interface{}{value}
+}
+
+// voteKey compares values irrespective of case and surrounding spaces
+func voteKey(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
+
+// fieldConfidence reads the confidence of a field of a product, 1 when the product doesn't report one
+func fieldConfidence(product hash, field string) float64 {
+	var confidence interface{}
+	switch c := product[types.ConfidenceKey].(type) {
+	case hash:
+		confidence = c[field]
+	case map[string]float64:
+		confidence = c[field]
+	}
+	if v, ok := toNumber(confidence); ok {
+		return v
+	}
+	return 1
+}
+
+// byConfidence orders candidates by confidence, keeping the preference order of candidates as confident
+func byConfidence(candidates []Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+}
+
+// productTime reads the crawl time of a product
+func productTime(product hash) int64 {
+	t, _ := toNumber(product["time"])
+	return int64(t)
+}
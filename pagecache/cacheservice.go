@@ -0,0 +1,64 @@
+package pagecache
+
+import (
+	"fmt"
+
+	"github.com/Semantics3/go-crawler/fixture"
+	htmlCache "github.com/Semantics3/sem3-go-crawl-utils/webcache/html"
+)
+
+// Tombstones expire almost immediately, after which the cache service reports a miss
+const tombstoneExpiry int32 = 1
+
+// CacheServiceCache reads and writes pages through the cache service
+type CacheServiceCache struct {
+	host string
+}
+
+// NewCacheServiceCache creates a page cache backed by the cache service at host
+func NewCacheServiceCache(host string) *CacheServiceCache {
+	return &CacheServiceCache{host: host}
+}
+
+// Name of the backend
+func (c *CacheServiceCache) Name() string {
+	return BackendCacheService
+}
+
+// Get downloads the document cached against key
+func (c *CacheServiceCache) Get(key string) (doc []byte, err error) {
+	err = fixture.Replay(fixture.KindCache, fmt.Sprintf("GET %s", key), &doc, func() (err error) {
+		doc, err = htmlCache.DownloadHtmlUsingCacheService(c.host, key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, ErrNotFound
+	}
+	return doc, nil
+}
+
+// Put uploads doc against key
+func (c *CacheServiceCache) Put(key string, doc []byte, expiry int32) error {
+	return fixture.Replay(fixture.KindCache, fmt.Sprintf("PUT %s", key), nil, func() (err error) {
+		_, err = htmlCache.UploadHtmlUsingCacheService(c.host, key, doc, expiry)
+		return err
+	})
+}
+
+// Delete overwrites key with an empty document which expires right away
+// htmlCache only exposes download and upload
+func (c *CacheServiceCache) Delete(key string) error {
+	return c.Put(key, []byte{}, tombstoneExpiry)
+}
+
+// Stat describes the document cached against key
+func (c *CacheServiceCache) Stat(key string) (Stat, error) {
+	doc, err := c.Get(key)
+	if err != nil {
+		return Stat{}, err
+	}
+	return statDocument(key, doc)
+}
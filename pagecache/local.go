@@ -0,0 +1,106 @@
+package pagecache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalCache stores pages as files under a directory, for dev setups and tests
+// Files never expire, documents are aged out by readers (cache_ttl)
+type LocalCache struct {
+	dir string
+}
+
+// NewLocalCache creates a page cache rooted at dir (created if missing)
+func NewLocalCache(dir string) (*LocalCache, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "go-crawler", "page_cache")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create page cache directory %s: %v", dir, err)
+	}
+	return &LocalCache{dir: dir}, nil
+}
+
+// Name of the backend
+func (c *LocalCache) Name() string {
+	return BackendLocal
+}
+
+// Get reads the document cached against key
+func (c *LocalCache) Get(key string) ([]byte, error) {
+	doc, err := ioutil.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return doc, err
+}
+
+// Put writes doc to {dir}/{key}.json
+func (c *LocalCache) Put(key string, doc []byte, expiry int32) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, doc, 0644)
+}
+
+// Delete removes the document cached against key
+func (c *LocalCache) Delete(key string) error {
+	err := os.Remove(c.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Stat describes the document cached against key
+func (c *LocalCache) Stat(key string) (Stat, error) {
+	doc, err := c.Get(key)
+	if err != nil {
+		return Stat{}, err
+	}
+	return statDocument(key, doc)
+}
+
+// List walks the directory for keys starting with prefix
+func (c *LocalCache) List(prefix string, limit int) (keys []string, err error) {
+	keys = make([]string, 0)
+	err = filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		keys = append(keys, key)
+		if limit > 0 && len(keys) >= limit {
+			return errListLimit
+		}
+		return nil
+	})
+	if err == errListLimit {
+		err = nil
+	}
+	return keys, err
+}
+
+var errListLimit = errors.New("list limit reached")
+
+func (c *LocalCache) path(key string) string {
+	key = strings.Replace(strings.TrimLeft(key, "/"), "..", "", -1)
+	return filepath.Join(c.dir, filepath.FromSlash(key)+".json")
+}
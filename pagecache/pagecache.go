@@ -0,0 +1,110 @@
+package pagecache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	s3Cache "github.com/Semantics3/sem3-go-crawl-utils/webcache/s3"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Backends supported by New
+const (
+	BackendCacheService = "cache_service"
+	BackendS3           = "s3"
+	BackendRedis        = "redis"
+	BackendLocal        = "local"
+)
+
+// ErrNotFound is returned when a key is not present in the cache (or has expired)
+var ErrNotFound = errors.New("page not found in cache")
+
+// PageCache stores crawled pages (json encoded web responses) against cache keys
+type PageCache interface {
+	// Name of the backend
+	Name() string
+	// Get reads the document cached against key
+	Get(key string) ([]byte, error)
+	// Put caches doc against key, expiry (seconds) is honoured by backends which support it
+	Put(key string, doc []byte, expiry int32) error
+	// Delete removes the document cached against key
+	Delete(key string) error
+	// Stat describes the document cached against key
+	Stat(key string) (Stat, error)
+}
+
+// Lister is implemented by backends which can enumerate their keys
+// The cache service and s3 clients can't, keys have to be known (or explained) upfront
+type Lister interface {
+	// List returns up to limit keys starting with prefix (all when limit is 0)
+	List(prefix string, limit int) ([]string, error)
+}
+
+// Stat describes a cached document
+type Stat struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+	// Crawl time (unix seconds) of the cached web response
+	Time int64 `json:"time"`
+}
+
+// Config selects the page cache of an environment
+type Config struct {
+	// cache_service (default), s3, redis or local
+	Backend string `json:"backend"`
+	// Root directory of the local backend
+	Dir string `json:"dir,omitempty"`
+	// Prepended to keys in redis
+	KeyPrefix string `json:"key_prefix,omitempty"`
+}
+
+// Deps are clients shared with the rest of the crawler
+type Deps struct {
+	CacheServiceHost string
+	S3Client         *s3Cache.Client
+	Redis            *redis.Pool
+}
+
+// New creates the page cache configured in c (cache service when c is nil)
+func New(c *Config, deps Deps) (PageCache, error) {
+	backend := BackendCacheService
+	if c != nil && c.Backend != "" {
+		backend = strings.ToLower(c.Backend)
+	}
+	switch backend {
+	case BackendCacheService:
+		return NewCacheServiceCache(deps.CacheServiceHost), nil
+	case BackendS3:
+		if deps.S3Client == nil {
+			return nil, fmt.Errorf("s3 client is not available for the s3 page cache")
+		}
+		return NewS3Cache(deps.S3Client), nil
+	case BackendRedis:
+		if deps.Redis == nil {
+			return nil, fmt.Errorf("redis pool is not available for the redis page cache")
+		}
+		return NewRedisCache(deps.Redis, c.KeyPrefix), nil
+	case BackendLocal:
+		return NewLocalCache(c.Dir)
+	}
+	return nil, fmt.Errorf("unsupported page cache backend %s", backend)
+}
+
+// IsWrittenByProxycloud is true when proxycloud writes crawled pages to the cache
+// (cache_key in request policy). Other backends are written by the crawler itself
+func IsWrittenByProxycloud(c PageCache) bool {
+	return c == nil || c.Name() == BackendCacheService
+}
+
+// statDocument reads size and crawl time of a cached document
+func statDocument(key string, doc []byte) (Stat, error) {
+	var d struct {
+		Time int64 `json:"time"`
+	}
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return Stat{}, fmt.Errorf("failed to decode cached document %s: %v", key, err)
+	}
+	return Stat{Key: key, Size: len(doc), Time: d.Time}, nil
+}
@@ -0,0 +1,84 @@
+package pagecache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PageCacheSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupTest - Called before each test
+func (suite *PageCacheSuite) SetupTest() {
+	suite.dir, _ = ioutil.TempDir("", "pagecache")
+}
+
+// TearDownTest - Called after each test
+func (suite *PageCacheSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *PageCacheSuite) Test_01_LocalCacheLifecycle() {
+	cache, err := New(&Config{Backend: "local", Dir: suite.dir}, Deps{})
+	suite.Nil(err)
+	suite.Equal(BackendLocal, cache.Name())
+	suite.False(IsWrittenByProxycloud(cache))
+
+	key := "ce/recrawl/example_com/7be40dccf156bc5e0dc90ec1ab9a85ee"
+	_, err = cache.Get(key)
+	suite.Equal(ErrNotFound, err)
+
+	doc := []byte(`{"url":"https://example.com/p/1","content":"<html></html>","time":1760000000}`)
+	suite.Nil(cache.Put(key, doc, 3600))
+	got, err := cache.Get(key)
+	suite.Nil(err)
+	suite.Equal(doc, got)
+
+	stat, err := cache.Stat(key)
+	suite.Nil(err)
+	suite.Equal(Stat{Key: key, Size: len(doc), Time: 1760000000}, stat)
+
+	suite.Nil(cache.Delete(key))
+	_, err = cache.Stat(key)
+	suite.Equal(ErrNotFound, err)
+	suite.Nil(cache.Delete(key))
+}
+
+func (suite *PageCacheSuite) Test_02_BackendSelection() {
+	cache, err := New(nil, Deps{CacheServiceHost: "localhost:4310"})
+	suite.Nil(err)
+	suite.Equal(BackendCacheService, cache.Name())
+	suite.True(IsWrittenByProxycloud(cache))
+
+	_, err = New(&Config{Backend: "s3"}, Deps{})
+	suite.NotNil(err)
+	_, err = New(&Config{Backend: "redis"}, Deps{})
+	suite.NotNil(err)
+	_, err = New(&Config{Backend: "memcached"}, Deps{})
+	suite.NotNil(err)
+}
+
+func (suite *PageCacheSuite) Test_03_LocalCacheList() {
+	cache, _ := NewLocalCache(suite.dir)
+	doc := []byte(`{"time":1760000000}`)
+	cache.Put("ce/recrawl/example_com/a", doc, 0)
+	cache.Put("ce/recrawl/example_com/b", doc, 0)
+	cache.Put("ce/recrawl/other_com/c", doc, 0)
+
+	keys, err := cache.List("ce/recrawl/example_com/", 0)
+	suite.Nil(err)
+	suite.Equal([]string{"ce/recrawl/example_com/a", "ce/recrawl/example_com/b"}, keys)
+
+	keys, err = cache.List("ce/", 1)
+	suite.Nil(err)
+	suite.Len(keys, 1)
+}
+
+func TestPageCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(PageCacheSuite))
+}
@@ -0,0 +1,102 @@
+package pagecache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisCache stores pages as redis strings which expire with the cache document
+type RedisCache struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// NewRedisCache creates a page cache backed by redis, keys are prefixed with prefix
+func NewRedisCache(pool *redis.Pool, prefix string) *RedisCache {
+	if prefix == "" {
+		prefix = "page_cache:"
+	}
+	return &RedisCache{pool: pool, prefix: prefix}
+}
+
+// Name of the backend
+func (c *RedisCache) Name() string {
+	return BackendRedis
+}
+
+// Get reads the document cached against key
+func (c *RedisCache) Get(key string) ([]byte, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	doc, err := redis.Bytes(conn.Do("GET", c.key(key)))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	return doc, err
+}
+
+// Put caches doc against key, expiring after expiry seconds (never when 0)
+func (c *RedisCache) Put(key string, doc []byte, expiry int32) (err error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	if expiry > 0 {
+		_, err = conn.Do("SET", c.key(key), doc, "EX", expiry)
+	} else {
+		_, err = conn.Do("SET", c.key(key), doc)
+	}
+	return err
+}
+
+// Delete removes the document cached against key
+func (c *RedisCache) Delete(key string) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", c.key(key))
+	return err
+}
+
+// Stat describes the document cached against key
+func (c *RedisCache) Stat(key string) (Stat, error) {
+	doc, err := c.Get(key)
+	if err != nil {
+		return Stat{}, err
+	}
+	return statDocument(key, doc)
+}
+
+// List scans redis for keys starting with prefix
+func (c *RedisCache) List(prefix string, limit int) (keys []string, err error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	keys = make([]string, 0)
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", c.key(prefix)+"*", "COUNT", 1000))
+		if err != nil {
+			return keys, err
+		}
+		var batch []string
+		if _, err = redis.Scan(values, &cursor, &batch); err != nil {
+			return keys, err
+		}
+		for _, key := range batch {
+			keys = append(keys, strings.TrimPrefix(key, c.prefix))
+			if limit > 0 && len(keys) >= limit {
+				return keys, nil
+			}
+		}
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+func (c *RedisCache) key(key string) string {
+	return fmt.Sprintf("%s%s", c.prefix, key)
+}
@@ -0,0 +1,60 @@
+package pagecache
+
+import (
+	htmlCache "github.com/Semantics3/sem3-go-crawl-utils/webcache/html"
+	s3Cache "github.com/Semantics3/sem3-go-crawl-utils/webcache/s3"
+)
+
+// S3Cache stores compressed pages in the html cache bucket
+// S3 has no expiry, documents are aged out by readers (cache_ttl)
+type S3Cache struct {
+	client *s3Cache.Client
+}
+
+// NewS3Cache creates a page cache backed by the s3 client
+func NewS3Cache(client *s3Cache.Client) *S3Cache {
+	return &S3Cache{client: client}
+}
+
+// Name of the backend
+func (c *S3Cache) Name() string {
+	return BackendS3
+}
+
+// Get downloads and decompresses the document cached against key
+func (c *S3Cache) Get(key string) ([]byte, error) {
+	data, err := c.client.DownloadDataFromS3(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+	doc, err := htmlCache.DecompressTextContent(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, ErrNotFound
+	}
+	return doc, nil
+}
+
+// Put compresses and uploads doc against key
+func (c *S3Cache) Put(key string, doc []byte, expiry int32) error {
+	return c.client.UploadData(key, string(htmlCache.CompressTextContent(doc)), false)
+}
+
+// Delete overwrites key with an empty document, which is read back as a miss
+func (c *S3Cache) Delete(key string) error {
+	return c.client.UploadData(key, "", false)
+}
+
+// Stat describes the document cached against key
+func (c *S3Cache) Stat(key string) (Stat, error) {
+	doc, err := c.Get(key)
+	if err != nil {
+		return Stat{}, err
+	}
+	return statDocument(key, doc)
+}
@@ -9,7 +9,10 @@ import (
 	"time"
 
 	"github.com/Semantics3/go-crawler/data"
+	"github.com/Semantics3/go-crawler/fixture"
 	"github.com/Semantics3/go-crawler/merge"
+	"github.com/Semantics3/go-crawler/request"
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 	"github.com/Semantics3/sem3-go-crawl-utils/html"
@@ -17,6 +20,7 @@ import (
 	rdutils "github.com/Semantics3/sem3-go-crawl-utils/rdstore"
 	rh "github.com/Semantics3/sem3-go-crawl-utils/redis"
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
 	"github.com/gomodule/redigo/redis"
 )
 
@@ -76,6 +80,13 @@ func PipelineExecutor(task string, jobInput *ctypes.Batch, pipeline types.Pipeli
 		return workflow
 	}
 
+	// 6a. Resolve geo the workflow is crawled from (pools, headers and cookies)
+	workflow.Geo, code, err = utils.ResolveGeo(workflow, appC)
+	if err != nil {
+		utils.FailWorkflow(task, pipeline, workflow, code, err.Error(), appC)
+		return workflow
+	}
+
 	// 7. Initiate object for product metrics
 	// Product metrics is where we track all url level metrics
 	// What's the time taken for domain/info network call
@@ -98,14 +109,18 @@ func PipelineExecutor(task string, jobInput *ctypes.Batch, pipeline types.Pipeli
 	}
 
 	// 8. Assign request_id
-	utils.AssignRequestId(workflow.JobType, workflow)
+	assignRequestId(workflow, pipeline)
 
 	// 9. Read rdstore data
 	if pipeline.ShouldReadFromRdstore(workflow) {
 		// Product url & parent sku checks have to be performed to avoid reading rdstore data
 		// for category pages during discovery_crawl requests
 		if workflow.DomainInfo.IsProductUrl && workflow.DomainInfo.ParentSku != "" {
-			rdstoreData, err := rdutils.FetchParentSKU(url, siteName, parentSku, appC.ConfigData.RestRdstoreUpdate)
+			var rdstoreData *ctypes.RdstoreParentSKU
+			err := fixture.Replay(fixture.KindRdstore, fmt.Sprintf("GET %s %s", siteName, parentSku), &rdstoreData, func() (err error) {
+				rdstoreData, err = rdutils.FetchParentSKU(url, siteName, parentSku, appC.ConfigData.RestRdstoreUpdate)
+				return err
+			})
 			if err != nil {
 				utils.FailWorkflow(task, pipeline, workflow, "RDSTORE_READ_FAIL", err.Error(), appC)
 				return workflow
@@ -124,6 +139,13 @@ func PipelineExecutor(task string, jobInput *ctypes.Batch, pipeline types.Pipeli
 	workflow.ProductMetrics.DomainInfo = duration
 	log.Printf("PIPELINE_DOMAININFO_TIME: (%s, %s) Retrieving domain info (incl rdstore lookup) took %.2f secs\n", siteName, url, duration)
 
+	// 8.1 Serve cached extraction results within the soft ttl (stale-while-revalidate)
+	if serveStaleWhileRevalidate(task, workflow, pipeline, appC) {
+		workflow.Status = 1
+		utils.PrintCrawlSummary(url, workflow)
+		return workflow
+	}
+
 	// 9. MERGE LOGIC INVOKED HERE
 
 	// 9.1 Create the merge object
@@ -132,6 +154,10 @@ func PipelineExecutor(task string, jobInput *ctypes.Batch, pipeline types.Pipeli
 		DataSources:     workflow.JobParams.DataSources,
 		MergePreference: workflow.JobParams.MergePreference,
 	}
+	if workflow.MergePlan != nil {
+		mergeObj.RequiredKeys = workflow.MergePlan.RequiredKeys
+		mergeObj.Conflicts = workflow.MergePlan.Conflicts
+	}
 
 	// 9.2 Extract data from multiple sources and merge
 	// All supervised, unsupervised & other requests are made here
@@ -143,6 +169,7 @@ func PipelineExecutor(task string, jobInput *ctypes.Batch, pipeline types.Pipeli
 
 	// 10. Print crawl summary
 	workflow.Status = 1
+	markServedFromCache(task, workflow, pipeline, appC)
 	utils.PrintCrawlSummary(url, workflow)
 
 	// 11. Data translation logic
@@ -153,12 +180,20 @@ func PipelineExecutor(task string, jobInput *ctypes.Batch, pipeline types.Pipeli
 	}
 
 	// 14. Execute post crawl ops for different job types
+	// Batches re-extracted from WARC archives only run them when asked to (warc_post_crawl_ops)
+	if request.GetBatchWarcArchive(workflow.JobInput) != nil {
+		if postCrawlOps, _ := cutils.GetIntKey(workflow.JobInput.JobParams, "warc_post_crawl_ops"); postCrawlOps != 1 {
+			log.Printf("PIPELINE_POSTCRAWLOPS_SKIPPED: (%s) re-extracted from warc archive\n", task)
+			return workflow
+		}
+	}
 	code, err = pipeline.PostCrawlOps(task, workflow, appC)
 	workflow.PostCrawlOpsCalled = true
 	if err != nil {
 		utils.FailWorkflow(task, pipeline, workflow, code, err.Error(), appC)
 		return workflow
 	}
+	cacheStaleWhileRevalidate(workflow, pipeline, appC)
 	return workflow
 }
 
@@ -188,35 +223,84 @@ func CrawlURL(workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *type
 }
 
 // handleMissingKeysFromInput - Updates workflow object with default values
-// if any of the important keys are missing
+// if any of the important keys are missing (see ResolveMergePlan)
 func handleMissingKeysFromInput(workflow *types.CrawlWorkflow, appC *types.Config) {
+	plan := ResolveMergePlan(workflow, appC)
+	workflow.MergePlan = &plan
+	workflow.JobParams.MergeMode = plan.MergeMode
+	workflow.JobParams.DataSources = plan.DataSources
+	workflow.JobParams.MergePreference = plan.MergePreference
+}
+
+// ResolveMergePlan - merge mode, data sources and preferences in effect for a crawl and where each of them comes from
+// Params sent in the request win over the domain/customer merge config (redis), which wins over the domain source map and defaults
+func ResolveMergePlan(workflow *types.CrawlWorkflow, appC *types.Config) (plan types.MergePlan) {
+	jobParams := workflow.JobParams
+	config, layers := merge.GetMergeConfig(workflow.DomainInfo.DomainName, jobParams.Customer, appC)
+	plan.Layers = layers
+	plan.Origin = make(map[string]string)
+
 	// If merge mode has not been sent, use CASCADE as the default mode
-	if workflow.JobParams.MergeMode == "" {
-		workflow.JobParams.MergeMode = "CASCADE"
+	switch {
+	case jobParams.MergeMode != "":
+		plan.MergeMode, plan.Origin["merge_mode"] = jobParams.MergeMode, "request"
+	case config.MergeMode != "":
+		plan.MergeMode, plan.Origin["merge_mode"] = config.MergeMode, "merge_config"
+	default:
+		plan.MergeMode, plan.Origin["merge_mode"] = "CASCADE", "default"
 	}
+
 	// If data_sources are sent use given data_source
-	if len(workflow.JobParams.DataSources) > 0 {
-		return
+	switch {
+	case len(jobParams.DataSources) > 0:
+		plan.DataSources, plan.Origin["data_sources"] = jobParams.DataSources, "request"
+	case len(config.DataSources) > 0:
+		plan.DataSources, plan.Origin["data_sources"] = config.DataSources, "merge_config"
+	default:
+		plan.DataSources, plan.Origin["data_sources"] = getDomainDataSources(workflow, appC)
 	}
-	// set default data_source
-	workflow.JobParams.DataSources = []string{"WRAPPER"}
+
+	if len(config.RequiredKeys) > 0 {
+		plan.RequiredKeys, plan.Origin["required_keys"] = config.RequiredKeys, "merge_config"
+	}
+
+	if config.Conflicts != nil {
+		plan.Conflicts, plan.Origin["conflicts"] = config.Conflicts, "merge_config"
+	}
+
+	// Fields missing in the merge config take the default preference (generated while merging without one)
+	switch {
+	case jobParams.MergePreference != nil:
+		plan.MergePreference, plan.Origin["merge_preference"] = jobParams.MergePreference, "request"
+	case config.MergePreference != nil:
+		plan.MergePreference, plan.Origin["merge_preference"] = merge.ConfigMergePreference(config.MergePreference, plan.DataSources), "merge_config"
+	default:
+		plan.Origin["merge_preference"] = "default"
+	}
+	return plan
+}
+
+// getDomainDataSources - data sources of a domain from the redis domain source map of the job type, WRAPPER by default
+func getDomainDataSources(workflow *types.CrawlWorkflow, appC *types.Config) ([]string, string) {
 	// check redis map for data_source for given site and given job_type
 	domainName := workflow.DomainInfo.DomainName
 	// redis key for domain-source map
-	redisKey := getDataSourceMapRedisKey(workflow.JobInput.JobDetails.JobType)
+	var redisKey string
+	if workflow.JobInput != nil {
+		redisKey = getDataSourceMapRedisKey(workflow.JobInput.JobDetails.JobType)
+	}
 	if redisKey == "" {
-		return
+		return []string{"WRAPPER"}, "default"
 	}
 	dataSources := getDomainDataSource(appC.RedisRdstore, redisKey, domainName)
 	if len(dataSources) > 0 {
-		workflow.JobParams.DataSources = dataSources
-		return
+		return dataSources, "domain_source_map"
 	}
 	dataSources = getDomainDataSource(appC.RedisRdstore, redisKey, "default")
 	if len(dataSources) > 0 {
-		workflow.JobParams.DataSources = dataSources
-		return
+		return dataSources, "domain_source_map"
 	}
+	return []string{"WRAPPER"}, "default"
 }
 
 // get redis key for data_source map wrt job type
@@ -239,12 +323,24 @@ func getDomainDataSource(rdStore *redis.Pool, redisKey string, domain string) []
 	return ds
 }
 
+// ValidateDataSources will verify that all the data sources of the crawl are registered (see sources.Register)
+func ValidateDataSources(workflow *types.CrawlWorkflow) (string, error) {
+	for _, name := range workflow.JobParams.DataSources {
+		if _, ok := sources.Lookup(name); !ok {
+			return "UNKNOWN_DATA_SOURCE", fmt.Errorf("data source %s is not registered", name)
+		}
+	}
+	return "", nil
+}
+
 // ValidateDomainInfoForSupervised will verify if domainInfo is valid (exclusive for supervised data source)
-// If WRAPPER is the only data source (supervised extraction)
+// If supervised sources (WRAPPER) are the only data sources (supervised extraction)
 // Sitedetails should be present && Site status should be matching whatever pipeline specifies
 func ValidateDomainInfoForSupervised(workflow *types.CrawlWorkflow, allowedSiteStatus string) (string, error) {
-	extractionMode := utils.GetExtractionMode(workflow.JobParams.DataSources)
-	if extractionMode == "WRAPPER" {
+	if code, err := ValidateDataSources(workflow); err != nil {
+		return code, err
+	}
+	if sources.OnlySupervised(workflow.JobParams.DataSources) {
 		di := workflow.DomainInfo
 		site := workflow.DomainInfo.DomainName
 
@@ -305,9 +401,7 @@ func DefaultPrepareRequestConfig(workflow *types.CrawlWorkflow) (reqConfig types
 		cacheFolder = workflow.JobParams.CacheFolder
 	}
 
-	domainKey := strings.Replace(siteName, ".", "_", -1)
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s", cacheFolder, jobType, domainKey, cacheId)
-	reqConfig.CacheKey = cacheKey
+	reqConfig.CacheKey = utils.ConstructCacheKey(cacheFolder, jobType, siteName, cacheId)
 	reqConfig.CacheFolder = cacheFolder
 
 	if workflow.JobParams.ExtractData == 1 {
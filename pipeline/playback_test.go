@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/Semantics3/go-crawler/fixture"
+	_ "github.com/Semantics3/go-crawler/sources/all"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	playbackURL      = "https://shop.example.com/p/trail-runner-2"
+	playbackFixtures = "testdata/fixtures"
+)
+
+type PlaybackSuite struct {
+	suite.Suite
+	appC *types.Config
+}
+
+// SetupTest - Called before each test
+// Proxycloud and the wrapper service aren't reachable, every interaction is played back from the fixtures
+func (suite *PlaybackSuite) SetupTest() {
+	suite.Require().Nil(fixture.Init(fixture.ModePlayback, playbackFixtures))
+	suite.appC = &types.Config{
+		ConfigData: &types.ConfigData{
+			ProxyRouter:       "proxycloud.invalid:4000",
+			WrapperServiceURI: "wrapper-service.invalid:3000",
+		},
+		StatsManager: &types.StatsManager{CrawlMetricsChannel: make(chan types.CrawlMetrics, 100)},
+	}
+}
+
+// TearDownTest - Called after each test
+func (suite *PlaybackSuite) TearDownTest() {
+	fixture.Init(fixture.ModeOff, "")
+}
+
+func (suite *PlaybackSuite) jobInput(dataSources ...interface{}) *ctypes.Batch {
+	return &ctypes.Batch{
+		JobDetails: ctypes.JobConfig{JobType: "realtimeapi"},
+		JobParams:  map[string]interface{}{"data_sources": dataSources},
+	}
+}
+
+// Test_01_PlaybackCrawl - tests a recorded crawl is extracted again from its fixtures
+func (suite *PlaybackSuite) Test_01_PlaybackCrawl() {
+	workflow := PipelineExecutor(playbackURL, suite.jobInput("JSONLD"), &RealtimeApiPipeline{}, suite.appC, "")
+	suite.Require().Nil(workflow.FailureType, "%v", workflow.FailureMessage)
+	suite.Equal(1, workflow.Status)
+	suite.Equal("shop.example.com", workflow.DomainInfo.DomainName)
+	suite.Equal(200, workflow.WebResponse.Status)
+	suite.Require().Len(workflow.Data.Products, 1)
+	suite.Equal("Acme Trail Runner 2 & Gaiters", workflow.Data.Products[0]["name"])
+	suite.Equal("TR2-0042", workflow.Data.Products[0]["sku"])
+
+	// case: crawls which weren't recorded fail instead of reaching the network
+	workflow = PipelineExecutor("https://shop.example.com/p/unrecorded", suite.jobInput("JSONLD"), &RealtimeApiPipeline{}, suite.appC, "")
+	suite.Equal(0, workflow.Status)
+	suite.Require().NotNil(workflow.FailureMessage)
+	suite.Contains(*workflow.FailureMessage, "FIXTURE_MISS")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPlaybackSuite(t *testing.T) {
+	suite.Run(t, new(PlaybackSuite))
+}
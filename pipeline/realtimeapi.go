@@ -6,8 +6,8 @@ import (
 	"strings"
 
 	"github.com/Semantics3/go-crawler/data"
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
-	"github.com/Semantics3/go-crawler/utils"
 	"github.com/Semantics3/sem3-go-crawl-utils/html"
 	jobutils "github.com/Semantics3/sem3-go-crawl-utils/jobs"
 )
@@ -36,10 +36,12 @@ func (rp *RealtimeApiPipeline) ValidateDomainInfo(workflow *types.CrawlWorkflow)
 	siteName := di.DomainName
 	isProductURL := di.IsProductUrl
 	isSearchURL := di.IsSearchUrl
-	extractionMode := utils.GetExtractionMode(workflow.JobParams.DataSources)
+	if code, err := ValidateDataSources(workflow); err != nil {
+		return code, err
+	}
 
 	if sitedetail != nil {
-		if extractionMode == "WRAPPER" {
+		if sources.OnlySupervised(workflow.JobParams.DataSources) {
 			if jobParams.UseSearchWrapper == 1 {
 				// we also check for the search wrapper here because isSearchUrl is false by default i.e. it will be
 				// false even when no searchUrlFilters are configured in the domain's sitedetail.
@@ -134,8 +136,9 @@ func (rp *RealtimeApiPipeline) PrepareRequestConfig(workflow *types.CrawlWorkflo
 	return reqConfig, code, err
 }
 
+// Pages are read from cache only in stale-while-revalidate mode
 func (rp *RealtimeApiPipeline) ShouldReadFromCache(workflow *types.CrawlWorkflow) bool {
-	return false
+	return swrSoftTTL(workflow, rp) > 0
 }
 
 // GetCacheExpiryTime - time (in seconds) before it gets expired from cache
@@ -0,0 +1,190 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Stale-while-revalidate for realtime requests
+// Job param stale_while_revalidate is a soft ttl (seconds): cached extraction results
+// (or cached pages) younger than it are served right away and refreshed by a crawl in the background
+const (
+	swrJobParam               = "stale_while_revalidate"
+	swrRefreshJobParam        = "swr_refresh"
+	defaultSWRRefreshInterval = 60
+)
+
+// Extraction result cached against the cache key of the page it was extracted from
+type swrDocument struct {
+	URL  string                   `json:"url"`
+	Time int64                    `json:"time"`
+	Data types.ExtractionResponse `json:"data"`
+}
+
+// swrSoftTTL returns the soft ttl of a workflow (0 when stale-while-revalidate is not requested)
+// Refresh crawls never read from cache
+func swrSoftTTL(workflow *types.CrawlWorkflow, pipeline types.Pipeline) int {
+	if _, ok := pipeline.(*RealtimeApiPipeline); !ok || workflow.JobInput == nil {
+		return 0
+	}
+	if isSWRRefresh(workflow) {
+		return 0
+	}
+	ttl, ok := cutils.GetIntKey(workflow.JobInput.JobParams, swrJobParam)
+	if !ok || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func isSWRRefresh(workflow *types.CrawlWorkflow) bool {
+	if workflow.JobInput == nil {
+		return false
+	}
+	refresh, _ := cutils.GetIntKey(workflow.JobInput.JobParams, swrRefreshJobParam)
+	return refresh == 1
+}
+
+// assignRequestId assigns the request_id of a workflow (see utils.AssignRequestId)
+// The request_id is part of the cache key, stale-while-revalidate crawls only use the one sent in job params
+// so that requests (and their refresh crawls) share cached pages and extraction results
+func assignRequestId(workflow *types.CrawlWorkflow, pipeline types.Pipeline) {
+	if swrSoftTTL(workflow, pipeline) == 0 && !isSWRRefresh(workflow) {
+		utils.AssignRequestId(workflow.JobType, workflow)
+		return
+	}
+	workflow.RequestId = workflow.JobParams.RequestId
+}
+
+func swrKey(cacheKey string) string {
+	return fmt.Sprintf("swr/%s", cacheKey)
+}
+
+// serveStaleWhileRevalidate serves the cached extraction result of a workflow within the soft ttl
+// Returns true when the workflow was served from cache, a refresh crawl is triggered in that case
+func serveStaleWhileRevalidate(task string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) bool {
+	softTTL := swrSoftTTL(workflow, pipeline)
+	if softTTL == 0 || appC.PageCache == nil {
+		return false
+	}
+
+	// Pages younger than the soft ttl can be served when there is no cached extraction result
+	if workflow.JobParams.CacheTtl == 0 {
+		workflow.JobParams.CacheTtl = softTTL
+	}
+
+	reqConfig, _, err := pipeline.PrepareRequestConfig(workflow)
+	if err != nil || reqConfig.CacheKey == "" {
+		return false
+	}
+	doc, err := appC.PageCache.Get(swrKey(reqConfig.CacheKey))
+	if err != nil {
+		return false
+	}
+	var cached swrDocument
+	if err = json.Unmarshal(doc, &cached); err != nil {
+		log.Printf("SWR_DECODE_ERROR: (%s) %s: %v\n", workflow.URL, reqConfig.CacheKey, err)
+		return false
+	}
+	age := time.Now().Unix() - cached.Time
+	if age > int64(softTTL) {
+		log.Printf("SWR_EXPIRED: (%s) age %d secs, soft ttl %d secs\n", workflow.URL, age, softTTL)
+		return false
+	}
+
+	workflow.CacheKey = reqConfig.CacheKey
+	workflow.Data = cached.Data
+	workflow.CrawlTime = cached.Time
+	workflow.FromCache = true
+	workflow.Age = age
+	workflow.WebResponse = types.WebResponse{URL: cached.URL, Status: 200, Success: true, FromCache: true, Time: cached.Time}
+	log.Printf("SWR_HIT: (%s) serving extraction result of age %d secs\n", workflow.URL, age)
+
+	refreshStaleWhileRevalidate(task, workflow, pipeline, appC)
+	return true
+}
+
+// markServedFromCache flags workflows whose page was read from cache within the soft ttl
+// and triggers a refresh crawl for them
+func markServedFromCache(task string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) {
+	if swrSoftTTL(workflow, pipeline) == 0 || !workflow.WebResponse.FromCache {
+		return
+	}
+	workflow.FromCache = true
+	workflow.Age = time.Now().Unix() - workflow.CrawlTime
+	refreshStaleWhileRevalidate(task, workflow, pipeline, appC)
+}
+
+// cacheStaleWhileRevalidate caches the extraction result of a successful realtime crawl
+func cacheStaleWhileRevalidate(workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) {
+	if _, ok := pipeline.(*RealtimeApiPipeline); !ok || appC.PageCache == nil || workflow.FromCache || workflow.CacheKey == "" {
+		return
+	}
+	softTTL := swrSoftTTL(workflow, pipeline)
+	if softTTL == 0 && !isSWRRefresh(workflow) {
+		return
+	}
+	doc := swrDocument{URL: workflow.URL, Time: workflow.CrawlTime, Data: workflow.Data}
+	if doc.Time == 0 {
+		doc.Time = time.Now().Unix()
+	}
+	// Keep results for a day, readers decide whether they are fresh enough
+	if err := utils.WriteDataToCache(workflow.URL, appC.PageCache, swrKey(workflow.CacheKey), doc, 24*60*60); err == nil {
+		log.Printf("SWR_CACHED: (%s) %s\n", workflow.URL, swrKey(workflow.CacheKey))
+	}
+}
+
+// refreshStaleWhileRevalidate crawls the workflow again in the background
+// A url is refreshed at most once every swr_refresh_interval seconds (across instances)
+func refreshStaleWhileRevalidate(task string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) {
+	if !acquireSWRRefreshLock(workflow.CacheKey, appC) {
+		log.Printf("SWR_REFRESH_SKIPPED: (%s) refresh already in progress\n", workflow.URL)
+		return
+	}
+
+	jobInput := *workflow.JobInput
+	jobInput.JobParams = make(map[string]interface{}, len(workflow.JobInput.JobParams)+1)
+	for k, v := range workflow.JobInput.JobParams {
+		jobInput.JobParams[k] = v
+	}
+	jobInput.JobParams[swrRefreshJobParam] = 1
+
+	runSWRRefresh(func() {
+		start := time.Now()
+		refreshed := PipelineExecutor(task, &jobInput, pipeline, appC, workflow.QueueName)
+		log.Printf("SWR_REFRESH_END: (%s) status %d, took %.2f secs\n", task, refreshed.Status, utils.ComputeDuration(start))
+	})
+}
+
+// Refresh crawls run in the background
+var runSWRRefresh = func(refresh func()) {
+	go refresh()
+}
+
+func acquireSWRRefreshLock(cacheKey string, appC *types.Config) bool {
+	if appC.RedisCrawl == nil {
+		return true
+	}
+	interval := defaultSWRRefreshInterval
+	if appC.ConfigData.SWRRefreshInterval > 0 {
+		interval = appC.ConfigData.SWRRefreshInterval
+	}
+	conn := appC.RedisCrawl.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", fmt.Sprintf("swr_refresh_%s", cacheKey), 1, "NX", "EX", interval))
+	if err == redis.ErrNil {
+		return false
+	}
+	if err != nil {
+		log.Printf("SWR_REFRESH_LOCK_ERROR: %s: %v\n", cacheKey, err)
+	}
+	return true
+}
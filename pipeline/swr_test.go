@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/tests/redistest"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type SWRSuite struct {
+	suite.Suite
+	redis     *redistest.Server
+	appC      *types.Config
+	pipeline  *RealtimeApiPipeline
+	refreshes int
+	dir       string
+}
+
+// SetupTest - Called before each test
+// Refresh crawls are counted instead of being run
+func (suite *SWRSuite) SetupTest() {
+	suite.redis = redistest.NewServer()
+	dir, err := ioutil.TempDir("", "page_cache")
+	suite.Require().Nil(err)
+	suite.dir = dir
+	cache, _ := pagecache.NewLocalCache(dir)
+	suite.appC = &types.Config{ConfigData: &types.ConfigData{}, PageCache: cache, RedisCrawl: suite.redis.Pool()}
+	suite.pipeline = &RealtimeApiPipeline{}
+	suite.refreshes = 0
+	runSWRRefresh = func(refresh func()) { suite.refreshes++ }
+}
+
+// TearDownTest - Called after each test
+func (suite *SWRSuite) TearDownTest() {
+	runSWRRefresh = func(refresh func()) { go refresh() }
+	os.RemoveAll(suite.dir)
+}
+
+// workflow of a realtime request for the same url, request_id assigned like the executor does
+func (suite *SWRSuite) workflow(jobParams map[string]interface{}) *types.CrawlWorkflow {
+	workflow := &types.CrawlWorkflow{
+		URL:        "https://example.com/p/1",
+		JobType:    "realtimeapi",
+		JobInput:   &ctypes.Batch{JobParams: jobParams, JobDetails: ctypes.JobConfig{JobType: "realtimeapi"}},
+		JobParams:  &ctypes.CrawlJobParams{},
+		DomainInfo: &ctypes.DomainInfo{DomainName: "example.com"},
+	}
+	assignRequestId(workflow, suite.pipeline)
+	return workflow
+}
+
+func (suite *SWRSuite) cacheKey(workflow *types.CrawlWorkflow) string {
+	reqConfig, _, err := suite.pipeline.PrepareRequestConfig(workflow)
+	suite.Require().Nil(err)
+	return reqConfig.CacheKey
+}
+
+// cache stores the extraction result of a refresh crawl crawled age seconds ago
+func (suite *SWRSuite) cache(age int64) {
+	refresh := suite.workflow(map[string]interface{}{swrRefreshJobParam: 1})
+	refresh.CacheKey = suite.cacheKey(refresh)
+	refresh.CrawlTime = time.Now().Unix() - age
+	refresh.Data = types.ExtractionResponse{Status: 1, Products: []map[string]interface{}{{"name": "Desk Lamp"}}}
+	cacheStaleWhileRevalidate(refresh, suite.pipeline, suite.appC)
+}
+
+// Test_01_CacheKeyIsStable - tests stale-while-revalidate requests and refresh crawls share the cache key
+func (suite *SWRSuite) Test_01_CacheKeyIsStable() {
+	swr := suite.workflow(map[string]interface{}{swrJobParam: 300})
+	suite.Equal("", swr.RequestId)
+	key := suite.cacheKey(swr)
+	suite.Equal(key, suite.cacheKey(suite.workflow(map[string]interface{}{swrJobParam: 300})))
+	suite.Equal(key, suite.cacheKey(suite.workflow(map[string]interface{}{swrRefreshJobParam: 1})))
+
+	// case: other realtime requests are never served from cache
+	fresh := suite.workflow(map[string]interface{}{})
+	suite.NotEqual("", fresh.RequestId)
+	suite.NotEqual(key, suite.cacheKey(fresh))
+
+	// case: request_id sent in job params
+	sent := suite.workflow(map[string]interface{}{swrJobParam: 300})
+	sent.JobParams.RequestId = "r1"
+	assignRequestId(sent, suite.pipeline)
+	suite.Equal("r1", sent.RequestId)
+}
+
+// Test_02_HitWithinSoftTTL - tests cached results younger than the soft ttl are served and refreshed
+func (suite *SWRSuite) Test_02_HitWithinSoftTTL() {
+	suite.cache(10)
+	workflow := suite.workflow(map[string]interface{}{swrJobParam: 300})
+	suite.True(serveStaleWhileRevalidate("task", workflow, suite.pipeline, suite.appC))
+	suite.True(workflow.FromCache)
+	suite.True(workflow.WebResponse.FromCache)
+	suite.InDelta(10, workflow.Age, 1)
+	suite.Equal("Desk Lamp", workflow.Data.Products[0]["name"])
+	suite.Equal(1, suite.refreshes)
+	_, locked := suite.redis.Get(fmt.Sprintf("swr_refresh_%s", workflow.CacheKey))
+	suite.True(locked)
+}
+
+// Test_03_MissAfterSoftTTL - tests results older than the soft ttl are crawled again
+func (suite *SWRSuite) Test_03_MissAfterSoftTTL() {
+	suite.cache(600)
+	workflow := suite.workflow(map[string]interface{}{swrJobParam: 300})
+	suite.False(serveStaleWhileRevalidate("task", workflow, suite.pipeline, suite.appC))
+	suite.False(workflow.FromCache)
+	suite.Equal(0, suite.refreshes)
+
+	// case: not requested
+	suite.cache(10)
+	workflow = suite.workflow(map[string]interface{}{})
+	suite.False(serveStaleWhileRevalidate("task", workflow, suite.pipeline, suite.appC))
+}
+
+// Test_04_RefreshUnderLock - tests a url is refreshed once per refresh interval across requests
+func (suite *SWRSuite) Test_04_RefreshUnderLock() {
+	suite.cache(10)
+	for i := 0; i < 3; i++ {
+		suite.True(serveStaleWhileRevalidate("task", suite.workflow(map[string]interface{}{swrJobParam: 300}), suite.pipeline, suite.appC))
+	}
+	suite.Equal(1, suite.refreshes)
+
+	// case: the lock expires after the refresh interval
+	workflow := suite.workflow(map[string]interface{}{swrJobParam: 300})
+	conn := suite.redis.Pool().Get()
+	conn.Do("DEL", fmt.Sprintf("swr_refresh_%s", suite.cacheKey(workflow)))
+	conn.Close()
+	suite.True(serveStaleWhileRevalidate("task", workflow, suite.pipeline, suite.appC))
+	suite.Equal(2, suite.refreshes)
+}
+
+// Test_05_MarkServedFromCache - tests workflows extracted from a cached page are marked and refreshed
+func (suite *SWRSuite) Test_05_MarkServedFromCache() {
+	workflow := suite.workflow(map[string]interface{}{swrJobParam: 300})
+	workflow.CacheKey = suite.cacheKey(workflow)
+	workflow.CrawlTime = time.Now().Unix() - 30
+	workflow.WebResponse = types.WebResponse{Status: 200, FromCache: true}
+	markServedFromCache("task", workflow, suite.pipeline, suite.appC)
+	suite.True(workflow.FromCache)
+	suite.InDelta(30, workflow.Age, 1)
+	suite.Equal(1, suite.refreshes)
+
+	// case: crawled page
+	workflow = suite.workflow(map[string]interface{}{swrJobParam: 300})
+	workflow.WebResponse = types.WebResponse{Status: 200}
+	markServedFromCache("task", workflow, suite.pipeline, suite.appC)
+	suite.False(workflow.FromCache)
+	suite.Equal(int64(0), workflow.Age)
+	suite.Equal(1, suite.refreshes)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSWRSuite(t *testing.T) {
+	suite.Run(t, new(SWRSuite))
+}
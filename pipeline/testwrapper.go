@@ -111,7 +111,7 @@ func (rp *TestWrapperPipeline) PostCrawlOps(task string, workflow *types.CrawlWo
 	overridingHttpStatus := workflow.Data.OverridingWebResponseStatus
 	log.Printf("S3_CACHE_READ_POST: (%s, ttl %d), overridingHttpStatus %d\n", workflow.CacheKey, workflow.JobParams.CacheTtl, overridingHttpStatus)
 
-	utils.ReadDataFromCache(appC.ConfigData.CacheService, workflow.CacheKey, workflow)
+	utils.ReadDataFromCache(appC.PageCache, workflow.CacheKey, workflow)
 
 	// Take the CE response after updating worflow.webResponse from cache service
 	if overridingHttpStatus > 0 {
@@ -0,0 +1,126 @@
+package request
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Semantics3/go-crawler/types"
+	htmlutils "github.com/Semantics3/sem3-go-crawl-utils/html"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+)
+
+// DefaultAjaxCacheTTL is used when ajax_cache_ttl is not configured
+const DefaultAjaxCacheTTL = 30 * time.Second
+
+// AjaxCache shares secondary web responses across workflows of a batch
+// Concurrent requests for the same key wait on a single fetch (singleflight)
+// Only successful responses are retained, and only for a short TTL
+type AjaxCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*ajaxCacheEntry
+}
+
+type ajaxCacheEntry struct {
+	done        chan struct{}
+	webResponse types.WebResponse
+	expiresAt   time.Time
+}
+
+// Caches of batches being executed, looked up by workflows through their job input
+var batchAjaxCaches = struct {
+	sync.Mutex
+	caches map[*ctypes.Batch]*AjaxCache
+}{caches: make(map[*ctypes.Batch]*AjaxCache)}
+
+// NewAjaxCache creates an ajax cache retaining responses for ttl
+func NewAjaxCache(ttl time.Duration) *AjaxCache {
+	return &AjaxCache{
+		ttl:     ttl,
+		entries: make(map[string]*ajaxCacheEntry),
+	}
+}
+
+// Fetch returns the response cached against key or calls fetch exactly once for all concurrent callers
+// hit is true when the response was not fetched by this caller
+func (c *AjaxCache) Fetch(key string, fetch func() types.WebResponse) (webResponse types.WebResponse, hit bool) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		select {
+		case <-e.done:
+			if time.Now().Before(e.expiresAt) {
+				c.mu.Unlock()
+				return e.webResponse, true
+			}
+		default:
+			// Fetch in flight, wait for it to complete
+			c.mu.Unlock()
+			<-e.done
+			return e.webResponse, true
+		}
+	}
+	e := &ajaxCacheEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.webResponse = fetch()
+	e.expiresAt = time.Now().Add(c.ttl)
+	close(e.done)
+
+	// Failures are shared with callers which were waiting, but never retained
+	if !htmlutils.IsSuccess(e.webResponse.Status) {
+		c.mu.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	c.evictExpired()
+	return e.webResponse, false
+}
+
+// Len returns the number of responses held by the cache
+func (c *AjaxCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *AjaxCache) evictExpired() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		select {
+		case <-e.done:
+			if now.After(e.expiresAt) {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
+
+// NewBatchAjaxCache creates an ajax cache shared by all the workflows of a batch
+// Callers must release it once the batch is executed
+func NewBatchAjaxCache(batch *ctypes.Batch, ttl time.Duration) *AjaxCache {
+	c := NewAjaxCache(ttl)
+	batchAjaxCaches.Lock()
+	batchAjaxCaches.caches[batch] = c
+	batchAjaxCaches.Unlock()
+	return c
+}
+
+// GetBatchAjaxCache returns the ajax cache of a batch (nil if the batch has none)
+func GetBatchAjaxCache(batch *ctypes.Batch) *AjaxCache {
+	batchAjaxCaches.Lock()
+	defer batchAjaxCaches.Unlock()
+	return batchAjaxCaches.caches[batch]
+}
+
+// ReleaseBatchAjaxCache drops the ajax cache of a batch
+func ReleaseBatchAjaxCache(batch *ctypes.Batch) {
+	batchAjaxCaches.Lock()
+	delete(batchAjaxCaches.caches, batch)
+	batchAjaxCaches.Unlock()
+}
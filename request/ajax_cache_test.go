@@ -0,0 +1,93 @@
+package request
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type AjaxCacheSuite struct {
+	suite.Suite
+}
+
+func (suite *AjaxCacheSuite) Test_01_ConcurrentFetchesShareOneRequest() {
+	cache := NewAjaxCache(time.Minute)
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func() types.WebResponse {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return types.WebResponse{URL: "https://example.com/price", Status: 200}
+	}
+
+	var wg sync.WaitGroup
+	var hits int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			webResponse, hit := cache.Fetch("|CE/recrawl/example_com/a|", fetch)
+			suite.Equal(200, webResponse.Status)
+			if hit {
+				atomic.AddInt32(&hits, 1)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	suite.Equal(int32(1), fetches)
+	suite.Equal(int32(9), hits)
+
+	_, hit := cache.Fetch("|CE/recrawl/example_com/a|", fetch)
+	suite.True(hit)
+	suite.Equal(int32(1), fetches)
+}
+
+func (suite *AjaxCacheSuite) Test_02_FailuresAreNotRetained() {
+	cache := NewAjaxCache(time.Minute)
+	fetches := 0
+	fetch := func() types.WebResponse {
+		fetches++
+		return types.WebResponse{Status: 503}
+	}
+	_, hit := cache.Fetch("POST|https://example.com/stock|{}", fetch)
+	suite.False(hit)
+	_, hit = cache.Fetch("POST|https://example.com/stock|{}", fetch)
+	suite.False(hit)
+	suite.Equal(2, fetches)
+	suite.Equal(0, cache.Len())
+}
+
+func (suite *AjaxCacheSuite) Test_03_ExpiredResponsesAreFetchedAgain() {
+	cache := NewAjaxCache(10 * time.Millisecond)
+	fetches := 0
+	fetch := func() types.WebResponse {
+		fetches++
+		return types.WebResponse{Status: 200}
+	}
+	cache.Fetch("key", fetch)
+	time.Sleep(20 * time.Millisecond)
+	_, hit := cache.Fetch("key", fetch)
+	suite.False(hit)
+	suite.Equal(2, fetches)
+}
+
+func (suite *AjaxCacheSuite) Test_04_BatchRegistry() {
+	batch := &ctypes.Batch{BatchID: "batch_1"}
+	suite.Nil(GetBatchAjaxCache(batch))
+	cache := NewBatchAjaxCache(batch, time.Minute)
+	suite.Equal(cache, GetBatchAjaxCache(batch))
+	ReleaseBatchAjaxCache(batch)
+	suite.Nil(GetBatchAjaxCache(batch))
+}
+
+func TestAjaxCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(AjaxCacheSuite))
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Semantics3/go-crawler/pagecache"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 	htmlutils "github.com/Semantics3/sem3-go-crawl-utils/html"
@@ -21,6 +22,13 @@ import (
 type pRequest ctypes.WebRequest
 type pResponse ctypes.WebResponse
 
+// proxyRequest extends the proxycloud payload with method and body for non-GET requests
+type proxyRequest struct {
+	pRequest
+	Method  string `json:"method,omitempty"`
+	Payload string `json:"payload,omitempty"`
+}
+
 var crawleraRequestPolicy *regexp.Regexp
 var renderingEngineRegex *regexp.Regexp
 
@@ -29,9 +37,10 @@ var renderingEngineRegex *regexp.Regexp
 // 2. Downloading web page by requesting proxycloud
 // 3. Copying the proxycloud response to crawl workflow response
 // 4. Updating crawl metrics
+// Requests of all methods (GET/POST/PUT/PATCH/DELETE) go through proxycloud
 func GetRequest(url, site, jobType string, config *types.RequestConfig, jobParams *ctypes.CrawlJobParams, appC *types.Config) (webResponse types.WebResponse) {
 
-	var request pRequest
+	var request proxyRequest
 	var response pResponse
 
 	request.Headers = make(map[string]string)
@@ -40,12 +49,18 @@ func GetRequest(url, site, jobType string, config *types.RequestConfig, jobParam
 
 	// 1. Construct request payload
 	request.constructPayload(url, jobType, config, jobParams, appC)
+	if err := request.setMethod(config); err != nil {
+		response.handleError(url, err.Error())
+		response.CopyResponse(url, &webResponse, utils.ComputeDuration(start), config)
+		return
+	}
 
 	// 2. Make request to proxycloud
 	request.fetchPage(&response, appC)
 
-	// 3. Copy response
+	// 3. Copy response and record it when WARC export is enabled
 	response.CopyResponse(url, &webResponse, utils.ComputeDuration(start), config)
+	request.recordWarc(url, &webResponse, config, appC)
 
 	// 4. Update crawl metrics to influx
 	utils.UpdateCrawlMetrics(site, config, &webResponse, jobParams, appC)
@@ -91,6 +106,13 @@ func (request *pRequest) constructPayload(url, jobType string, config *types.Req
 	request.Headers = utils.GetRequestHeaders(config, wrapperBrowser)
 	request.PageTransforms = utils.GetPageTransforms(&config.DomainInfo.Wrapper)
 	request.Pools = utils.GetProxyPools(jobParams, wrapperBrowser)
+	if len(jobParams.Pools) == 0 && config.Geo != nil && len(config.Geo.Pools) > 0 {
+		// Exit nodes in the requested country
+		request.Pools = config.Geo.Pools
+	} else if len(jobParams.Pools) == 0 && config.Session != nil && len(config.Session.Pools) > 0 {
+		// Cookies of a session are only valid on the pool which created them
+		request.Pools = config.Session.Pools
+	}
 	request.Sleep = utils.GetSleepTime(site, jobParams, wrapperBrowser)
 	request.Timeout = utils.GetRequestTimeout(jobParams, wrapperBrowser)
 	request.RequestPolicy = utils.GetRequestPolicy(jobParams, wrapperBrowser)
@@ -130,7 +152,9 @@ func (request *pRequest) constructPayload(url, jobType string, config *types.Req
 	}
 
 	// Add cache config to request policy
-	if config.CacheKey != "" {
+	// Only the cache service is written by proxycloud, which then returns a placeholder instead of the page.
+	// Pages cached in the other backends are written by the crawler from the content returned
+	if config.CacheKey != "" && (appC == nil || pagecache.IsWrittenByProxycloud(appC.PageCache)) {
 		request.RequestPolicy = fmt.Sprintf("%scache_key:%s;cache_expiry:%d;", request.RequestPolicy, config.CacheKey, config.CacheExpiry)
 	}
 	if config.CacheEvent != "" {
@@ -149,9 +173,42 @@ func (request *pRequest) constructPayload(url, jobType string, config *types.Req
 	// utils.PrettyJSON("REQUEST_1202: PAYLOAD: ", request, false)
 }
 
+// Set method and body for non-GET requests
+// JSON (and GraphQL) bodies are sent with a JSON content type unless one is configured
+func (request *proxyRequest) setMethod(config *types.RequestConfig) error {
+	method := strings.ToUpper(strings.TrimSpace(config.Method))
+	switch method {
+	case "", http.MethodGet:
+		return nil
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead:
+	default:
+		return fmt.Errorf("invalid json request: unsupported method %s", config.Method)
+	}
+
+	request.Method = method
+	request.Payload = config.Body
+	body := strings.TrimSpace(config.Body)
+	if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+		// Request headers can be shared with the wrapper browser, never update them in place
+		headers := make(map[string]string, len(request.Headers)+1)
+		hasContentType := false
+		for k, v := range request.Headers {
+			headers[k] = v
+			if strings.EqualFold(k, "Content-Type") {
+				hasContentType = true
+			}
+		}
+		if !hasContentType {
+			headers["Content-Type"] = "application/json"
+		}
+		request.Headers = headers
+	}
+	return nil
+}
+
 // Handle proxycloud (http) request/response
-func (request *pRequest) fetchPage(response *pResponse, appC *types.Config) {
-	log.Printf("PCREQUEST_START: (%s, %s) Request policy %s", request.URL, request.Domain, request.RequestPolicy)
+func (request *proxyRequest) fetchPage(response *pResponse, appC *types.Config) {
+	log.Printf("PCREQUEST_START: (%s, %s) Method %s, Request policy %s", request.URL, request.Domain, request.Method, request.RequestPolicy)
 	payload, err := json.Marshal(request)
 	if err != nil {
 		log.Fatalln(err)
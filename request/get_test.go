@@ -0,0 +1,168 @@
+package request
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/tests/redistest"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type GetRequestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	payloads []map[string]interface{}
+	// Proxycloud response, empty by default
+	response map[string]interface{}
+	appC     *types.Config
+}
+
+// SetupTest - Called before each test
+func (suite *GetRequestSuite) SetupTest() {
+	suite.payloads = nil
+	suite.response = map[string]interface{}{}
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&payload)
+		suite.payloads = append(suite.payloads, payload)
+		json.NewEncoder(w).Encode(suite.response)
+	}))
+	suite.appC = &types.Config{
+		ConfigData:   &types.ConfigData{ProxyRouter: strings.TrimPrefix(suite.server.URL, "http://")},
+		StatsManager: &types.StatsManager{CrawlMetricsChannel: make(chan types.CrawlMetrics, 10)},
+	}
+}
+
+// TearDownTest - Called after each test
+func (suite *GetRequestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *GetRequestSuite) config(method, body string) *types.RequestConfig {
+	return &types.RequestConfig{
+		DomainInfo: &ctypes.DomainInfo{DomainName: "example.com"},
+		JobType:    "recrawl",
+		IsAjax:     true,
+		Cookie:     "uid=lo_2arJ; sid=1:kgKY+nZw==; Path=/; Secure",
+		CacheKey:   "CE/recrawl/example_com/a",
+		Method:     method,
+		Body:       body,
+	}
+}
+
+func (suite *GetRequestSuite) Test_01_GetRequestHasNoMethod() {
+	GetRequest("https://example.com/price", "example.com", "recrawl", suite.config("", ""), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Len(suite.payloads, 1)
+	suite.Nil(suite.payloads[0]["method"])
+	suite.Nil(suite.payloads[0]["payload"])
+}
+
+func (suite *GetRequestSuite) Test_02_NonGetRequestsGoThroughProxycloud() {
+	for _, method := range []string{"post", "PUT", "PATCH", "DELETE"} {
+		GetRequest("https://example.com/graphql", "example.com", "recrawl", suite.config(method, `{"query":"{ product { price } }"}`), &ctypes.CrawlJobParams{}, suite.appC)
+	}
+	suite.Len(suite.payloads, 4)
+	for i, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		payload := suite.payloads[i]
+		suite.Equal(method, payload["method"])
+		suite.Equal(`{"query":"{ product { price } }"}`, payload["payload"])
+		suite.Equal("uid=lo_2arJ;sid=1:kgKY+nZw==;", payload["cookie"])
+		suite.Contains(payload["request_policy"], "cache_key:CE/recrawl/example_com/a;")
+		headers := payload["headers"].(map[string]interface{})
+		suite.Equal("application/json", headers["Content-Type"])
+	}
+}
+
+func (suite *GetRequestSuite) Test_03_UnsupportedMethodIsNotRequested() {
+	webResponse := GetRequest("https://example.com/price", "example.com", "recrawl", suite.config("TRACE", ""), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Len(suite.payloads, 0)
+	suite.Equal(http.StatusBadRequest, webResponse.Status)
+}
+
+func (suite *GetRequestSuite) Test_04_GeoSetsPoolsHeadersAndCookies() {
+	config := suite.config("", "")
+	config.IsAjax = false
+	config.Cookie = ""
+	config.Geo = &types.GeoTarget{
+		Country: "CH",
+		Locale:  "fr-CH",
+		Pools:   []string{"residential_ch"},
+		Headers: map[string]string{"Accept-Language": "fr-CH,fr;q=0.9"},
+		Cookie:  "currency=CHF;",
+	}
+	GetRequest("https://example.com/p/1", "example.com", "recrawl", config, &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Len(suite.payloads, 1)
+	payload := suite.payloads[0]
+	suite.Equal([]interface{}{"residential_ch"}, payload["pools"])
+	suite.Equal("currency=CHF;", payload["cookie"])
+	headers := payload["headers"].(map[string]interface{})
+	suite.Equal("fr-CH,fr;q=0.9", headers["Accept-Language"])
+
+	// Pools sent in job params take precedence over the geo
+	GetRequest("https://example.com/p/1", "example.com", "recrawl", config, &ctypes.CrawlJobParams{Pools: []string{"datacenter"}}, suite.appC)
+	suite.Equal([]interface{}{"datacenter"}, suite.payloads[1]["pools"])
+}
+
+func (suite *GetRequestSuite) Test_05_WrapperHeadersAreNotUpdated() {
+	config := suite.config("POST", `{"sku":"1"}`)
+	config.DomainInfo.Wrapper.Setup.Browser.RequestHeaders = map[string]string{"Accept": "text/html"}
+	config.Session = &types.CrawlSession{ID: "s1", UserAgent: "session-agent"}
+	config.Geo = &types.GeoTarget{Country: "DE", Headers: map[string]string{"Accept-Language": "de-DE"}}
+	GetRequest("https://example.com/p/1", "example.com", "recrawl", config, &ctypes.CrawlJobParams{}, suite.appC)
+	headers := suite.payloads[0]["headers"].(map[string]interface{})
+	suite.Equal("session-agent", headers["User-Agent"])
+	suite.Equal("de-DE", headers["Accept-Language"])
+
+	wrapperBrowser := config.DomainInfo.Wrapper.Setup.Browser
+	suite.Equal(map[string]string{"Accept": "text/html"}, wrapperBrowser.RequestHeaders)
+	// Later crawls of the site, and their cache keys, don't inherit the headers of the session and geo
+	fields := utils.CacheKeyFields("https://example.com/p/1", "example.com", &types.CrawlWorkflow{JobParams: &ctypes.CrawlJobParams{}}, wrapperBrowser)
+	suite.Equal(map[string]string{"Accept": "text/html"}, fields.Headers)
+}
+
+func (suite *GetRequestSuite) Test_06_PageCacheRoundTrip() {
+	html := `<html><body><h1>Desk Lamp</h1></body></html>`
+	suite.response = map[string]interface{}{"content": html, "success": true, "statusCode": 200}
+	dir, err := ioutil.TempDir("", "page_cache")
+	suite.Require().Nil(err)
+	defer os.RemoveAll(dir)
+	local, _ := pagecache.NewLocalCache(dir)
+	backends := []pagecache.PageCache{local, pagecache.NewRedisCache(redistest.NewServer().Pool(), "")}
+	for i, cache := range backends {
+		suite.appC.PageCache = cache
+		config := suite.config("", "")
+		config.IsAjax = false
+		webResponse := GetRequest("https://example.com/p/1", "example.com", "recrawl", config, &ctypes.CrawlJobParams{}, suite.appC)
+		// Pages cached by the crawler are returned by proxycloud, not written by it
+		suite.NotContains(suite.payloads[i]["request_policy"], "cache_key:")
+		suite.Equal(html, webResponse.Content)
+
+		workflow := &types.CrawlWorkflow{URL: "https://example.com/p/1", CacheKey: config.CacheKey, CacheExpiry: 3600, WebResponse: webResponse}
+		utils.WriteWebResponseToCache(workflow, suite.appC)
+		content, err := utils.ReadCachedContent(cache, config.CacheKey)
+		suite.Nil(err, cache.Name())
+		suite.Equal(html, content, cache.Name())
+		suite.False(utils.IsCacheWritten(content))
+	}
+
+	// case: proxycloud writes the cache service and returns a placeholder
+	suite.appC.PageCache = pagecache.NewCacheServiceCache("localhost:0")
+	suite.response["content"] = "CACHE_WRITTEN: 45 bytes"
+	webResponse := GetRequest("https://example.com/p/1", "example.com", "recrawl", suite.config("", ""), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Contains(suite.payloads[2]["request_policy"], "cache_key:CE/recrawl/example_com/a;")
+	suite.True(utils.IsCacheWritten(webResponse.Content))
+	suite.Equal(45, webResponse.ResponseSize)
+}
+
+func TestGetRequestTestSuite(t *testing.T) {
+	suite.Run(t, new(GetRequestSuite))
+}
@@ -2,12 +2,12 @@ package request
 
 import (
 	"bytes"
+	b64 "encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/Semantics3/go-crawler/types"
@@ -21,6 +21,14 @@ import (
 // 2. Handling client retries
 func VisitPage(url string, config *types.RequestConfig, jobParams *ctypes.CrawlJobParams, productMetrics *types.ProductMetrics, appC *types.Config) (webResponse types.WebResponse) {
 
+	// Pages re-extracted from a WARC archive never reach the live site
+	if config.Archive != nil {
+		webResponse, _ = ArchivedWebResponse(url, config.Archive)
+		logMessage := fmt.Sprintf("WARC_ARCHIVE_READ: Url: %s, IsAjax: %t, Status: %d", url, config.IsAjax, webResponse.Status)
+		utils.PrintResponseDetails(webResponse.Status, logMessage)
+		return
+	}
+
 	curAttempt := 1
 
 	// Read max_attempts from job params
@@ -64,17 +72,12 @@ func VisitPage(url string, config *types.RequestConfig, jobParams *ctypes.CrawlJ
 				}
 			}(url, tickerDrone)
 
-			// Request page based on method
-			if config.Method != "" && config.Method == "POST" {
-				webResponse = PostRequest(url, config, jobParams, appC)
-			} else {
-				webResponse = GetRequest(url, productMetrics.Site, productMetrics.JobType, config, jobParams, appC)
-			}
+			// Request page through proxycloud irrespective of the method
+			webResponse = GetRequest(url, productMetrics.Site, productMetrics.JobType, config, jobParams, appC)
 			webResponse.Attempts = (curAttempt - 1)
 
-			// Make sure ajax calls dont run into race conditions while updating the value
-			var m sync.Mutex
-			m.Lock()
+			// productMetrics is owned by the caller, concurrent callers (ajax workers)
+			// must pass their own copy and merge it back
 			if htmlutils.IsTempError(webResponse.Status) {
 				utils.CollectProductMetrics("latency", webResponse.TimeTaken, productMetrics)
 			}
@@ -83,7 +86,6 @@ func VisitPage(url string, config *types.RequestConfig, jobParams *ctypes.CrawlJ
 			} else {
 				utils.CollectProductMetrics("retry_count", 1, productMetrics)
 			}
-			m.Unlock()
 		}
 		if !htmlutils.IsTempError(webResponse.Status) {
 			break
@@ -94,18 +96,47 @@ func VisitPage(url string, config *types.RequestConfig, jobParams *ctypes.CrawlJ
 	return
 }
 
+// Proxycloud response to a screenshot request
+// Screenshot is set (base64) only for screenshot:inline request policies
+type screenshotResponse struct {
+	ctypes.WebResponse
+	Screenshot string `json:"screenshot"`
+}
+
 // GetScreenshot makes a simple request to proxycloud for screenshot
 func GetScreenshot(appC *types.Config, request types.ScreenshotRequest) (err error) {
+	_, err = requestScreenshot(appC, request)
+	return err
+}
+
+// CaptureScreenshot requests a screenshot which is returned inline by proxycloud
+// Request policy must be constructed with utils.GetInlineScreenshotPolicy
+func CaptureScreenshot(appC *types.Config, request types.ScreenshotRequest) (image []byte, err error) {
+	res, err := requestScreenshot(appC, request)
+	if err != nil {
+		return nil, err
+	}
+	if res.Screenshot == "" {
+		return nil, fmt.Errorf("RESPONSE_ERR: no screenshot returned for %s", request.URL)
+	}
+	image, err = b64.StdEncoding.DecodeString(res.Screenshot)
+	if err != nil {
+		return nil, fmt.Errorf("SCREENSHOT_DECODE_ERR: %v", err)
+	}
+	return image, nil
+}
+
+func requestScreenshot(appC *types.Config, request types.ScreenshotRequest) (res screenshotResponse, err error) {
 	log.Printf("PCREQUEST_START: (%s, %s) Request policy %s", request.URL, request.Domain, request.RequestPolicy)
 	payload, err := json.Marshal(request)
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	router := fmt.Sprintf("http://%s/crawl/url", appC.ConfigData.ProxyRouter)
 	req, err := http.NewRequest("POST", router, bytes.NewBuffer(payload))
 	if err != nil {
-		return err
+		return res, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
@@ -116,20 +147,19 @@ func GetScreenshot(appC *types.Config, request types.ScreenshotRequest) (err err
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return res, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("BODY_PARSE_ERR: %v", err)
+		return res, fmt.Errorf("BODY_PARSE_ERR: %v", err)
 	}
-	var res ctypes.WebResponse
 	err = json.Unmarshal(body, &res)
 	if err != nil {
-		return fmt.Errorf("JSON_UNMARSHAL_ERR: %v", err)
+		return res, fmt.Errorf("JSON_UNMARSHAL_ERR: %v", err)
 	}
 	if res.Error != "" || !res.Success {
-		return fmt.Errorf("RESPONSE_ERR: code: %d, message: %s", res.Status, res.Message)
+		return res, fmt.Errorf("RESPONSE_ERR: code: %d, message: %s", res.Status, res.Message)
 	}
-	return nil
+	return res, nil
 }
@@ -0,0 +1,411 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	htmlutils "github.com/Semantics3/sem3-go-crawl-utils/html"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Defaults for session pools, used when session_pool is not configured
+const (
+	defaultSessionPoolSize        = 5
+	defaultSessionTTL             = 1800
+	defaultSessionMaxFailures     = 3
+	sessionPoolKeyExpiryBufferSec = 60
+	// Transactions on a slot changed meanwhile by concurrent workers are retried
+	maxSessionUpdateAttempts = 5
+)
+
+// Sessions of a site are stored in size slots, {pool}_{slot} => hash of the session fields
+// Sessions created from a geo are never handed out to requests of another geo
+func sessionPoolKey(site, geo string) string {
+	if geo != "" {
+		return fmt.Sprintf("crawl_session_pool_%s_%s", site, strings.ToLower(geo))
+	}
+	return fmt.Sprintf("crawl_session_pool_%s", site)
+}
+
+func sessionKey(site, geo string, slot int) string {
+	return fmt.Sprintf("%s_%d", sessionPoolKey(site, geo), slot)
+}
+
+// Sessions are warmed up in the background, off the crawl path
+var warmupAsync = func(warmup func()) {
+	go warmup()
+}
+
+func sessionPoolConfig(appC *types.Config) (size, ttl, maxFailures int, userAgents []string) {
+	size, ttl, maxFailures = defaultSessionPoolSize, defaultSessionTTL, defaultSessionMaxFailures
+	if appC.ConfigData == nil || appC.ConfigData.SessionPool == nil {
+		return
+	}
+	c := appC.ConfigData.SessionPool
+	if c.Size > 0 {
+		size = c.Size
+	}
+	if c.TTL > 0 {
+		ttl = c.TTL
+	}
+	if c.MaxFailures > 0 {
+		maxFailures = c.MaxFailures
+	}
+	return size, ttl, maxFailures, c.UserAgents
+}
+
+// AcquireSession hands out the sessions of the site in rotation
+// A new session is created in a free slot of the pool, slots are claimed in a transaction watching the slot so
+// that concurrent workers never grow the pool past its size. New sessions are warmed up in the background and handed out
+// once ready, the requests made meanwhile rotate through the other sessions
+// Returns nil when the wrapper doesn't use session pools, no session is ready or redis is unavailable,
+// in which case the request is made without a session
+func AcquireSession(site string, domainInfo *ctypes.DomainInfo, geo *types.GeoTarget, jobParams *ctypes.CrawlJobParams, appC *types.Config) *types.CrawlSession {
+	if appC.RedisCrawl == nil || domainInfo == nil || domainInfo.Sitedetail == nil {
+		return nil
+	}
+	size, warmupURL, ok := utils.GetSessionPoolDirective(domainInfo.Wrapper.Setup.Browser)
+	if !ok {
+		return nil
+	}
+	defaultSize, ttl, _, userAgents := sessionPoolConfig(appC)
+	if size <= 0 {
+		size = defaultSize
+	}
+	geoCountry := ""
+	if geo != nil {
+		geoCountry = geo.Country
+	}
+
+	// A slot lost to a concurrent worker is read again
+	for attempt := 0; attempt < 2; attempt++ {
+		sessions, err := getSessions(site, geoCountry, size, ttl, appC)
+		if err != nil {
+			log.Printf("SESSION_POOL_ERROR: Failed to read session pool of %s: %v\n", site, err)
+			return nil
+		}
+
+		free := -1
+		for slot, sess := range sessions {
+			if sess == nil {
+				free = slot
+				break
+			}
+		}
+		if free < 0 {
+			return rotateSession(site, geoCountry, sessions, ttl, appC)
+		}
+
+		now := time.Now().Unix()
+		sess := &types.CrawlSession{
+			ID:        fmt.Sprintf("%s_%d", site, time.Now().UnixNano()),
+			Site:      site,
+			Geo:       geoCountry,
+			Slot:      free,
+			CreatedAt: now,
+			Ready:     warmupURL == "",
+		}
+		if len(userAgents) > 0 {
+			sess.UserAgent = userAgents[free%len(userAgents)]
+		}
+		if claimed, err := claimSlot(sess, ttl, appC); err != nil {
+			log.Printf("SESSION_POOL_ERROR: Failed to claim slot %d of %s: %v\n", free, site, err)
+			return nil
+		} else if !claimed {
+			continue
+		}
+		log.Printf("SESSION_CREATED: SITE: %s, SESSION: %s, SLOT: %d\n", site, sess.ID, free)
+		if sess.Ready {
+			return useSession(sess, appC)
+		}
+		warmupDomainInfo := *domainInfo
+		warmupAsync(func() {
+			warmupSession(sess, warmupURL, &warmupDomainInfo, geo, jobParams, appC)
+		})
+		return rotateSession(site, geoCountry, sessions, ttl, appC)
+	}
+	return nil
+}
+
+// rotateSession hands out the next ready session of the pool
+// The rotation cursor is incremented atomically, so concurrent workers start from different slots
+func rotateSession(site, geo string, sessions []*types.CrawlSession, ttl int, appC *types.Config) *types.CrawlSession {
+	conn := appC.RedisCrawl.Get()
+	cursorKey := sessionPoolKey(site, geo) + "_cursor"
+	cursor, err := redis.Int(conn.Do("INCR", cursorKey))
+	if err == nil {
+		conn.Do("EXPIRE", cursorKey, ttl+sessionPoolKeyExpiryBufferSec)
+	}
+	conn.Close()
+	if err != nil {
+		log.Printf("SESSION_POOL_ERROR: Failed to rotate sessions of %s: %v\n", site, err)
+		return nil
+	}
+	for i := range sessions {
+		if sess := sessions[(cursor+i)%len(sessions)]; sess != nil && sess.Ready {
+			if used := useSession(sess, appC); used != nil {
+				return used
+			}
+		}
+	}
+	return nil
+}
+
+// useSession counts a use of the session, sessions retired meanwhile aren't handed out
+func useSession(sess *types.CrawlSession, appC *types.Config) *types.CrawlSession {
+	now := time.Now().Unix()
+	replies, updated, err := updateSession(sess, appC, func(key string, current *types.CrawlSession) []sessionCommand {
+		return []sessionCommand{{"HINCRBY", redis.Args{key, "uses", 1}}, {"HSET", redis.Args{key, "last_used_at", now}}}
+	})
+	if err != nil {
+		log.Printf("SESSION_POOL_ERROR: Failed to update session %s: %v\n", sess.ID, err)
+	} else if !updated {
+		return nil
+	} else {
+		sess.Uses, _ = redis.Int(replies[0], nil)
+		sess.LastUsedAt = now
+	}
+	log.Printf("SESSION_ACQUIRED: SITE: %s, SESSION: %s, USES: %d, POOLS: %v\n", sess.Site, sess.ID, sess.Uses, sess.Pools)
+	return sess
+}
+
+// ReleaseSession updates the session with the outcome of a request made with it
+// 1. Blocked responses (403, 429) retire the session immediately
+// 2. Temporary errors retire it after max_failures consecutive failures
+// 3. Successful responses reset failures and refresh cookies set by the site
+// Counters are incremented in redis and cookies merged into the stored ones, so that concurrent holders of
+// the session don't overwrite each other
+func ReleaseSession(sess *types.CrawlSession, webResponse types.WebResponse, appC *types.Config) {
+	if sess == nil || appC.RedisCrawl == nil {
+		return
+	}
+	_, _, maxFailures, _ := sessionPoolConfig(appC)
+
+	switch {
+	case webResponse.Status == 403 || webResponse.Status == 429:
+		retireSession(sess, fmt.Sprintf("blocked (%d)", webResponse.Status), appC)
+	case htmlutils.IsSuccess(webResponse.Status):
+		_, _, err := updateSession(sess, appC, func(key string, current *types.CrawlSession) []sessionCommand {
+			commands := []sessionCommand{{"HSET", redis.Args{key, "failures", 0}}}
+			if webResponse.Cookie != "" {
+				commands = append(commands, sessionCommand{"HSET", redis.Args{key, "cookie", utils.MergeCookies(current.Cookie, webResponse.Cookie)}})
+			}
+			return commands
+		})
+		if err != nil {
+			log.Printf("SESSION_POOL_ERROR: Failed to update session %s: %v\n", sess.ID, err)
+		}
+	default:
+		replies, updated, err := updateSession(sess, appC, func(key string, current *types.CrawlSession) []sessionCommand {
+			return []sessionCommand{{"HINCRBY", redis.Args{key, "failures", 1}}}
+		})
+		if err != nil {
+			log.Printf("SESSION_POOL_ERROR: Failed to update session %s: %v\n", sess.ID, err)
+			return
+		}
+		if !updated {
+			return
+		}
+		sess.Failures, _ = redis.Int(replies[0], nil)
+		if sess.Failures >= maxFailures {
+			retireSession(sess, fmt.Sprintf("%d consecutive failures", sess.Failures), appC)
+		}
+	}
+}
+
+// Visit the warm-up url to collect cookies and pin the session to the pool which served it
+// The session is handed out once warmed up, a failed warm-up frees its slot
+func warmupSession(sess *types.CrawlSession, warmupURL string, domainInfo *ctypes.DomainInfo, geo *types.GeoTarget, jobParams *ctypes.CrawlJobParams, appC *types.Config) bool {
+	config := &types.RequestConfig{
+		DomainInfo: domainInfo,
+		JobType:    "session_warmup",
+		Session:    sess,
+		Geo:        geo,
+	}
+	productMetrics := &types.ProductMetrics{Site: sess.Site, JobType: config.JobType}
+	webResponse := VisitPage(warmupURL, config, jobParams, productMetrics, appC)
+	if !htmlutils.IsSuccess(webResponse.Status) {
+		log.Printf("SESSION_WARMUP_FAILED: SITE: %s, URL: %s, STATUS: %d\n", sess.Site, warmupURL, webResponse.Status)
+		retireSession(sess, "warm-up failed", appC)
+		return false
+	}
+	sess.Cookie = utils.NormalizeCookie(webResponse.Cookie)
+	if webResponse.Headers.XNodePool != "" {
+		sess.Pools = []string{webResponse.Headers.XNodePool}
+	}
+	sess.Ready = true
+	pools, _ := json.Marshal(sess.Pools)
+	_, updated, err := updateSession(sess, appC, func(key string, current *types.CrawlSession) []sessionCommand {
+		return []sessionCommand{{"HSET", redis.Args{key, "ready", sess.Ready, "cookie", sess.Cookie, "pools", pools}}}
+	})
+	if err != nil {
+		log.Printf("SESSION_POOL_ERROR: Failed to update session %s: %v\n", sess.ID, err)
+		return false
+	}
+	if !updated {
+		return false
+	}
+	log.Printf("SESSION_WARMUP: SITE: %s, SESSION: %s, POOLS: %v\n", sess.Site, sess.ID, sess.Pools)
+	return true
+}
+
+// getSessions reads the slots of a pool, free slots are nil
+// Sessions which have outlived the ttl are retired, freeing their slot
+func getSessions(site, geo string, size, ttl int, appC *types.Config) ([]*types.CrawlSession, error) {
+	conn := appC.RedisCrawl.Get()
+	defer conn.Close()
+
+	for slot := 0; slot < size; slot++ {
+		conn.Send("HGETALL", sessionKey(site, geo, slot))
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	sessions := make([]*types.CrawlSession, size)
+	expired := make([]*types.CrawlSession, 0)
+	unreadable := make([]interface{}, 0)
+	for slot := range sessions {
+		fields, err := redis.StringMap(conn.Receive())
+		if _, isRedisError := err.(redis.Error); err != nil && !isRedisError {
+			return nil, err
+		}
+		if err == nil && len(fields) == 0 {
+			continue
+		}
+		sess, parseErr := parseSession(fields)
+		if err != nil || parseErr != nil {
+			log.Printf("SESSION_POOL_ERROR: Dropping unreadable session in slot %d of %s: %v %v\n", slot, site, err, parseErr)
+			unreadable = append(unreadable, sessionKey(site, geo, slot))
+			continue
+		}
+		if now-sess.CreatedAt > int64(ttl) {
+			expired = append(expired, sess)
+			continue
+		}
+		sessions[slot] = sess
+	}
+	if len(unreadable) > 0 {
+		conn.Do("DEL", unreadable...)
+	}
+	for _, sess := range expired {
+		retireSession(sess, "expired", appC)
+	}
+	return sessions, nil
+}
+
+// sessionFields are the fields of the hash of a session
+func sessionFields(sess *types.CrawlSession) redis.Args {
+	pools, _ := json.Marshal(sess.Pools)
+	return redis.Args{"id", sess.ID, "site", sess.Site, "geo", sess.Geo, "slot", sess.Slot, "ready", sess.Ready,
+		"cookie", sess.Cookie, "user_agent", sess.UserAgent, "pools", pools, "created_at", sess.CreatedAt,
+		"last_used_at", sess.LastUsedAt, "uses", sess.Uses, "failures", sess.Failures}
+}
+
+func parseSession(fields map[string]string) (*types.CrawlSession, error) {
+	if fields["id"] == "" {
+		return nil, fmt.Errorf("session without id")
+	}
+	sess := &types.CrawlSession{ID: fields["id"], Site: fields["site"], Geo: fields["geo"], Cookie: fields["cookie"], UserAgent: fields["user_agent"]}
+	var err error
+	for field, value := range map[string]*int{"slot": &sess.Slot, "uses": &sess.Uses, "failures": &sess.Failures} {
+		if *value, err = strconv.Atoi(fields[field]); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", field, err)
+		}
+	}
+	for field, value := range map[string]*int64{"created_at": &sess.CreatedAt, "last_used_at": &sess.LastUsedAt} {
+		if *value, err = strconv.ParseInt(fields[field], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", field, err)
+		}
+	}
+	if sess.Ready, err = strconv.ParseBool(fields["ready"]); err != nil {
+		return nil, fmt.Errorf("invalid ready: %v", err)
+	}
+	if pools := fields["pools"]; pools != "" {
+		if err = json.Unmarshal([]byte(pools), &sess.Pools); err != nil {
+			return nil, fmt.Errorf("invalid pools: %v", err)
+		}
+	}
+	return sess, nil
+}
+
+// claimSlot stores a new session in its slot unless a concurrent worker claimed the slot first
+func claimSlot(sess *types.CrawlSession, ttl int, appC *types.Config) (bool, error) {
+	conn := appC.RedisCrawl.Get()
+	defer conn.Close()
+
+	key := sessionKey(sess.Site, sess.Geo, sess.Slot)
+	if _, err := conn.Do("WATCH", key); err != nil {
+		return false, err
+	}
+	if exists, err := redis.Bool(conn.Do("EXISTS", key)); err != nil || exists {
+		conn.Do("UNWATCH")
+		return false, err
+	}
+	conn.Send("MULTI")
+	conn.Send("HSET", redis.Args{key}.AddFlat(sessionFields(sess))...)
+	// Slots expire along with their session
+	conn.Send("EXPIRE", key, ttl+sessionPoolKeyExpiryBufferSec)
+	reply, err := conn.Do("EXEC")
+	return reply != nil, err
+}
+
+type sessionCommand struct {
+	name string
+	args redis.Args
+}
+
+// updateSession runs the commands built by update on the slot of a session in a transaction, update is passed the
+// session as stored. Nothing is run once the slot no longer holds the session (retired, or claimed again by a
+// new session). The transaction is retried when a concurrent worker changed the slot meanwhile
+func updateSession(sess *types.CrawlSession, appC *types.Config, update func(key string, current *types.CrawlSession) []sessionCommand) (replies []interface{}, updated bool, err error) {
+	conn := appC.RedisCrawl.Get()
+	defer conn.Close()
+
+	key := sessionKey(sess.Site, sess.Geo, sess.Slot)
+	for attempt := 0; attempt < maxSessionUpdateAttempts; attempt++ {
+		if _, err = conn.Do("WATCH", key); err != nil {
+			return nil, false, err
+		}
+		fields, err := redis.StringMap(conn.Do("HGETALL", key))
+		if err != nil {
+			conn.Do("UNWATCH")
+			return nil, false, err
+		}
+		current, err := parseSession(fields)
+		if err != nil || current.ID != sess.ID {
+			conn.Do("UNWATCH")
+			return nil, false, nil
+		}
+		conn.Send("MULTI")
+		for _, command := range update(key, current) {
+			conn.Send(command.name, command.args...)
+		}
+		replies, err = redis.Values(conn.Do("EXEC"))
+		if err == redis.ErrNil {
+			continue
+		}
+		return replies, err == nil, err
+	}
+	return nil, false, fmt.Errorf("slot %s kept changing", key)
+}
+
+// retireSession frees the slot of a session
+// The slot is deleted in a transaction only while it still holds the session, a slot claimed again by a new
+// session is kept
+func retireSession(sess *types.CrawlSession, reason string, appC *types.Config) {
+	log.Printf("SESSION_RETIRED: SITE: %s, SESSION: %s, USES: %d, REASON: %s\n", sess.Site, sess.ID, sess.Uses, reason)
+	_, _, err := updateSession(sess, appC, func(key string, current *types.CrawlSession) []sessionCommand {
+		return []sessionCommand{{"DEL", redis.Args{key}}}
+	})
+	if err != nil {
+		log.Printf("SESSION_POOL_ERROR: Failed to retire session %s: %v\n", sess.ID, err)
+	}
+}
@@ -0,0 +1,263 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Semantics3/go-crawler/tests/redistest"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/suite"
+)
+
+const sessionSite = "shop.example.com"
+
+type SessionSuite struct {
+	suite.Suite
+	redis       *redistest.Server
+	proxy       *httptest.Server
+	proxyStatus int
+	appC        *types.Config
+}
+
+// SetupTest - Called before each test
+// Warm-ups run synchronously against a local proxy
+func (suite *SessionSuite) SetupTest() {
+	suite.redis = redistest.NewServer()
+	suite.proxyStatus = 200
+	suite.proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Header().Set("X-Node-Pool", "residential")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"url":        payload["url"],
+			"content":    "<html></html>",
+			"success":    suite.proxyStatus == 200,
+			"statusCode": suite.proxyStatus,
+			"cookie":     "sid=abc; Path=/; Secure",
+		})
+	}))
+	suite.appC = &types.Config{
+		ConfigData: &types.ConfigData{
+			ProxyRouter: strings.TrimPrefix(suite.proxy.URL, "http://"),
+			SessionPool: &types.SessionPoolConfig{TTL: 600, MaxFailures: 2, UserAgents: []string{"ua-0", "ua-1", "ua-2"}},
+		},
+		StatsManager: &types.StatsManager{CrawlMetricsChannel: make(chan types.CrawlMetrics, 100)},
+		RedisCrawl:   suite.redis.Pool(),
+	}
+	warmupAsync = func(warmup func()) { warmup() }
+}
+
+// TearDownTest - Called after each test
+func (suite *SessionSuite) TearDownTest() {
+	suite.proxy.Close()
+	warmupAsync = func(warmup func()) { go warmup() }
+}
+
+func (suite *SessionSuite) domainInfo(directive string) *ctypes.DomainInfo {
+	domainInfo := &ctypes.DomainInfo{DomainName: sessionSite, Sitedetail: &ctypes.Sitedetail{}}
+	domainInfo.Wrapper.Setup.Browser.Cookie = directive
+	return domainInfo
+}
+
+func (suite *SessionSuite) acquire(directive string) *types.CrawlSession {
+	return AcquireSession(sessionSite, suite.domainInfo(directive), nil, &ctypes.CrawlJobParams{MaxAttempts: 1}, suite.appC)
+}
+
+// stored reads the session of a slot from redis
+func (suite *SessionSuite) stored(slot int) *types.CrawlSession {
+	conn := suite.appC.RedisCrawl.Get()
+	defer conn.Close()
+	fields, err := redis.StringMap(conn.Do("HGETALL", sessionKey(sessionSite, "", slot)))
+	suite.Require().Nil(err)
+	if len(fields) == 0 {
+		return nil
+	}
+	sess, err := parseSession(fields)
+	suite.Require().Nil(err)
+	return sess
+}
+
+// store writes a session to its slot
+func (suite *SessionSuite) store(sess *types.CrawlSession) {
+	conn := suite.appC.RedisCrawl.Get()
+	defer conn.Close()
+	_, err := conn.Do("HSET", redis.Args{sessionKey(sess.Site, sess.Geo, sess.Slot)}.AddFlat(sessionFields(sess))...)
+	suite.Require().Nil(err)
+}
+
+// Test_01_PoolNeverExceedsSize - tests concurrent workers claim at most size slots
+func (suite *SessionSuite) Test_01_PoolNeverExceedsSize() {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sess := suite.acquire("SESSION_POOL:3"); sess != nil {
+				mu.Lock()
+				ids[sess.ID] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	suite.LessOrEqual(len(ids), 3)
+	slots := 0
+	for slot := 0; slot < 3; slot++ {
+		if sess := suite.stored(slot); sess != nil {
+			slots++
+			suite.Equal(slot, sess.Slot)
+			suite.Equal(fmt.Sprintf("ua-%d", slot), sess.UserAgent)
+		}
+	}
+	suite.Equal(3, slots)
+	suite.Nil(suite.stored(3))
+}
+
+// Test_02_SessionsRotate - tests a full pool hands out each of its sessions in turn
+func (suite *SessionSuite) Test_02_SessionsRotate() {
+	created := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		sess := suite.acquire("SESSION_POOL:3")
+		suite.Require().NotNil(sess)
+		suite.Equal(i, sess.Slot)
+		created[sess.ID] = true
+	}
+	suite.Len(created, 3)
+
+	handedOut := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		sess := suite.acquire("SESSION_POOL:3")
+		suite.Require().NotNil(sess)
+		handedOut[sess.ID]++
+	}
+	for id := range created {
+		suite.Equal(2, handedOut[id])
+	}
+	suite.Equal(3, suite.stored(0).Uses)
+}
+
+// Test_03_ExpiredSessionsAreReplaced - tests a session older than the ttl frees its slot for a new one
+func (suite *SessionSuite) Test_03_ExpiredSessionsAreReplaced() {
+	suite.store(&types.CrawlSession{ID: "old", Site: sessionSite, Slot: 0, Ready: true, CreatedAt: time.Now().Unix() - 601})
+
+	sess := suite.acquire("SESSION_POOL:1")
+	suite.Require().NotNil(sess)
+	suite.NotEqual("old", sess.ID)
+	suite.Equal(0, sess.Slot)
+	suite.Equal(sess.ID, suite.stored(0).ID)
+}
+
+// Test_04_ReleaseRetiresSessions - tests blocked responses and repeated failures free the slot of the session
+func (suite *SessionSuite) Test_04_ReleaseRetiresSessions() {
+	sess := suite.acquire("SESSION_POOL:2")
+	suite.Require().NotNil(sess)
+	ReleaseSession(sess, types.WebResponse{Status: 200, Cookie: "cart=1; Path=/"}, suite.appC)
+	suite.Equal("cart=1;", suite.stored(0).Cookie)
+
+	// case: blocked
+	ReleaseSession(sess, types.WebResponse{Status: 403}, suite.appC)
+	suite.Nil(suite.stored(0))
+
+	// case: consecutive temp errors
+	sess = suite.acquire("SESSION_POOL:2")
+	suite.Require().NotNil(sess)
+	ReleaseSession(sess, types.WebResponse{Status: 503}, suite.appC)
+	suite.Equal(1, suite.stored(sess.Slot).Failures)
+	ReleaseSession(sess, types.WebResponse{Status: 503}, suite.appC)
+	suite.Nil(suite.stored(sess.Slot))
+
+	// case: a retired session doesn't free a slot claimed again by a new session
+	stale := *sess
+	sess = suite.acquire("SESSION_POOL:2")
+	suite.Require().NotNil(sess)
+	suite.Equal(stale.Slot, sess.Slot)
+	ReleaseSession(&stale, types.WebResponse{Status: 403}, suite.appC)
+	suite.Equal(sess.ID, suite.stored(sess.Slot).ID)
+}
+
+// Test_05_Warmup - tests sessions are handed out once warmed up, and failed warm-ups free their slot
+func (suite *SessionSuite) Test_05_Warmup() {
+	directive := "SESSION_POOL:1;WARMUP:https://shop.example.com/"
+	// The first request goes without a session while it warms up
+	suite.Nil(suite.acquire(directive))
+	stored := suite.stored(0)
+	suite.Require().NotNil(stored)
+	suite.True(stored.Ready)
+	suite.Equal("sid=abc;", stored.Cookie)
+	suite.Equal([]string{"residential"}, stored.Pools)
+
+	sess := suite.acquire(directive)
+	suite.Require().NotNil(sess)
+	suite.Equal(stored.ID, sess.ID)
+
+	// case: failed warm-up
+	ReleaseSession(sess, types.WebResponse{Status: 403}, suite.appC)
+	suite.proxyStatus = 404
+	suite.Nil(suite.acquire(directive))
+	suite.Nil(suite.stored(0))
+
+	// case: sessions warming up aren't handed out
+	warmupAsync = func(warmup func()) {}
+	suite.Nil(suite.acquire(directive))
+	suite.False(suite.stored(0).Ready)
+	suite.Nil(suite.acquire(directive))
+}
+
+// Test_06_ConcurrentReleases - tests concurrent holders of a session don't lose each other's uses and failures
+func (suite *SessionSuite) Test_06_ConcurrentReleases() {
+	suite.appC.ConfigData.SessionPool.MaxFailures = 100
+	sess := suite.acquire("SESSION_POOL:1")
+	suite.Require().NotNil(sess)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			held := suite.acquire("SESSION_POOL:1")
+			suite.NotNil(held)
+			ReleaseSession(held, types.WebResponse{Status: 503}, suite.appC)
+		}()
+	}
+	wg.Wait()
+	stored := suite.stored(0)
+	suite.Equal(sess.ID, stored.ID)
+	suite.Equal(11, stored.Uses)
+	suite.Equal(10, stored.Failures)
+
+	// Successes keep the cookies set by other holders
+	ReleaseSession(sess, types.WebResponse{Status: 200, Cookie: "cart=1;"}, suite.appC)
+	ReleaseSession(sess, types.WebResponse{Status: 200, Cookie: "currency=EUR;"}, suite.appC)
+	stored = suite.stored(0)
+	suite.Equal(0, stored.Failures)
+	suite.Contains(stored.Cookie, "cart=1;")
+	suite.Contains(stored.Cookie, "currency=EUR;")
+}
+
+// Test_07_UnreadableSlotsAreFreed - tests slots holding sessions stored as json by older crawlers are replaced
+func (suite *SessionSuite) Test_07_UnreadableSlotsAreFreed() {
+	value, _ := json.Marshal(&types.CrawlSession{ID: "legacy", Site: sessionSite, Ready: true, CreatedAt: time.Now().Unix()})
+	suite.redis.Set(sessionKey(sessionSite, "", 0), string(value))
+
+	sess := suite.acquire("SESSION_POOL:1")
+	suite.Require().NotNil(sess)
+	suite.NotEqual("legacy", sess.ID)
+	suite.Equal(sess.ID, suite.stored(0).ID)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSessionSuite(t *testing.T) {
+	suite.Run(t, new(SessionSuite))
+}
@@ -0,0 +1,277 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	"github.com/Semantics3/go-crawler/warc"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+)
+
+// Batches being exported to WARC files and batches re-extracted from WARC archives
+var batchWarc = struct {
+	sync.Mutex
+	writers  map[*ctypes.Batch]*warc.Writer
+	archives map[*ctypes.Batch]*warc.Archive
+}{writers: make(map[*ctypes.Batch]*warc.Writer), archives: make(map[*ctypes.Batch]*warc.Archive)}
+
+// IsWarcEnabled checks whether responses of a batch are exported
+// Enabled through job param `warc: 1` or for customers listed in the warc config
+func IsWarcEnabled(batch *ctypes.Batch, appC *types.Config) bool {
+	if appC.WarcStore == nil || batch == nil {
+		return false
+	}
+	// Responses replayed from an archive are not archived again
+	if _, ok := batch.JobParams[WarcInputJobParam]; ok {
+		return false
+	}
+	if enabled, _ := cutils.GetIntKey(batch.JobParams, "warc"); enabled == 1 {
+		return true
+	}
+	customer, _ := cutils.GetStringKey(batch.JobParams, "customer")
+	for _, c := range appC.ConfigData.Warc.Customers {
+		if customer != "" && strings.EqualFold(c, customer) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBatchWarcWriter creates a WARC writer shared by all the workflows of a batch (nil when export is disabled)
+// Files are named {customer}/{YYYYMMDD}/{job type}-{batch id}-{seq}.warc.gz
+// Callers must release it once the batch is executed, which writes out the last file
+func NewBatchWarcWriter(batch *ctypes.Batch, jobType string, appC *types.Config) *warc.Writer {
+	if !IsWarcEnabled(batch, appC) {
+		return nil
+	}
+	customer, _ := cutils.GetStringKey(batch.JobParams, "customer")
+	if customer == "" {
+		customer = "sem3"
+	}
+	batchID := batch.BatchID
+	if batchID == "" {
+		batchID = fmt.Sprintf("%d", time.Now().Unix())
+	}
+	prefix := fmt.Sprintf("%s/%s/%s-%s", strings.ToLower(customer), time.Now().UTC().Format("20060102"), jobType, batchID)
+
+	w := warc.NewWriter(appC.WarcStore, prefix, appC.ConfigData.Warc.MaxFileSize)
+	batchWarc.Lock()
+	batchWarc.writers[batch] = w
+	batchWarc.Unlock()
+	return w
+}
+
+// GetBatchWarcWriter returns the WARC writer of a batch (nil if the batch has none)
+func GetBatchWarcWriter(batch *ctypes.Batch) *warc.Writer {
+	batchWarc.Lock()
+	defer batchWarc.Unlock()
+	return batchWarc.writers[batch]
+}
+
+// ReleaseBatchWarcWriter drops the WARC writer of a batch after writing out its last file
+func ReleaseBatchWarcWriter(batch *ctypes.Batch) {
+	batchWarc.Lock()
+	w := batchWarc.writers[batch]
+	delete(batchWarc.writers, batch)
+	batchWarc.Unlock()
+
+	if w == nil {
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("WARC_WRITE_FAILED: (BatchId %s) %v\n", batch.BatchID, err)
+	}
+}
+
+// WarcInputJobParam lists the WARC files a batch is re-extracted from
+// Entries are local paths or keys in warc storage
+const WarcInputJobParam = "warc_input"
+
+// NewBatchWarcArchive loads the WARC files listed in the warc_input job param of a batch (nil when not set)
+// Pages of the batch are then read from the archive instead of the live site
+// Callers must release it once the batch is executed
+func NewBatchWarcArchive(batch *ctypes.Batch, appC *types.Config) (*warc.Archive, error) {
+	locations := warcInputLocations(batch)
+	if len(locations) == 0 {
+		return nil, nil
+	}
+	archive, err := LoadWarcArchive(locations, appC)
+	if err != nil {
+		return nil, err
+	}
+	batchWarc.Lock()
+	batchWarc.archives[batch] = archive
+	batchWarc.Unlock()
+	return archive, nil
+}
+
+// GetBatchWarcArchive returns the WARC archive a batch is re-extracted from (nil if the batch has none)
+func GetBatchWarcArchive(batch *ctypes.Batch) *warc.Archive {
+	batchWarc.Lock()
+	defer batchWarc.Unlock()
+	return batchWarc.archives[batch]
+}
+
+// ReleaseBatchWarcArchive drops the WARC archive of a batch
+func ReleaseBatchWarcArchive(batch *ctypes.Batch) {
+	batchWarc.Lock()
+	delete(batchWarc.archives, batch)
+	batchWarc.Unlock()
+}
+
+// warc_input is either a single location or a list of them
+func warcInputLocations(batch *ctypes.Batch) (locations []string) {
+	if batch == nil {
+		return nil
+	}
+	switch input := batch.JobParams[WarcInputJobParam].(type) {
+	case string:
+		locations = strings.Split(input, ",")
+	case []string:
+		locations = input
+	case []interface{}:
+		for _, location := range input {
+			if l, ok := location.(string); ok {
+				locations = append(locations, l)
+			}
+		}
+	}
+	return locations
+}
+
+// LoadWarcArchive reads WARC files from local paths (or file:// urls), falling back to warc storage
+func LoadWarcArchive(locations []string, appC *types.Config) (*warc.Archive, error) {
+	archive := warc.NewArchive()
+	for _, location := range locations {
+		location = strings.TrimSpace(location)
+		if location == "" {
+			continue
+		}
+		path := strings.TrimPrefix(location, "file://")
+		var data []byte
+		var err error
+		if _, statErr := os.Stat(path); statErr == nil {
+			data, err = ioutil.ReadFile(path)
+		} else if appC.WarcStore != nil {
+			data, err = appC.WarcStore.Get(location)
+		} else {
+			err = statErr
+		}
+		if err != nil {
+			return nil, cutils.PrintErr("WARC_READ_FAILED", fmt.Sprintf("failed to read %s", location), err)
+		}
+		if err = archive.Read(bytes.NewReader(data)); err != nil {
+			return nil, cutils.PrintErr("WARC_READ_FAILED", fmt.Sprintf("failed to parse %s", location), err)
+		}
+	}
+	log.Printf("WARC_ARCHIVE_LOADED: %d files, %d urls\n", len(locations), archive.Len())
+	return archive, nil
+}
+
+// ArchivedWebResponse returns the archived response of url as a cached web response
+func ArchivedWebResponse(url string, archive *warc.Archive) (webResponse types.WebResponse, found bool) {
+	response, found := archive.Get(url)
+	if !found {
+		webResponse.URL = url
+		webResponse.Redirect = url
+		webResponse.Status = http.StatusNotFound
+		webResponse.Error = fmt.Sprintf("%s not found in warc archive", url)
+		return webResponse, false
+	}
+	// Archives written before pages were read back from the cache service hold the CACHE_WRITTEN placeholder
+	if utils.IsCacheWritten(response.Content) {
+		webResponse.URL = url
+		webResponse.Redirect = url
+		webResponse.Status = http.StatusNotFound
+		webResponse.Error = fmt.Sprintf("%s was archived without its content", url)
+		return webResponse, false
+	}
+	webResponse = types.WebResponse{
+		URL:          response.URL,
+		Redirect:     response.URL,
+		Content:      response.Content,
+		Time:         response.Date.Unix(),
+		Cookie:       response.Headers["Set-Cookie"],
+		FromCache:    true,
+		Success:      response.Status < 400,
+		ResponseSize: len(response.Content),
+		Status:       response.Status,
+		WarcRecordID: response.RecordID,
+	}
+	webResponse.Headers.XNodeIp = response.Headers["X-Node-Ip"]
+	webResponse.Headers.XNodePool = response.Headers["X-Node-Pool"]
+	webResponse.Headers.XRenderIp = response.Headers["X-Render-Ip"]
+	webResponse.Headers.XRenderPool = response.Headers["X-Render-Pool"]
+	webResponse.Headers.ContentLength = len(response.Content)
+	return webResponse, true
+}
+
+// Record the proxycloud request and response as WARC records
+func (request *proxyRequest) recordWarc(url string, webResponse *types.WebResponse, config *types.RequestConfig, appC *types.Config) {
+	if config.Warc == nil {
+		return
+	}
+
+	// Pages written to the cache service by proxycloud are read back from it, placeholders are never archived
+	content := webResponse.Content
+	if utils.IsCacheWritten(content) {
+		var cache pagecache.PageCache
+		if appC != nil {
+			cache = appC.PageCache
+		}
+		var err error
+		if content, err = utils.ReadCachedContent(cache, config.CacheKey); err != nil {
+			log.Printf("WARC_CONTENT_UNAVAILABLE: (%s) %s: %v\n", url, config.CacheKey, err)
+			return
+		}
+	}
+
+	requestHeaders := make(map[string]string, len(request.Headers)+1)
+	for name, value := range request.Headers {
+		requestHeaders[name] = value
+	}
+	// Cookie directives (eg. SESSION:2;) are handled by proxycloud and are not request headers
+	if strings.Contains(request.Cookie, "=") {
+		requestHeaders["Cookie"] = request.Cookie
+	}
+
+	// Proxy and render nodes reported by proxycloud
+	responseHeaders := make(map[string]string)
+	h := webResponse.Headers
+	for name, value := range map[string]string{"X-Node-Ip": h.XNodeIp, "X-Node-Pool": h.XNodePool, "X-Render-Ip": h.XRenderIp, "X-Render-Pool": h.XRenderPool} {
+		if value != "" {
+			responseHeaders[name] = value
+		}
+	}
+	if webResponse.Cookie != "" {
+		responseHeaders["Set-Cookie"] = webResponse.Cookie
+	}
+
+	record, err := config.Warc.WriteExchange(warc.Exchange{
+		URL:             url,
+		Method:          request.Method,
+		RequestHeaders:  requestHeaders,
+		RequestBody:     request.Payload,
+		Status:          webResponse.Status,
+		ResponseHeaders: responseHeaders,
+		Content:         content,
+	})
+	if err != nil {
+		log.Printf("WARC_WRITE_FAILED: (%s) %v\n", url, err)
+		return
+	}
+	webResponse.WarcRecordID = record.ID
+	webResponse.WarcFile = record.File
+	log.Printf("WARC_RECORD_WRITTEN: (%s) %s in %s\n", url, record.ID, record.File)
+}
@@ -0,0 +1,225 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/storage"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/warc"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+var placeholderRegex = regexp.MustCompile(`CACHE_WRITTEN: \d+ bytes`)
+
+var cacheKeyRegex = regexp.MustCompile(`cache_key:([^;]+);`)
+
+// cacheService is an in-memory cache service, written by the proxy like proxycloud does
+type cacheService struct {
+	mu   sync.Mutex
+	docs map[string][]byte
+}
+
+func (c *cacheService) Name() string {
+	return pagecache.BackendCacheService
+}
+
+func (c *cacheService) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[key]
+	if !ok {
+		return nil, pagecache.ErrNotFound
+	}
+	return doc, nil
+}
+
+func (c *cacheService) Put(key string, doc []byte, expiry int32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs[key] = doc
+	return nil
+}
+
+func (c *cacheService) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.docs, key)
+	return nil
+}
+
+func (c *cacheService) Stat(key string) (pagecache.Stat, error) {
+	return pagecache.Stat{}, fmt.Errorf("not implemented")
+}
+
+type WarcSuite struct {
+	suite.Suite
+	proxy *httptest.Server
+	cache *cacheService
+	store *storage.LocalStore
+	// Pages of the site by url
+	pages map[string]string
+	// Pages the proxy fails to write to the cache service
+	lost map[string]bool
+	appC *types.Config
+	dir  string
+}
+
+// SetupTest - Called before each test
+// The proxy writes pages to the cache service and returns the placeholder when a cache key is sent
+func (suite *WarcSuite) SetupTest() {
+	suite.cache = &cacheService{docs: make(map[string][]byte)}
+	dir, err := ioutil.TempDir("", "warc")
+	suite.Require().Nil(err)
+	suite.dir = dir
+	suite.store, _ = storage.NewLocalStore(filepath.Join(dir, "store"), "")
+	suite.pages = map[string]string{
+		"https://shop.example.com/p/1":         `<html><body><h1>Desk Lamp</h1></body></html>`,
+		"https://shop.example.com/api/price/1": `{"price": "24.00"}`,
+	}
+	suite.lost = make(map[string]bool)
+	suite.proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&payload)
+		url, _ := payload["url"].(string)
+		policy, _ := payload["request_policy"].(string)
+		content, ok := suite.pages[url]
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusNotFound
+		}
+		if match := cacheKeyRegex.FindStringSubmatch(policy); match != nil && ok {
+			if !suite.lost[url] {
+				doc, _ := json.Marshal(ctypes.WebResponse{URL: url, Content: content, Status: status, Success: true})
+				suite.cache.Put(match[1], doc, 0)
+			}
+			content = fmt.Sprintf("CACHE_WRITTEN: %d bytes", len(content))
+		}
+		w.Header().Set("X-Node-Pool", "residential")
+		json.NewEncoder(w).Encode(map[string]interface{}{"url": url, "content": content, "success": ok, "statusCode": status})
+	}))
+	suite.appC = &types.Config{
+		ConfigData:   &types.ConfigData{ProxyRouter: strings.TrimPrefix(suite.proxy.URL, "http://")},
+		StatsManager: &types.StatsManager{CrawlMetricsChannel: make(chan types.CrawlMetrics, 100)},
+		PageCache:    suite.cache,
+		WarcStore:    suite.store,
+	}
+}
+
+// TearDownTest - Called after each test
+func (suite *WarcSuite) TearDownTest() {
+	suite.proxy.Close()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *WarcSuite) config(w *warc.Writer, isAjax bool, cacheKey string) *types.RequestConfig {
+	return &types.RequestConfig{
+		DomainInfo: &ctypes.DomainInfo{DomainName: "shop.example.com"},
+		JobType:    "recrawl",
+		IsAjax:     isAjax,
+		CacheKey:   cacheKey,
+		Warc:       w,
+	}
+}
+
+// archived reads back the WARC file of a record
+func (suite *WarcSuite) archived(file string) *warc.Archive {
+	data, err := suite.store.Get(file)
+	suite.Require().Nil(err)
+	archive := warc.NewArchive()
+	suite.Require().Nil(archive.Read(strings.NewReader(string(data))))
+	return archive
+}
+
+// Test_01_RecordsPageContent - tests pages written to the cache service are archived, not their placeholder
+func (suite *WarcSuite) Test_01_RecordsPageContent() {
+	w := warc.NewWriter(suite.store, "sem3/test", 0)
+	page := GetRequest("https://shop.example.com/p/1", "shop.example.com", "recrawl", suite.config(w, false, "ce/recrawl/shop_example_com/p1"), &ctypes.CrawlJobParams{}, suite.appC)
+	ajax := GetRequest("https://shop.example.com/api/price/1", "shop.example.com", "recrawl", suite.config(w, true, "ce/recrawl/shop_example_com/p1_ajax"), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Require().Nil(w.Close())
+
+	// The crawl itself still gets the placeholder, extraction reads the cache service
+	suite.True(placeholderRegex.MatchString(page.Content))
+	suite.NotEqual("", page.WarcRecordID)
+	suite.NotEqual("", ajax.WarcRecordID)
+
+	archive := suite.archived(page.WarcFile)
+	for url, html := range suite.pages {
+		response, ok := archive.Get(url)
+		suite.Require().True(ok, url)
+		suite.Equal(html, response.Content)
+		suite.False(placeholderRegex.MatchString(response.Content), url)
+	}
+	suite.Equal(2, archive.Len())
+}
+
+// Test_02_SkipsUnavailableContent - tests nothing is archived when the page can't be read back
+func (suite *WarcSuite) Test_02_SkipsUnavailableContent() {
+	suite.lost["https://shop.example.com/p/1"] = true
+	w := warc.NewWriter(suite.store, "sem3/test", 0)
+	page := GetRequest("https://shop.example.com/p/1", "shop.example.com", "recrawl", suite.config(w, false, "ce/recrawl/shop_example_com/p1"), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Equal(200, page.Status)
+	suite.Equal("", page.WarcRecordID)
+
+	// case: pages cached by the crawler are returned by the proxy
+	suite.appC.PageCache, _ = pagecache.NewLocalCache(filepath.Join(suite.dir, "page_cache"))
+	page = GetRequest("https://shop.example.com/p/1", "shop.example.com", "recrawl", suite.config(w, false, "ce/recrawl/shop_example_com/p1"), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Require().Nil(w.Close())
+	suite.Equal(suite.pages["https://shop.example.com/p/1"], page.Content)
+	response, ok := suite.archived(page.WarcFile).Get("https://shop.example.com/p/1")
+	suite.Require().True(ok)
+	suite.Equal(page.Content, response.Content)
+}
+
+// Test_03_ReplaysArchivedPages - tests pages archived while crawling are replayed as they were crawled
+func (suite *WarcSuite) Test_03_ReplaysArchivedPages() {
+	w := warc.NewWriter(suite.store, "sem3/test", 0)
+	page := GetRequest("https://shop.example.com/p/1", "shop.example.com", "recrawl", suite.config(w, false, "ce/recrawl/shop_example_com/p1"), &ctypes.CrawlJobParams{}, suite.appC)
+	GetRequest("https://shop.example.com/api/price/1", "shop.example.com", "recrawl", suite.config(w, true, "ce/recrawl/shop_example_com/p1_ajax"), &ctypes.CrawlJobParams{}, suite.appC)
+	suite.Require().Nil(w.Close())
+
+	archive, err := LoadWarcArchive([]string{page.WarcFile}, suite.appC)
+	suite.Require().Nil(err)
+	for url, html := range suite.pages {
+		webResponse, found := ArchivedWebResponse(url, archive)
+		suite.True(found, url)
+		suite.Equal(html, webResponse.Content)
+		suite.Equal(200, webResponse.Status)
+		suite.Equal("residential", webResponse.Headers.XNodePool)
+	}
+	// Pages of re-extracted batches are read from the archive
+	config := suite.config(nil, false, "")
+	config.Archive = archive
+	webResponse := VisitPage("https://shop.example.com/p/1", config, &ctypes.CrawlJobParams{}, &types.ProductMetrics{}, suite.appC)
+	suite.Equal(suite.pages["https://shop.example.com/p/1"], webResponse.Content)
+	suite.True(webResponse.FromCache)
+
+	// case: placeholders of older archives aren't replayed
+	w = warc.NewWriter(suite.store, "sem3/legacy", 0)
+	record, err := w.WriteExchange(warc.Exchange{URL: "https://shop.example.com/p/2", Status: 200, Content: "CACHE_WRITTEN: 45 bytes"})
+	suite.Require().Nil(err)
+	suite.Require().Nil(w.Close())
+	archive, err = LoadWarcArchive([]string{record.File}, suite.appC)
+	suite.Require().Nil(err)
+	webResponse, found := ArchivedWebResponse("https://shop.example.com/p/2", archive)
+	suite.False(found)
+	suite.Equal(404, webResponse.Status)
+	suite.Equal("", webResponse.Content)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestWarcSuite(t *testing.T) {
+	suite.Run(t, new(WarcSuite))
+}
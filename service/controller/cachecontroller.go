@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	jobutils "github.com/Semantics3/sem3-go-crawl-utils/jobs"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/labstack/echo"
+)
+
+// Keys listed (and invalidated by prefix) in a single request
+const maxCacheListLimit = 10000
+
+type cacheExplainRequest struct {
+	URL       string                 `json:"url"`
+	JobType   string                 `json:"job_type"`
+	JobParams map[string]interface{} `json:"job_params,omitempty"`
+}
+
+type cacheExplainResponse struct {
+	URL         string               `json:"url"`
+	Site        string               `json:"site"`
+	JobType     string               `json:"job_type"`
+	CacheFolder string               `json:"cache_folder"`
+	Fields      types.CacheKeyConfig `json:"fields"`
+	// Canonical json of the fields, the cache id is its md5 hash
+	CanonicalJson string `json:"canonical_json"`
+	CacheId       string `json:"cache_id"`
+	CacheKey      string `json:"cache_key"`
+	Note          string `json:"note,omitempty"`
+}
+
+// GetCacheExplainHandler explains the cache key of a url and job type: the fields which went into the hash
+func GetCacheExplainHandler(appC *types.Config) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		var req cacheExplainRequest
+		if err = c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": fmt.Sprintf("Error in binding request: %s\n", err.Error()),
+			})
+		}
+		if req.URL == "" || req.JobType == "" {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "url and job_type are mandatory fields in the request",
+			})
+		}
+
+		explained, status, err := explainCacheKey(req, appC)
+		if err != nil {
+			return c.JSON(status, map[string]interface{}{"message": err.Error()})
+		}
+		return c.JSONPretty(http.StatusOK, explained, "  ")
+	}
+}
+
+// newAdminWorkflow builds the workflow of a url and job type the same way the crawl pipeline does, without crawling it
+func newAdminWorkflow(url string, jobType string, jobParams map[string]interface{}, appC *types.Config) (workflow *types.CrawlWorkflow, status int, err error) {
+	jobInput := &ctypes.Batch{JobParams: jobParams, JobDetails: ctypes.JobConfig{JobType: jobType}}
+	if jobInput.JobParams == nil {
+		jobInput.JobParams = make(map[string]interface{})
+	}
+	workflow = &types.CrawlWorkflow{URL: url, JobInput: jobInput}
+	workflow.JobParams, err = utils.ParseJobParams(url, jobInput.JobParams)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	workflow.JobType = jobutils.GetJobType(jobInput)
+	workflow.DomainInfo, err = utils.GetCompleteDomainInfo(url, workflow.JobType, appC.ConfigData.WrapperServiceURI, workflow.JobParams)
+	if err != nil || workflow.DomainInfo == nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("could not fetch domain_info for %s: %v", url, err)
+	}
+	if workflow.Geo, _, err = utils.ResolveGeo(workflow, appC); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	workflow.RequestId = workflow.JobParams.RequestId
+	return workflow, http.StatusOK, nil
+}
+
+// explainCacheKey derives the cache key of a url the same way the crawl pipeline does
+func explainCacheKey(req cacheExplainRequest, appC *types.Config) (explained cacheExplainResponse, status int, err error) {
+	workflow, status, err := newAdminWorkflow(req.URL, req.JobType, req.JobParams, appC)
+	if err != nil {
+		return explained, status, err
+	}
+
+	site := workflow.DomainInfo.DomainName
+	explained = cacheExplainResponse{
+		URL:         req.URL,
+		Site:        site,
+		JobType:     workflow.JobType,
+		CacheFolder: "ce",
+		Fields:      utils.CacheKeyFields(req.URL, site, workflow, workflow.DomainInfo.Wrapper.Setup.Browser),
+	}
+	if workflow.JobParams.CacheFolder != "" {
+		explained.CacheFolder = workflow.JobParams.CacheFolder
+	}
+	explained.CanonicalJson, explained.CacheId, err = utils.HashCacheKeyFields(explained.Fields, req.URL)
+	if err != nil {
+		return explained, http.StatusInternalServerError, err
+	}
+	explained.CacheKey = utils.ConstructCacheKey(explained.CacheFolder, workflow.JobType, site, explained.CacheId)
+
+	// Crawls are assigned a random request_id unless one is sent (see utils.AssignRequestId)
+	if workflow.RequestId == "" && (workflow.JobType == "recrawl" || (workflow.JobType == "realtimeapi" && workflow.JobParams.Cache != 1)) {
+		explained.Note = "crawls of this job type use a random request_id unless one is sent in job_params, pages cached by them have different keys"
+	}
+	return explained, http.StatusOK, nil
+}
+
+// GetCacheDocumentHandler fetches the document cached against a key along with its age
+// The key is either sent as is (key) or explained from url and job_type
+func GetCacheDocumentHandler(appC *types.Config) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		key := c.QueryParam("key")
+		if key == "" && c.QueryParam("url") != "" {
+			req := cacheExplainRequest{URL: c.QueryParam("url"), JobType: c.QueryParam("job_type")}
+			if jobParams := c.QueryParam("job_params"); jobParams != "" {
+				if err = json.Unmarshal([]byte(jobParams), &req.JobParams); err != nil {
+					return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": "job_params is not valid json"})
+				}
+			}
+			explained, status, err := explainCacheKey(req, appC)
+			if err != nil {
+				return c.JSON(status, map[string]interface{}{"message": err.Error()})
+			}
+			key = explained.CacheKey
+		}
+		if key == "" {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "key or url and job_type are mandatory query params",
+			})
+		}
+
+		doc, err := appC.PageCache.Get(key)
+		if err == pagecache.ErrNotFound {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"message": fmt.Sprintf("%s is not present in %s cache", key, appC.PageCache.Name()),
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": err.Error()})
+		}
+
+		var webResponse ctypes.WebResponse
+		if err = json.Unmarshal(doc, &webResponse); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"message": fmt.Sprintf("cached document %s is not a web response: %v", key, err),
+			})
+		}
+		// Content is large, only sent when asked for
+		if withContent, _ := strconv.ParseBool(c.QueryParam("content")); !withContent {
+			webResponse.Content = ""
+		}
+		return c.JSONPretty(http.StatusOK, map[string]interface{}{
+			"key":      key,
+			"backend":  appC.PageCache.Name(),
+			"size":     len(doc),
+			"time":     webResponse.Time,
+			"age":      time.Now().Unix() - webResponse.Time,
+			"document": webResponse,
+		}, "  ")
+	}
+}
+
+// cacheKeyPrefix builds a key prefix from the prefix query param or from domain, folder and job type
+// Keys of a domain are only listed under a job type, so that a domain never scans the whole folder
+func cacheKeyPrefix(c echo.Context) (prefix string, err error) {
+	if prefix = c.QueryParam("prefix"); prefix != "" {
+		return prefix, nil
+	}
+	folder, jobType, domain := c.QueryParam("folder"), c.QueryParam("job_type"), c.QueryParam("domain")
+	if folder == "" {
+		folder = "ce"
+	}
+	if domain != "" && jobType == "" {
+		return "", fmt.Errorf("job_type is mandatory with domain")
+	}
+	if domain != "" {
+		return utils.CacheKeyPrefix(folder, jobType, domain), nil
+	}
+	if jobType != "" {
+		return fmt.Sprintf("%s/%s/", folder, jobType), nil
+	}
+	return fmt.Sprintf("%s/", folder), nil
+}
+
+// listCacheKeys lists up to limit keys under prefix, truncated is set when more keys are left
+func listCacheKeys(appC *types.Config, prefix string, limit int) (keys []string, truncated bool, err error) {
+	lister, ok := appC.PageCache.(pagecache.Lister)
+	if !ok {
+		return nil, false, fmt.Errorf("%s cache can't list keys", appC.PageCache.Name())
+	}
+	// One more key than asked for tells whether the listing is complete
+	keys, err = lister.List(prefix, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(keys) > limit {
+		return keys[:limit], true, nil
+	}
+	return keys, false, nil
+}
+
+// GetCacheKeysHandler lists cached keys by domain and cache folder (or a raw key prefix)
+func GetCacheKeysHandler(appC *types.Config) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		limit := 100
+		if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 {
+			limit = l
+		}
+		if limit > maxCacheListLimit {
+			limit = maxCacheListLimit
+		}
+		prefix, err := cacheKeyPrefix(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+		}
+		keys, truncated, err := listCacheKeys(appC, prefix, limit)
+		if err != nil {
+			return c.JSON(http.StatusNotImplemented, map[string]interface{}{"message": err.Error()})
+		}
+
+		// Size and crawl time of each key
+		stats := make([]map[string]interface{}, 0, len(keys))
+		now := time.Now().Unix()
+		for _, key := range keys {
+			stat, err := appC.PageCache.Stat(key)
+			if err != nil {
+				stats = append(stats, map[string]interface{}{"key": key, "error": err.Error()})
+				continue
+			}
+			stats = append(stats, map[string]interface{}{"key": key, "size": stat.Size, "time": stat.Time, "age": now - stat.Time})
+		}
+		return c.JSONPretty(http.StatusOK, map[string]interface{}{
+			"backend":   appC.PageCache.Name(),
+			"prefix":    prefix,
+			"count":     len(stats),
+			"truncated": truncated,
+			"keys":      stats,
+		}, "  ")
+	}
+}
+
+// InvalidateCacheHandler deletes a single key (key) or all the keys under a prefix (prefix or domain/folder/job_type)
+// Prefix invalidation needs a backend which can list its keys and deletes at most maxCacheListLimit keys a request,
+// responds with 206 and truncated set when keys are left under the prefix
+func InvalidateCacheHandler(appC *types.Config) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		if key := c.QueryParam("key"); key != "" {
+			if err = appC.PageCache.Delete(key); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": err.Error()})
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{"invalidated": []string{key}, "count": 1})
+		}
+
+		if c.QueryParam("prefix") == "" && c.QueryParam("domain") == "" {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "key, prefix or domain is a mandatory query param",
+			})
+		}
+		prefix, err := cacheKeyPrefix(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+		}
+		keys, truncated, err := listCacheKeys(appC, prefix, maxCacheListLimit)
+		if err != nil {
+			return c.JSON(http.StatusNotImplemented, map[string]interface{}{"message": err.Error()})
+		}
+		invalidated := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if err = appC.PageCache.Delete(key); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+					"message":     fmt.Sprintf("failed to invalidate %s: %v", key, err),
+					"invalidated": invalidated,
+					"truncated":   true,
+				})
+			}
+			invalidated = append(invalidated, key)
+		}
+		response := map[string]interface{}{"invalidated": invalidated, "count": len(invalidated), "truncated": truncated}
+		if truncated {
+			response["message"] = fmt.Sprintf("more keys are left under %s, repeat the request to invalidate them", prefix)
+			return c.JSON(http.StatusPartialContent, response)
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
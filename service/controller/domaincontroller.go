@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 	"github.com/labstack/echo"
@@ -11,8 +12,9 @@ import (
 func GetDomainInfo(appC *types.Config) echo.HandlerFunc {
 
 	type getDomainInfoInput struct {
-		URL     string `json:"url"`
-		JobType string `json:"job_type,omitempty"`
+		URL         string   `json:"url"`
+		JobType     string   `json:"job_type,omitempty"`
+		DataSources []string `json:"data_sources,omitempty"`
 	}
 
 	return func(c echo.Context) (err error) {
@@ -40,8 +42,33 @@ func GetDomainInfo(appC *types.Config) echo.HandlerFunc {
 				"parent_sku":        domainInfo.ParentSKU,
 				"site_status":       domainInfo.SiteStatus,
 				"canonicalized_url": domainInfo.CanonicalUrl,
+				"data_sources":      dataSourcesInfo(domainInfoInput.DataSources),
 			})
 		}
 	}
 
 }
+
+// dataSourcesInfo describes the data sources looked up by name (WRAPPER by default)
+func dataSourcesInfo(names []string) []map[string]interface{} {
+	if len(names) == 0 {
+		names = []string{"WRAPPER"}
+	}
+	infos := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		info, ok := sources.Lookup(name)
+		if !ok {
+			infos = append(infos, map[string]interface{}{"name": name, "registered": false})
+			continue
+		}
+		infos = append(infos, map[string]interface{}{
+			"name":        name,
+			"registered":  true,
+			"supervised":  info.Supervised,
+			"cost":        info.Cost,
+			"fields":      info.Fields,
+			"missing_env": info.MissingEnv(),
+		})
+	}
+	return infos
+}
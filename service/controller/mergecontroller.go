@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Semantics3/go-crawler/merge"
+	"github.com/Semantics3/go-crawler/pipeline"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/labstack/echo"
+)
+
+type mergePlanResponse struct {
+	URL     string          `json:"url"`
+	Site    string          `json:"site"`
+	JobType string          `json:"job_type"`
+	Plan    types.MergePlan `json:"plan"`
+	// Order sources are tried in, for CASCADE merges
+	Cascade *types.CascadePlan `json:"cascade,omitempty"`
+}
+
+// GetMergePlanHandler shows the merge plan in effect for a url and job type: mode, data sources, required keys
+// and merge preference, and whether each of them comes from the request, the merge config or the defaults
+func GetMergePlanHandler(appC *types.Config) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		url, jobType := c.QueryParam("url"), c.QueryParam("job_type")
+		if url == "" || jobType == "" {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "url and job_type are mandatory query params",
+			})
+		}
+		var jobParams map[string]interface{}
+		if raw := c.QueryParam("job_params"); raw != "" {
+			if err = json.Unmarshal([]byte(raw), &jobParams); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": "job_params is not valid json"})
+			}
+		}
+
+		workflow, status, err := newAdminWorkflow(url, jobType, jobParams, appC)
+		if err != nil {
+			return c.JSON(status, map[string]interface{}{"message": err.Error()})
+		}
+		resp := mergePlanResponse{
+			URL:     url,
+			Site:    workflow.DomainInfo.DomainName,
+			JobType: workflow.JobType,
+			Plan:    pipeline.ResolveMergePlan(workflow, appC),
+		}
+		if resp.Plan.MergeMode != "MERGE_ALL" {
+			cascade := merge.PreviewCascadePlan(resp.Plan.DataSources, workflow.JobInput, appC)
+			resp.Cascade = &cascade
+		}
+		return c.JSONPretty(http.StatusOK, resp, "  ")
+	}
+}
@@ -3,7 +3,6 @@ package controller
 import (
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/Semantics3/go-crawler/types"
@@ -79,17 +78,15 @@ func UploadContentToS3(appC *types.Config) echo.HandlerFunc {
 			if err != nil {
 
 			}
-			domainKey := strings.Replace(site, ".", "_", -1)
-
 			// Check if any cache folder has been sent in job_params
 			cacheFolder := "ce"
 			if workflow.JobParams.CacheFolder != "" {
 				cacheFolder = workflow.JobParams.CacheFolder
 			}
-			cacheKey = fmt.Sprintf("%s/%s/%s/%s", cacheFolder, jobType, domainKey, cacheID)
+			cacheKey = utils.ConstructCacheKey(cacheFolder, jobType, site, cacheID)
 			var expiry int32
 			expiry = 60 * 60 // seconds (1 hour)
-			uploadErr := utils.WriteDataToCache(domainInfo.CanonicalUrl, appC.ConfigData.CacheService, cacheKey, webResponse, expiry)
+			uploadErr := utils.WriteDataToCache(domainInfo.CanonicalUrl, appC.PageCache, cacheKey, webResponse, expiry)
 			if uploadErr != nil {
 				return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 					"message": fmt.Sprintf("Uploading content to S3 failed with error %s", err.Error()),
@@ -1,15 +1,18 @@
 package controller
 
 import (
+	b64 "encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/labstack/echo"
 
 	"github.com/Semantics3/go-crawler/request"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
-	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 )
 
 // GetScreenshotHandler - screenshot controller
@@ -27,36 +30,157 @@ func GetScreenshotHandler(appC *types.Config) echo.HandlerFunc {
 			resp.FailureMessage = "url is a mandatory field in the request"
 			return c.JSON(http.StatusBadRequest, resp)
 		}
-		url := req.URL
+		if appC.ScreenshotStore == nil {
+			resp.FailureType = "STORAGE_NOT_CONFIGURED"
+			resp.FailureMessage = "screenshot_storage is not configured"
+			return c.JSON(http.StatusBadRequest, resp)
+		}
 
-		domain, err := utils.GetDomainName(url, appC.ConfigData.WrapperServiceURI)
+		setScreenshotRequestDefaults(&req)
+		req.RequestPolicy, err = utils.GetInlineScreenshotPolicy(req.RequestPolicy, types.ScreenshotOptions{})
 		if err != nil {
-			resp.FailureType = "SITE_EXTRACTION_ERROR"
-			resp.FailureMessage = fmt.Sprintf("site name could not be extracted: %s", err.Error())
+			resp.FailureType = "INVALID_PARAM"
+			resp.FailureMessage = err.Error()
+			return c.JSON(http.StatusBadRequest, resp)
+		}
+
+		// Screenshots are written to the configured storage, like those of the batch endpoint
+		result := captureScreenshot(req.URL, req, defaultScreenshotCustomer, false, true, appC)
+		if result.Status == 0 {
+			resp.FailureType = result.FailureType
+			resp.FailureMessage = result.FailureMessage
+			if result.FailureType == "SITE_EXTRACTION_ERROR" {
+				return c.JSON(http.StatusBadRequest, resp)
+			}
+			return c.JSON(http.StatusInternalServerError, resp)
+		}
+
+		resp.Status = 1
+		resp.Screenshot = result.Screenshot
+		return c.JSONPretty(http.StatusOK, resp, "  ")
+	}
+}
+
+// Batch screenshot requests are capped, larger batches must be split by the caller
+const (
+	maxScreenshotBatchSize    = 50
+	screenshotBatchWorkers    = 5
+	defaultScreenshotCustomer = "default"
+)
+
+func setScreenshotRequestDefaults(req *types.ScreenshotRequest) {
+	if len(req.Pools) == 0 {
+		req.Pools = []string{"internal_chrome"}
+	}
+	if req.Timeout == 0 {
+		req.Timeout = 60
+	}
+	if req.RequestPolicy == "" {
+		req.RequestPolicy = "render:1;rendering_engine:render_chrome;render_wait:10;"
+	}
+}
+
+// GetBatchScreenshotHandler - captures screenshots of a batch of urls
+// Images are returned inline (base64) and/or written to the configured screenshot storage
+func GetBatchScreenshotHandler(appC *types.Config) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		var req types.ScreenshotBatchRequest
+		resp := types.ScreenshotBatchResponse{}
+		if err = c.Bind(&req); err != nil {
+			resp.FailureType = "REQUEST_PARAM_PARSE_ERROR"
+			resp.FailureMessage = fmt.Sprintf("Error in binding request: %s\n", err.Error())
+			return c.JSON(http.StatusBadRequest, resp)
+		}
+		if len(req.URLs) == 0 {
+			resp.FailureType = "REQUIRED_PARAM_EMPTY"
+			resp.FailureMessage = "urls is a mandatory field in the request"
 			return c.JSON(http.StatusBadRequest, resp)
 		}
-		req.Domain = domain
-		if len(req.Pools) == 0 {
-			req.Pools = []string{"internal_chrome"}
+		if len(req.URLs) > maxScreenshotBatchSize {
+			resp.FailureType = "BATCH_SIZE_EXCEEDED"
+			resp.FailureMessage = fmt.Sprintf("a batch can have at most %d urls, received %d", maxScreenshotBatchSize, len(req.URLs))
+			return c.JSON(http.StatusBadRequest, resp)
 		}
-		if req.Timeout == 0 {
-			req.Timeout = 60
+		store := req.Store == nil || *req.Store
+		if !store && !req.Inline {
+			resp.FailureType = "INVALID_PARAM"
+			resp.FailureMessage = "screenshots must either be returned inline or stored"
+			return c.JSON(http.StatusBadRequest, resp)
 		}
-		if req.RequestPolicy == "" {
-			req.RequestPolicy = "render:1;rendering_engine:render_chrome;render_wait:10;"
+		if store && appC.ScreenshotStore == nil {
+			resp.FailureType = "STORAGE_NOT_CONFIGURED"
+			resp.FailureMessage = "screenshot_storage is not configured, request inline screenshots with store set to false"
+			return c.JSON(http.StatusBadRequest, resp)
 		}
-		config := &types.RequestConfig{}
-		req.RequestPolicy = utils.GetScreenshotPath(req.Domain, url, true, req.RequestPolicy, config, &ctypes.CrawlJobParams{})
 
-		err = request.GetScreenshot(appC, req)
+		template := types.ScreenshotRequest{Pools: req.Pools, Timeout: req.Timeout, RequestPolicy: req.RequestPolicy}
+		setScreenshotRequestDefaults(&template)
+		template.RequestPolicy, err = utils.GetInlineScreenshotPolicy(template.RequestPolicy, req.ScreenshotOptions)
 		if err != nil {
-			resp.FailureType = "PROXY_CLOUD_REQUEST_ERROR"
-			resp.FailureMessage = fmt.Sprintf("Error: %s", err.Error())
-			return c.JSON(http.StatusInternalServerError, resp)
+			resp.FailureType = "INVALID_PARAM"
+			resp.FailureMessage = err.Error()
+			return c.JSON(http.StatusBadRequest, resp)
+		}
+		customer := defaultScreenshotCustomer
+		if req.Customer != "" {
+			customer = strings.ToLower(req.Customer)
+		}
+
+		// Capture with a bounded number of concurrent proxycloud requests
+		resp.Results = make([]types.ScreenshotResult, len(req.URLs))
+		sem := make(chan struct{}, screenshotBatchWorkers)
+		var wg sync.WaitGroup
+		for i, url := range req.URLs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, url string) {
+				defer func() { <-sem; wg.Done() }()
+				resp.Results[i] = captureScreenshot(url, template, customer, req.Inline, store, appC)
+			}(i, url)
 		}
+		wg.Wait()
 
 		resp.Status = 1
-		resp.Screenshot = config.ScreenshotPath
 		return c.JSONPretty(http.StatusOK, resp, "  ")
 	}
 }
+
+func captureScreenshot(url string, req types.ScreenshotRequest, customer string, inline, store bool, appC *types.Config) (result types.ScreenshotResult) {
+	result.URL = url
+	// Page type of the storage key depends on whether the url is a product url
+	domainInfo, err := utils.GetPartialDomainInfo(url, "screenshot", appC.ConfigData.WrapperServiceURI)
+	if err == nil && domainInfo.DomainName == "" {
+		err = fmt.Errorf("empty domain name extracted for %s", url)
+	}
+	if err != nil {
+		result.FailureType = "SITE_EXTRACTION_ERROR"
+		result.FailureMessage = fmt.Sprintf("site name could not be extracted: %s", err.Error())
+		return
+	}
+	domain := domainInfo.DomainName
+	req.URL = url
+	req.Domain = domain
+
+	image, err := request.CaptureScreenshot(appC, req)
+	if err != nil {
+		result.FailureType = "PROXY_CLOUD_REQUEST_ERROR"
+		result.FailureMessage = fmt.Sprintf("Error: %s", err.Error())
+		return
+	}
+
+	if store {
+		key := fmt.Sprintf("%s.png", utils.ScreenshotKey(domain, url, domainInfo.IsProductUrl, req.RequestPolicy, customer))
+		result.Screenshot, err = appC.ScreenshotStore.Put(key, image)
+		if err != nil {
+			result.FailureType = "SCREENSHOT_STORAGE_ERROR"
+			result.FailureMessage = fmt.Sprintf("Error writing to %s storage: %s", appC.ScreenshotStore.Name(), err.Error())
+			return
+		}
+	}
+	if inline {
+		result.Image = b64.StdEncoding.EncodeToString(image)
+	}
+	log.Printf("SCREENSHOT_CAPTURED: URL: %s, SIZE: %d, LOCATION: %s\n", url, len(image), result.Screenshot)
+	result.Status = 1
+	return
+}
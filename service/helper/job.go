@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Semantics3/go-crawler/pipeline"
+	"github.com/Semantics3/go-crawler/request"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 	jobutils "github.com/Semantics3/sem3-go-crawl-utils/jobs"
@@ -33,6 +34,30 @@ func CrawlJobBatchExecute(jobInput *ctypes.Batch, appC *types.Config, queueName
 	inputCh := make(chan string, batchSize)
 	outputCh := make(chan *crawlResult, batchSize)
 
+	// Identical ajax requests fired by workflows of this batch share a single fetch
+	if ttl := appC.ConfigData.AjaxCacheTTL; ttl >= 0 {
+		cacheTTL := request.DefaultAjaxCacheTTL
+		if ttl > 0 {
+			cacheTTL = time.Duration(ttl) * time.Second
+		}
+		request.NewBatchAjaxCache(jobInput, cacheTTL)
+		defer request.ReleaseBatchAjaxCache(jobInput)
+	}
+
+	// Pages of the batch are read from WARC archives when re-extracting (warc_input)
+	archive, err := request.NewBatchWarcArchive(jobInput, appC)
+	if err != nil {
+		return tasksResults, crawlResults, err
+	}
+	if archive != nil {
+		defer request.ReleaseBatchWarcArchive(jobInput)
+	}
+
+	// Responses of the batch are written to WARC files when export is enabled
+	if request.NewBatchWarcWriter(jobInput, jobType, appC) != nil {
+		defer request.ReleaseBatchWarcWriter(jobInput)
+	}
+
 	// Execute appropriate job pipeline in parallel: 12 workers
 	numWorkers := 12
 	if batchSize < numWorkers {
@@ -131,6 +156,10 @@ func CollectResultsAndAnalyze(jobInput *ctypes.Batch,
 			taskResult["status_failed_reason_message"] = *workflow.FailureMessage
 		}
 
+		if workflow.Warc != nil {
+			taskResult["warc"] = workflow.Warc
+		}
+
 		// Construct jobserver feedback
 		if (workflow.Status == 1 || workflow.SendFailureAsFeedback) && len(workflow.Data.Links) > 0 {
 			taskFeedback := make(map[string]interface{}, 0)
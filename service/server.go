@@ -8,7 +8,9 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/Semantics3/go-crawler/merge"
 	"github.com/Semantics3/go-crawler/service/controller"
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 
@@ -29,8 +31,21 @@ func StartWebService(appC *types.Config) {
 	router.POST("/crawl/url", controller.GetCrawlWorkflowHandler(appC))
 	router.POST("/crawl/url/simple", controller.GetCrawlSimpleHandler(appC))
 	router.POST("/crawl/url/screenshot", controller.GetScreenshotHandler(appC))
+	router.POST("/crawl/url/screenshot/batch", controller.GetBatchScreenshotHandler(appC))
 	router.POST("/crawl/upload/content", controller.UploadContentToS3(appC))
 	router.POST("/domain/info", controller.GetDomainInfo(appC))
+	router.POST("/admin/cache/explain", controller.GetCacheExplainHandler(appC))
+	router.GET("/admin/cache/document", controller.GetCacheDocumentHandler(appC))
+	router.GET("/admin/cache/keys", controller.GetCacheKeysHandler(appC))
+	router.DELETE("/admin/cache/keys", controller.InvalidateCacheHandler(appC))
+	router.GET("/admin/merge/plan", controller.GetMergePlanHandler(appC))
+	router.GET("/admin/sources", func(c echo.Context) (err error) {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"estimates": merge.SourceEstimates(),
+			"sources":   sources.Registered(),
+			"costs":     appC.ConfigData.SourceCosts,
+		})
+	})
 	router.GET("/admin/memstats", func(c echo.Context) (err error) {
 		var memStats runtime.MemStats
 		runtime.ReadMemStats(&memStats)
@@ -0,0 +1,14 @@
+// Package all registers all the data sources, import it for its side effects
+package all
+
+import (
+	_ "github.com/Semantics3/go-crawler/sources/amazon"
+	_ "github.com/Semantics3/go-crawler/sources/diffbot"
+	_ "github.com/Semantics3/go-crawler/sources/jsonld"
+	_ "github.com/Semantics3/go-crawler/sources/m101"
+	_ "github.com/Semantics3/go-crawler/sources/metadata"
+	_ "github.com/Semantics3/go-crawler/sources/platform"
+	_ "github.com/Semantics3/go-crawler/sources/spastate"
+	_ "github.com/Semantics3/go-crawler/sources/supervised"
+	_ "github.com/Semantics3/go-crawler/sources/unsupervised"
+)
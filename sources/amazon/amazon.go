@@ -3,6 +3,7 @@ package amazon
 import (
 	"fmt"
 
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 )
 
@@ -12,6 +13,17 @@ type Amazon struct {
 	ErrorCode string
 }
 
+func init() {
+	sources.Register(sources.Info{
+		Name:        "AMAZON",
+		ErrorPrefix: "AMAZON_",
+		RequiredEnv: []string{"PAAPI_ACCESS_KEY", "PAAPI_SECRET_KEY"},
+		Cost:        1,
+	}, func(name string) types.Sources {
+		return &Amazon{Name: name}
+	})
+}
+
 // GetName - return name
 func (a *Amazon) GetName() string {
 	return a.Name
@@ -0,0 +1,58 @@
+// Package apisource implements data sources backed by JSON product APIs, defined in the config or in redis
+// (see types.APISourceConfig) rather than in code
+package apisource
+
+import (
+	"fmt"
+
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// APISource - implements Source interface, requests the API of its definition and maps the products of the responses
+type APISource struct {
+	Name       string
+	ErrorCode  string
+	definition *definition
+}
+
+// GetName - return name
+func (a *APISource) GetName() string {
+	return a.Name
+}
+
+// GetErrorCode - return code of error encountered while processing request
+func (a *APISource) GetErrorCode() string {
+	return a.ErrorCode
+}
+
+// Request - Requests the pages of the API response and maps their products
+func (a *APISource) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		a.ErrorCode = code
+	}(code)
+
+	products, code, err := a.definition.fetch(url, workflow, appC)
+	if err != nil {
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: err.Error()}
+		return false, code, fmt.Errorf("%s: %v", url, err)
+	}
+	if len(products) == 0 {
+		code = a.Name + "_NO_PRODUCT"
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: fmt.Sprintf("%s request resulted in an empty products response", a.Name)}
+		return false, code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+	}
+	workflow.Data = types.ExtractionResponse{Products: products, Status: 1}
+	return true, "", nil
+}
+
+// Extract - Returns nothing as we get products data directly
+func (a *APISource) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
+	return
+}
+
+// Normalize - products are mapped to the standard schema while requesting
+func (a *APISource) Normalize(workflow *types.CrawlWorkflow, appC *types.Config) {
+	return
+}
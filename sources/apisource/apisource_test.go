@@ -0,0 +1,181 @@
+package apisource
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+const pageURL = "https://shop.example.com/item/7731?ref=home"
+
+type APISourceSuite struct {
+	suite.Suite
+	api      *httptest.Server
+	requests []string
+}
+
+// Pages of the products of the local API by cursor
+var apiPages = map[string]string{
+	"": `{"items": [{"title": "Desk Lamp", "code": "DL-1", "link": "/item/7731?v=1", "price": {"value": 24, "currency": "USD"}, "stock": true,
+		"photos": [{"href": "https://cdn.example.com/dl-1.jpg"}]}], "next": "c2"}`,
+	"c2": `{"items": [{"title": "Desk Lamp XL", "code": "DL-2", "price": {"value": "31.5", "currency": "USD"}, "stock": false, "photos": []}], "next": "c3"}`,
+	"c3": `{"items": [], "next": null}`,
+}
+
+// SetupTest - Called before each test
+// Starts a local product API requiring a bearer token
+func (suite *APISourceSuite) SetupTest() {
+	suite.requests = nil
+	os.Setenv("ACME_API_TOKEN", "secret")
+	suite.api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.requests = append(suite.requests, r.URL.RequestURI())
+		page, ok := apiPages[r.URL.Query().Get("cursor")]
+		if r.Header.Get("Authorization") != "Bearer secret" || r.URL.Path != "/v2/items/7731" || !ok {
+			http.Error(w, `{"error": "not found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+}
+
+// TearDownTest - Called after each test
+func (suite *APISourceSuite) TearDownTest() {
+	suite.api.Close()
+	os.Unsetenv("ACME_API_TOKEN")
+	Load(&types.Config{ConfigData: &types.ConfigData{}})
+}
+
+func (suite *APISourceSuite) definition() *types.APISourceConfig {
+	return &types.APISourceConfig{
+		URL:        suite.api.URL + "/v2/items/{id}?site={site}",
+		IDPattern:  `/item/(\d+)`,
+		Auth:       &types.APISourceAuth{Type: "bearer", Env: "ACME_API_TOKEN"},
+		Cost:       0.5,
+		Products:   "$.items[*]",
+		Fields:     map[string]string{"name": "@.title", "sku": "@.code", "url": "@.link", "images": "@.photos[*].href", "price": "@.price.value", "currency": "@.price.currency", "availability": "@.stock"},
+		Pagination: &types.APISourcePagination{Type: "cursor", Param: "cursor", Next: "$.next"},
+	}
+}
+
+func (suite *APISourceSuite) workflow() *types.CrawlWorkflow {
+	return &types.CrawlWorkflow{URL: pageURL, DomainInfo: &ctypes.DomainInfo{DomainName: "shop.example.com"}}
+}
+
+// Test_01_Load - tests definitions of the config are registered as data sources, and unregistered once removed
+func (suite *APISourceSuite) Test_01_Load() {
+	appC := &types.Config{ConfigData: &types.ConfigData{APISources: map[string]*types.APISourceConfig{
+		"ACME_API": suite.definition(),
+		"BAD_API":  {URL: suite.api.URL, Fields: map[string]string{"name": "title"}},
+		"JSONLD":   suite.definition(),
+	}}}
+	Load(appC)
+
+	info, ok := sources.Lookup("ACME_API")
+	suite.Require().True(ok)
+	suite.Assert().Equal("ACME_API_", info.ErrorPrefix)
+	suite.Assert().Equal([]string{"ACME_API_TOKEN"}, info.RequiredEnv)
+	suite.Assert().Equal(0.5, info.Cost)
+	suite.Assert().Equal([]string{"availability", "currency", "images", "name", "price", "sku", "url"}, info.Fields)
+	_, ok = sources.Lookup("BAD_API")
+	suite.Assert().False(ok)
+	// case: built-in sources aren't replaced
+	info, _ = sources.Lookup("JSONLD")
+	suite.Assert().Equal("JSONLD_", info.ErrorPrefix)
+	suite.Assert().Empty(info.RequiredEnv)
+
+	delete(appC.ConfigData.APISources, "ACME_API")
+	Load(appC)
+	_, ok = sources.Lookup("ACME_API")
+	suite.Assert().False(ok)
+	_, ok = sources.Lookup("JSONLD")
+	suite.Assert().True(ok)
+}
+
+// Test_02_Request - tests products are mapped from the pages of the API response
+func (suite *APISourceSuite) Test_02_Request() {
+	Load(&types.Config{ConfigData: &types.ConfigData{APISources: map[string]*types.APISourceConfig{"ACME_API": suite.definition()}}})
+	source, err := sources.New("ACME_API")
+	suite.Require().Nil(err)
+	workflow := suite.workflow()
+
+	canExtract, code, err := source.Request(pageURL, workflow, nil, nil)
+	suite.Require().Nil(err)
+	suite.Assert().True(canExtract)
+	suite.Assert().Equal("", code)
+	suite.Assert().Equal([]string{
+		"/v2/items/7731?site=shop.example.com",
+		"/v2/items/7731?cursor=c2&site=shop.example.com",
+		"/v2/items/7731?cursor=c3&site=shop.example.com",
+	}, suite.requests)
+	suite.Assert().Equal(1, workflow.Data.Status)
+	suite.Require().Len(workflow.Data.Products, 2)
+	suite.Assert().Equal(map[string]interface{}{
+		"name":               "Desk Lamp",
+		"sku":                "DL-1",
+		"url":                "https://shop.example.com/item/7731?v=1",
+		"images":             []interface{}{"https://cdn.example.com/dl-1.jpg"},
+		"listprice":          "24.00",
+		"listprice_currency": "USD",
+		"offers":             []interface{}{map[string]interface{}{"price": "24.00", "currency": "USD", "availability": "Available"}},
+	}, workflow.Data.Products[0])
+	suite.Assert().Equal("31.50", workflow.Data.Products[1]["listprice"])
+
+	// case: pages are limited by max_pages
+	definition := suite.definition()
+	definition.Pagination.MaxPages = 1
+	Load(&types.Config{ConfigData: &types.ConfigData{APISources: map[string]*types.APISourceConfig{"ACME_API": definition}}})
+	source, _ = sources.New("ACME_API")
+	_, _, err = source.Request(pageURL, workflow, nil, nil)
+	suite.Require().Nil(err)
+	suite.Assert().Len(workflow.Data.Products, 1)
+
+	// case: urls without an id fail with TEMPLATE_FAILED
+	canExtract, code, err = source.Request("https://shop.example.com/about", workflow, nil, nil)
+	suite.Assert().NotNil(err)
+	suite.Assert().False(canExtract)
+	suite.Assert().Equal("ACME_API_TEMPLATE_FAILED", code)
+	suite.Assert().Equal("ACME_API_TEMPLATE_FAILED", source.GetErrorCode())
+	suite.Assert().Equal(0, workflow.Data.Status)
+
+	// case: failed requests fail with REQUEST_FAILED
+	os.Setenv("ACME_API_TOKEN", "expired")
+	_, code, err = source.Request(pageURL, workflow, nil, nil)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("ACME_API_REQUEST_FAILED", code)
+
+	// case: credentials of query auth aren't leaked by failed requests
+	queryAuth := suite.definition()
+	queryAuth.Auth = &types.APISourceAuth{Type: "query", Name: "api_key", Env: "ACME_API_TOKEN"}
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	queryAuth.URL = closed.URL + "/v2/items/{id}"
+	Load(&types.Config{ConfigData: &types.ConfigData{APISources: map[string]*types.APISourceConfig{"ACME_API": queryAuth}}})
+	source, _ = sources.New("ACME_API")
+	_, code, err = source.Request(pageURL, workflow, nil, nil)
+	suite.Require().NotNil(err)
+	suite.Assert().Equal("ACME_API_REQUEST_FAILED", code)
+	suite.Assert().NotContains(err.Error(), "expired")
+	suite.Assert().NotContains(workflow.Data.Message, "expired")
+	suite.Assert().Contains(err.Error(), "/v2/items/{id}")
+
+	// case: rate limits need redis
+	definition.RateLimit = 5
+	Load(&types.Config{ConfigData: &types.ConfigData{APISources: map[string]*types.APISourceConfig{"ACME_API": definition}}})
+	source, _ = sources.New("ACME_API")
+	_, code, err = source.Request(pageURL, workflow, nil, &types.Config{})
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("ACME_API_RATELIMIT_ERR", code)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestAPISourceSuite(t *testing.T) {
+	suite.Run(t, new(APISourceSuite))
+}
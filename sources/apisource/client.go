@@ -0,0 +1,275 @@
+package apisource
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/sources/mapping"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	defaultTimeout  = 10
+	defaultMaxPages = 5
+	maxRetry        = 10
+)
+
+// Shared by the requests of all the API sources, its default transport lets fixtures record and replay them
+var client = &http.Client{}
+
+var variableRegex = regexp.MustCompile(`\{(env:)?([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// code prefixes an error code with the name of the source
+func (d *definition) code(code string) string {
+	return d.name + "_" + code
+}
+
+// fetch requests the pages of the API response for the url and maps their products
+func (d *definition) fetch(url string, workflow *types.CrawlWorkflow, appC *types.Config) ([]map[string]interface{}, string, error) {
+	variables := d.variables(url, workflow)
+	endpoint, err := expand(d.config.URL, variables, neturl.QueryEscape)
+	if err != nil {
+		return nil, d.code("TEMPLATE_FAILED"), err
+	}
+	body, err := expand(d.config.Body, variables, nil)
+	if err != nil {
+		return nil, d.code("TEMPLATE_FAILED"), err
+	}
+	headers := make(map[string]string)
+	for name, value := range d.config.Headers {
+		if headers[name], err = expand(value, variables, nil); err != nil {
+			return nil, d.code("TEMPLATE_FAILED"), err
+		}
+	}
+	base, _ := neturl.Parse(url)
+
+	pagination := d.config.Pagination
+	maxPages := 1
+	if pagination != nil {
+		maxPages = defaultMaxPages
+		if pagination.MaxPages > 0 {
+			maxPages = pagination.MaxPages
+		}
+		if pagination.Type == "page" || pagination.Type == "offset" {
+			endpoint = setParam(endpoint, pagination.Param, strconv.Itoa(pagination.Start))
+		}
+	}
+
+	products := make([]map[string]interface{}, 0)
+	for page := 0; page < maxPages; page++ {
+		response, code, err := d.request(endpoint, body, headers, appC)
+		if err != nil {
+			if page == 0 {
+				return nil, code, err
+			}
+			// Products of the previous pages are kept
+			log.Printf("API_SOURCE_PAGE_FAILED: %s page %d of %s: %v\n", d.name, page+1, url, err)
+			break
+		}
+		count := len(products)
+		for _, node := range d.products.Eval(response, response) {
+			if product := d.fields.Product(response, node, base); product != nil {
+				products = append(products, product)
+			}
+		}
+		if pagination == nil || len(products) == count {
+			break
+		}
+
+		// Next page
+		switch pagination.Type {
+		case "page":
+			endpoint = setParam(endpoint, pagination.Param, strconv.Itoa(pagination.Start+page+1))
+		case "offset":
+			endpoint = setParam(endpoint, pagination.Param, strconv.Itoa(pagination.Start+(page+1)*pagination.Size))
+		case "cursor", "next_url":
+			next := ""
+			if values := d.next.Eval(response, response); len(values) > 0 {
+				next = mapping.Text(values[0])
+			}
+			if next == "" {
+				return products, "", nil
+			}
+			if pagination.Type == "cursor" {
+				endpoint = setParam(endpoint, pagination.Param, next)
+			} else if endpoint, err = resolve(endpoint, next); err != nil {
+				log.Printf("API_SOURCE_PAGE_FAILED: %s next url %s of %s: %v\n", d.name, next, url, err)
+				return products, "", nil
+			}
+		}
+	}
+	return products, "", nil
+}
+
+// variables of the templates of the definition for the url
+func (d *definition) variables(url string, workflow *types.CrawlWorkflow) map[string]string {
+	variables := map[string]string{"url": url}
+	if workflow.DomainInfo != nil {
+		variables["sku"] = workflow.DomainInfo.ParentSku
+		variables["site"] = workflow.DomainInfo.DomainName
+	}
+	if d.idPattern != nil {
+		if match := d.idPattern.FindStringSubmatch(url); len(match) > 1 {
+			variables["id"] = match[1]
+		}
+	}
+	return variables
+}
+
+// expand replaces the variables of a template, escaping their values with escape, variables without a value fail
+func expand(template string, variables map[string]string, escape func(string) string) (string, error) {
+	var err error
+	expanded := variableRegex.ReplaceAllStringFunc(template, func(variable string) string {
+		match := variableRegex.FindStringSubmatch(variable)
+		value := variables[match[2]]
+		if match[1] != "" {
+			value = os.Getenv(match[2])
+		}
+		if value == "" && err == nil {
+			err = fmt.Errorf("no value for %s in template %s", variable, template)
+		}
+		if escape != nil {
+			value = escape(value)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+func setParam(endpoint, param, value string) string {
+	u, err := neturl.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	query := u.Query()
+	query.Set(param, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func resolve(endpoint, link string) (string, error) {
+	base, err := neturl.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	ref, err := neturl.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// request makes a rate limited request to the API and decodes its JSON response
+func (d *definition) request(endpoint, body string, headers map[string]string, appC *types.Config) (response interface{}, code string, err error) {
+	if code, err = d.waitForRateLimit(appC, 0); err != nil {
+		return nil, code, err
+	}
+
+	timeout := d.config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, d.config.Method, endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, d.code("REQUEST_FAILED"), err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	d.authenticate(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Errors of the client hold the url of the request, which has the credential of query auth
+		if urlErr, ok := err.(*neturl.Error); ok {
+			urlErr.URL = d.config.URL
+		}
+		return nil, d.code("REQUEST_FAILED"), err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, d.code("REQUEST_FAILED"), err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(content) > 200 {
+			content = content[:200]
+		}
+		return nil, d.code("REQUEST_FAILED"), fmt.Errorf("status %d: %s", resp.StatusCode, content)
+	}
+	if err = json.Unmarshal(content, &response); err != nil {
+		return nil, d.code("INVALID_RESPONSE"), err
+	}
+	return response, "", nil
+}
+
+// authenticate sets the credential of the auth of the definition on the request
+func (d *definition) authenticate(req *http.Request) {
+	auth := d.config.Auth
+	if auth == nil {
+		return
+	}
+	credential := os.Getenv(auth.Env)
+	switch auth.Type {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+credential)
+	case "basic":
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credential)))
+	case "header":
+		req.Header.Set(auth.Name, credential)
+	case "query":
+		query := req.URL.Query()
+		query.Set(auth.Name, credential)
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+// waitForRateLimit waits till the rate limit of the definition allows a request, like amazon and m101
+func (d *definition) waitForRateLimit(appC *types.Config, retry int) (string, error) {
+	if d.config.RateLimit <= 0 {
+		return "", nil
+	}
+	if appC == nil || appC.RedisRdstore == nil {
+		return d.code("RATELIMIT_ERR"), fmt.Errorf("RATELIMIT_ERR: no redis to rate limit requests")
+	}
+	check, err := sources.CheckRateLimitPerSecond(appC.RedisRdstore, d.rateLimitSource())
+	if err == redis.ErrNil && retry == 0 {
+		// The limit is missing from redis (eg. flushed), store it again
+		d.seedRateLimit(appC)
+		return d.waitForRateLimit(appC, retry+1)
+	}
+	if err != nil {
+		return d.code("RATELIMIT_ERR"), fmt.Errorf("RATELIMIT_ERR: %v", err)
+	}
+	if !check {
+		if retry >= maxRetry {
+			return d.code("RATELIMIT_EXCEEDED"), fmt.Errorf("too many requests, %d retries failed", retry)
+		}
+		// calculate sleep time
+		milliEpoch := time.Now().UnixNano() / int64(time.Millisecond)
+		secEpoch := time.Now().Unix()
+		sleepTime := 1000 - (milliEpoch - secEpoch*1000)
+		time.Sleep(time.Duration(sleepTime) * time.Millisecond)
+		return d.waitForRateLimit(appC, retry+1)
+	}
+	return "", nil
+}
@@ -0,0 +1,221 @@
+package apisource
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/sources/mapping"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// API source definitions are stored as json in a redis hash by source name, they override those of the config
+	APISourceRedisKey = "api_sources"
+	// Any message reloads the definitions
+	APISourceChannel = "api_source_live_updates"
+)
+
+var envRegex = regexp.MustCompile(`\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Sources registered by the last load, and the config they were loaded with
+var loaded = struct {
+	sync.Mutex
+	names map[string]bool
+	appC  *types.Config
+}{names: make(map[string]bool)}
+
+// definition is a validated API source definition with its paths compiled
+type definition struct {
+	name      string
+	config    types.APISourceConfig
+	idPattern *regexp.Regexp
+	products  *mapping.Path
+	fields    mapping.Fields
+	next      *mapping.Path
+}
+
+// Load registers the API sources defined in the config and the api_sources redis hash, sources which are no longer
+// defined are unregistered. Invalid definitions, and definitions using the name of a built-in source, are skipped
+func Load(appC *types.Config) {
+	definitions := make(map[string]*types.APISourceConfig)
+	if appC.ConfigData != nil {
+		for name, config := range appC.ConfigData.APISources {
+			definitions[name] = config
+		}
+	}
+	for name, config := range redisDefinitions(appC) {
+		definitions[name] = config
+	}
+
+	loaded.Lock()
+	defer loaded.Unlock()
+	names := make(map[string]bool)
+	for name, config := range definitions {
+		if _, ok := sources.Lookup(name); ok && !loaded.names[name] {
+			log.Printf("API_SOURCE_INVALID: %s is the name of a built-in data source\n", name)
+			continue
+		}
+		d, err := newDefinition(name, config)
+		if err != nil {
+			log.Printf("API_SOURCE_INVALID: %s: %v\n", name, err)
+			continue
+		}
+		d.seedRateLimit(appC)
+		sources.Register(d.info(), func(name string) types.Sources {
+			return &APISource{Name: name, definition: d}
+		})
+		names[name] = true
+	}
+	for name := range loaded.names {
+		if !names[name] {
+			sources.Unregister(name)
+		}
+	}
+	loaded.names = names
+	loaded.appC = appC
+	log.Printf("API_SOURCE_LOADED: %d api sources registered\n", len(names))
+}
+
+// Reload loads the definitions again with the config of the last load, on live updates
+func Reload() {
+	loaded.Lock()
+	appC := loaded.appC
+	loaded.Unlock()
+	if appC != nil {
+		Load(appC)
+	}
+}
+
+// redisDefinitions reads the definitions of the api_sources redis hash
+func redisDefinitions(appC *types.Config) map[string]*types.APISourceConfig {
+	definitions := make(map[string]*types.APISourceConfig)
+	if appC.RedisRdstore == nil {
+		return definitions
+	}
+	conn := appC.RedisRdstore.Get()
+	defer conn.Close()
+	raw, err := redis.StringMap(conn.Do("HGETALL", APISourceRedisKey))
+	if err != nil {
+		log.Printf("API_SOURCE_READ_FAILED: %v\n", err)
+		return definitions
+	}
+	for name, value := range raw {
+		config := &types.APISourceConfig{}
+		if err = json.Unmarshal([]byte(value), config); err != nil {
+			log.Printf("API_SOURCE_INVALID: %s: %v\n", name, err)
+			continue
+		}
+		definitions[name] = config
+	}
+	return definitions
+}
+
+func newDefinition(name string, config *types.APISourceConfig) (d *definition, err error) {
+	if config == nil || config.URL == "" {
+		return nil, fmt.Errorf("url is mandatory")
+	}
+	if name != strings.ToUpper(name) {
+		return nil, fmt.Errorf("names of data sources are upper case")
+	}
+	d = &definition{name: name, config: *config}
+	if d.config.Method == "" {
+		d.config.Method = "GET"
+	}
+	if d.config.IDPattern != "" {
+		if d.idPattern, err = regexp.Compile(d.config.IDPattern); err != nil {
+			return nil, fmt.Errorf("id_pattern: %v", err)
+		}
+	}
+	if auth := d.config.Auth; auth != nil {
+		switch {
+		case auth.Env == "":
+			return nil, fmt.Errorf("auth needs the env holding the credential")
+		case auth.Type == "header" || auth.Type == "query":
+			if auth.Name == "" {
+				return nil, fmt.Errorf("%s auth needs a name", auth.Type)
+			}
+		case auth.Type != "bearer" && auth.Type != "basic":
+			return nil, fmt.Errorf("unknown auth type %s", auth.Type)
+		}
+	}
+	products := d.config.Products
+	if products == "" {
+		products = "$"
+	}
+	if d.products, err = mapping.Compile(products); err != nil {
+		return nil, err
+	}
+	if d.fields, err = mapping.CompileFields(d.config.Fields); err != nil {
+		return nil, err
+	}
+	if pagination := d.config.Pagination; pagination != nil {
+		switch pagination.Type {
+		case "page", "offset":
+			if pagination.Param == "" || (pagination.Type == "offset" && pagination.Size <= 0) {
+				return nil, fmt.Errorf("%s pagination needs a param (and a size for offsets)", pagination.Type)
+			}
+		case "cursor", "next_url":
+			if pagination.Next == "" || (pagination.Type == "cursor" && pagination.Param == "") {
+				return nil, fmt.Errorf("%s pagination needs the path of the next page (and a param for cursors)", pagination.Type)
+			}
+			if d.next, err = mapping.Compile(pagination.Next); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown pagination type %s", pagination.Type)
+		}
+	}
+	return d, nil
+}
+
+// info describes the source in the registry, environment variables of the templates and auth are required
+func (d *definition) info() sources.Info {
+	info := sources.Info{Name: d.name, ErrorPrefix: d.name + "_", Cost: d.config.Cost, RequiredEnv: make([]string, 0)}
+	env := make(map[string]bool)
+	templates := []string{d.config.URL, d.config.Body}
+	for _, header := range d.config.Headers {
+		templates = append(templates, header)
+	}
+	for _, template := range templates {
+		for _, match := range envRegex.FindAllStringSubmatch(template, -1) {
+			env[match[1]] = true
+		}
+	}
+	if d.config.Auth != nil {
+		env[d.config.Auth.Env] = true
+	}
+	for name := range env {
+		info.RequiredEnv = append(info.RequiredEnv, name)
+	}
+	sort.Strings(info.RequiredEnv)
+	for field := range d.fields {
+		info.Fields = append(info.Fields, field)
+	}
+	sort.Strings(info.Fields)
+	return info
+}
+
+// rateLimitSource is the name the rate limit of the source is kept under in redis (see sources.CheckRateLimitPerSecond)
+func (d *definition) rateLimitSource() string {
+	return strings.ToLower(d.name)
+}
+
+// seedRateLimit stores the rate limit of the definition for sources.CheckRateLimitPerSecond
+func (d *definition) seedRateLimit(appC *types.Config) {
+	if d.config.RateLimit <= 0 || appC.RedisRdstore == nil {
+		return
+	}
+	conn := appC.RedisRdstore.Get()
+	defer conn.Close()
+	key := fmt.Sprintf("global_ratelimit_per_second_%s", d.rateLimitSource())
+	if _, err := conn.Do("SET", key, d.config.RateLimit); err != nil {
+		log.Printf("API_SOURCE_RATELIMIT_FAILED: %s: %v\n", d.name, err)
+	}
+}
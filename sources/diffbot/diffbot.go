@@ -14,6 +14,12 @@ type Diffbot struct {
 	ErrorCode string
 }
 
+func init() {
+	sources.Register(sources.Info{Name: "DIFFBOT", ErrorPrefix: "DIFFBOT_", Cost: 1}, func(name string) types.Sources {
+		return &Diffbot{Name: name}
+	})
+}
+
 // Request - Make http request to m101 api and fetch response
 func (d *Diffbot) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
 
@@ -0,0 +1,494 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type hash = map[string]interface{}
+
+var (
+	scriptPattern = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+	// Wrappers some sites put around the json of a script tag
+	scriptWrappers = strings.NewReplacer("/*<![CDATA[*/", "", "/*]]>*/", "", "<!--", "", "-->", "", "<![CDATA[", "", "]]>", "")
+	pricePattern   = regexp.MustCompile(`[0-9]+(\.[0-9]+)?`)
+)
+
+// Availability of schema.org offers in the sem3 schema
+var availabilities = map[string]string{
+	"InStock":             "Available",
+	"InStoreOnly":         "Available",
+	"OnlineOnly":          "Available",
+	"LimitedAvailability": "Available",
+	"PreOrder":            "Pre-Order",
+	"PreSale":             "Pre-Order",
+	"BackOrder":           "Backorder",
+	"OutOfStock":          "Out of Stock",
+	"SoldOut":             "Out of Stock",
+	"Discontinued":        "Discontinued",
+}
+
+// Conditions of schema.org offers in the sem3 schema
+var conditions = map[string]string{
+	"NewCondition":         "New",
+	"UsedCondition":        "Used",
+	"RefurbishedCondition": "Refurbished",
+	"DamagedCondition":     "Damaged",
+}
+
+// ExtractProducts returns the schema.org products embedded as JSON-LD in the html of pageURL, mapped to the sem3 schema
+// Product groups (ProductGroup/ItemGroup with hasVariant, or products which are isVariantOf a group) result in a
+// product per variant, the fields of the group are used for the fields the variants don't have
+func ExtractProducts(content, pageURL string) []hash {
+	blocks := make([]interface{}, 0)
+	for _, match := range scriptPattern.FindAllStringSubmatch(content, -1) {
+		raw := strings.TrimSpace(scriptWrappers.Replace(match[1]))
+		if raw == "" {
+			continue
+		}
+		var block interface{}
+		if err := json.Unmarshal([]byte(raw), &block); err != nil {
+			log.Printf("JSONLD_INVALID_BLOCK: (%s) %v\n", pageURL, err)
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+	return ProductsFromNodes(blocks, pageURL)
+}
+
+// ProductsFromNodes returns the products described by schema.org nodes (in their JSON-LD form, eg. {"@type": "Product",
+// "name": ...}) mapped to the sem3 schema, used for schema.org data embedded in other syntaxes as well
+func ProductsFromNodes(nodes []interface{}, pageURL string) []hash {
+	graph := newGraph()
+	graph.add(nodes)
+
+	base, _ := neturl.Parse(pageURL)
+	products := make([]hash, 0)
+	seen := make(map[string]bool)
+	emit := func(product hash) {
+		if len(product) == 0 {
+			return
+		}
+		key := fmt.Sprintf("%v|%v|%v", product["sku"], product["name"], product["url"])
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		products = append(products, product)
+	}
+
+	// Variants of the groups on the page are only extracted as part of their group
+	variants := make(map[string]bool)
+	for _, node := range graph.nodes {
+		if !isGroup(node) {
+			continue
+		}
+		groupVariants := graph.variantsOf(node)
+		if len(groupVariants) == 0 {
+			emit(normalizeProduct(graph, node, nil, base))
+		}
+		for _, variant := range groupVariants {
+			variants[nodeID(variant)] = true
+			emit(normalizeProduct(graph, variant, node, base))
+		}
+	}
+	for _, node := range graph.nodes {
+		if !hasType(node, "Product") || isGroup(node) || (nodeID(node) != "" && variants[nodeID(node)]) {
+			continue
+		}
+		group, _ := graph.resolve(node["isVariantOf"]).(hash)
+		if group != nil && isGroup(group) && graph.byID[nodeID(group)] != nil {
+			continue
+		}
+		emit(normalizeProduct(graph, node, group, base))
+	}
+	return products
+}
+
+// graph holds the nodes of all the JSON-LD blocks of a page, nodes referenced by @id are resolved against it
+type graph struct {
+	nodes     []hash
+	byID      map[string]hash
+	positions map[string]int
+}
+
+func newGraph() *graph {
+	return &graph{byID: make(map[string]hash), positions: make(map[string]int)}
+}
+
+// add collects the nodes of a block: top level nodes, nodes of @graph and the main entities of pages
+func (g *graph) add(block interface{}) {
+	switch b := block.(type) {
+	case []interface{}:
+		for _, item := range b {
+			g.add(item)
+		}
+	case hash:
+		if items, ok := b["@graph"]; ok {
+			g.add(items)
+		}
+		if _, ok := b["@type"]; !ok {
+			return
+		}
+		if id := nodeID(b); id != "" {
+			// A node can be described more than once, keep the most complete description
+			if existing, ok := g.byID[id]; ok {
+				if len(b) > len(existing) {
+					g.byID[id] = b
+					g.nodes[g.positions[id]] = b
+				}
+				return
+			}
+			g.byID[id] = b
+			g.positions[id] = len(g.nodes)
+		}
+		g.nodes = append(g.nodes, b)
+		if entity, ok := b["mainEntity"]; ok {
+			g.add(entity)
+		}
+	}
+}
+
+// resolve returns the node a reference ({"@id": ...}) points to, other values as they are
+func (g *graph) resolve(value interface{}) interface{} {
+	node, ok := value.(hash)
+	if !ok {
+		return value
+	}
+	if _, typed := node["@type"]; !typed {
+		if target, ok := g.byID[nodeID(node)]; ok {
+			return target
+		}
+	}
+	return node
+}
+
+// variantsOf returns the variants of a group, listed in hasVariant or pointing to it with isVariantOf
+func (g *graph) variantsOf(group hash) []hash {
+	variants := make([]hash, 0)
+	ids := make(map[string]bool)
+	for _, value := range asList(group["hasVariant"]) {
+		if variant, ok := g.resolve(value).(hash); ok {
+			variants = append(variants, variant)
+			ids[nodeID(variant)] = true
+		}
+	}
+	if groupID := nodeID(group); groupID != "" {
+		for _, node := range g.nodes {
+			parent, _ := node["isVariantOf"].(hash)
+			if parent != nil && nodeID(parent) == groupID && (nodeID(node) == "" || !ids[nodeID(node)]) {
+				variants = append(variants, node)
+			}
+		}
+	}
+	return variants
+}
+
+// normalizeProduct maps a schema.org product (with the group it is a variant of, if any) to the sem3 schema
+func normalizeProduct(g *graph, node, group hash, base *neturl.URL) hash {
+	field := func(key string) interface{} {
+		if value, ok := node[key]; ok && value != nil {
+			return g.resolve(value)
+		}
+		if group != nil {
+			return g.resolve(group[key])
+		}
+		return nil
+	}
+
+	product := make(hash)
+	setString(product, "name", text(field("name")))
+	setString(product, "description", text(field("description")))
+	setString(product, "brand", text(g.resolve(field("brand"))))
+	setString(product, "manufacturer", text(g.resolve(field("manufacturer"))))
+	setString(product, "model", text(g.resolve(field("model"))))
+	setString(product, "mpn", text(node["mpn"]))
+	setString(product, "color", text(node["color"]))
+	setString(product, "size", text(g.resolve(node["size"])))
+
+	sku := text(node["sku"])
+	if sku == "" {
+		sku = text(node["productID"])
+	}
+	setString(product, "sku", sku)
+	setGTIN(product, node)
+
+	if link := text(node["url"]); link != "" {
+		product["url"] = resolveURL(base, link)
+	} else if base != nil {
+		product["url"] = base.String()
+	}
+	if images := imageURLs(g, field("image"), base); len(images) > 0 {
+		product["images"] = images
+	}
+
+	if weight, ok := g.resolve(node["weight"]).(hash); ok {
+		setString(product, "weight", text(weight["value"]))
+		setString(product, "weight_unit", text(weight["unitText"]))
+		if product["weight_unit"] == nil {
+			setString(product, "weight_unit", text(weight["unitCode"]))
+		}
+	}
+	if rating, ok := g.resolve(field("aggregateRating")).(hash); ok {
+		setString(product, "siterating", text(rating["ratingValue"]))
+		setString(product, "siterating_scale", text(rating["bestRating"]))
+		count := text(rating["reviewCount"])
+		if count == "" {
+			count = text(rating["ratingCount"])
+		}
+		setString(product, "reviews_number", count)
+	}
+
+	if offers := normalizeOffers(g, field("offers")); len(offers) > 0 {
+		product["offers"] = offers
+		if price, ok := offers[0]["price"]; ok {
+			product["listprice"] = price
+		}
+		if currency, ok := offers[0]["currency"]; ok {
+			product["listprice_currency"] = currency
+		}
+	}
+
+	// Products without any identifying data are breadcrumbs or widgets, not products
+	if product["name"] == nil && product["sku"] == nil {
+		return nil
+	}
+	return product
+}
+
+// normalizeOffers maps Offer, AggregateOffer and lists of them to sem3 offers
+// An AggregateOffer without the offers it aggregates results in a single offer at its lowPrice
+func normalizeOffers(g *graph, value interface{}) []hash {
+	offers := make([]hash, 0)
+	for _, item := range asList(value) {
+		node, ok := g.resolve(item).(hash)
+		if !ok {
+			continue
+		}
+		if hasType(node, "AggregateOffer") {
+			if nested := normalizeOffers(g, node["offers"]); len(nested) > 0 {
+				offers = append(offers, nested...)
+				continue
+			}
+			offer := normalizeOffer(g, node)
+			if low := price(node["lowPrice"]); low != "" {
+				offer["price"] = low
+			}
+			if offer["price"] != nil {
+				offers = append(offers, offer)
+			}
+			continue
+		}
+		if offer := normalizeOffer(g, node); offer["price"] != nil {
+			offers = append(offers, offer)
+		}
+	}
+	return offers
+}
+
+func normalizeOffer(g *graph, node hash) hash {
+	offer := make(hash)
+	specification, _ := g.resolve(node["priceSpecification"]).(hash)
+	if p := price(node["price"]); p != "" {
+		offer["price"] = p
+	} else if specification != nil {
+		setString(offer, "price", price(specification["price"]))
+	}
+	currency := text(node["priceCurrency"])
+	if currency == "" && specification != nil {
+		currency = text(specification["priceCurrency"])
+	}
+	setString(offer, "currency", currency)
+	setString(offer, "availability", availabilities[schemaTerm(text(node["availability"]))])
+	setString(offer, "condition", conditions[schemaTerm(text(node["itemCondition"]))])
+	setString(offer, "seller", text(g.resolve(node["seller"])))
+	return offer
+}
+
+// setGTIN maps the gtin fields of a product to upc/ean/upc14/isbn, a plain gtin by its length
+func setGTIN(product hash, node hash) {
+	setString(product, "upc", digits(text(node["gtin12"])))
+	setString(product, "ean", digits(text(node["gtin13"])))
+	setString(product, "upc14", digits(text(node["gtin14"])))
+	gtin := digits(text(node["gtin"]))
+	switch len(gtin) {
+	case 12:
+		setDefault(product, "upc", gtin)
+	case 13:
+		setDefault(product, "ean", gtin)
+	case 14:
+		setDefault(product, "upc14", gtin)
+	}
+	isbn := digits(text(node["isbn"]))
+	switch len(isbn) {
+	case 10:
+		setString(product, "isbn10", isbn)
+	case 13:
+		setString(product, "isbn13", isbn)
+	}
+}
+
+// imageURLs returns the urls of an image field (urls, ImageObjects or lists of them)
+func imageURLs(g *graph, value interface{}, base *neturl.URL) []interface{} {
+	images := make([]interface{}, 0)
+	for _, item := range asList(value) {
+		link := ""
+		switch image := g.resolve(item).(type) {
+		case string:
+			link = image
+		case hash:
+			if link = text(image["contentUrl"]); link == "" {
+				link = text(image["url"])
+			}
+		}
+		if link != "" {
+			images = append(images, resolveURL(base, link))
+		}
+	}
+	return images
+}
+
+// text returns the text of a value: strings, numbers, or the name of a node (eg. brand: {"@type": "Brand", "name": ...})
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(html.UnescapeString(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case hash:
+		if name := text(v["name"]); name != "" {
+			return name
+		}
+		return text(v["@value"])
+	case []interface{}:
+		if len(v) > 0 {
+			return text(v[0])
+		}
+	}
+	return ""
+}
+
+// Price formats a price of other product data (number or text) like the prices of JSON-LD offers
+func Price(value interface{}) string {
+	return price(value)
+}
+
+// Availability maps an availability (schema.org term or url, case and separators ignored, eg. in_stock) to the sem3
+// schema, empty when unknown
+func Availability(value string) string {
+	term := strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(schemaTerm(value)))
+	for schemaOrg, availability := range availabilities {
+		if strings.ToLower(schemaOrg) == term {
+			return availability
+		}
+	}
+	return ""
+}
+
+// price formats a price (number or text, eg. "1,299.00", "1.299,00" or "$12") with two decimals
+func price(value interface{}) string {
+	var amount float64
+	switch v := value.(type) {
+	case float64:
+		amount = v
+	case string:
+		match := pricePattern.FindString(normalizeSeparators(v))
+		if match == "" {
+			return ""
+		}
+		amount, _ = strconv.ParseFloat(match, 64)
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// normalizeSeparators removes thousands separators of a price and uses . as decimal separator
+// The last separator is the decimal one when both are used, a lone comma is unless followed by 3 digits
+func normalizeSeparators(value string) string {
+	comma, dot := strings.LastIndex(value, ","), strings.LastIndex(value, ".")
+	decimalComma := comma > dot && (dot >= 0 || len(digits(value[comma+1:])) != 3)
+	if !decimalComma {
+		return strings.Replace(value, ",", "", -1)
+	}
+	value = strings.Replace(value, ".", "", -1)
+	return strings.Replace(value, ",", ".", -1)
+}
+
+func digits(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// schemaTerm strips the schema.org prefix of an enumeration value (eg. https://schema.org/InStock)
+func schemaTerm(value string) string {
+	if i := strings.LastIndexAny(value, "/:"); i >= 0 {
+		return value[i+1:]
+	}
+	return value
+}
+
+func resolveURL(base *neturl.URL, link string) string {
+	if base == nil {
+		return link
+	}
+	ref, err := neturl.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func nodeID(node hash) string {
+	id, _ := node["@id"].(string)
+	return id
+}
+
+// hasType checks the @type of a node, with or without the schema.org prefix
+func hasType(node hash, name string) bool {
+	for _, t := range asList(node["@type"]) {
+		if s, ok := t.(string); ok && schemaTerm(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isGroup(node hash) bool {
+	return hasType(node, "ProductGroup") || hasType(node, "ItemGroup")
+}
+
+func asList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+func setString(product hash, key, value string) {
+	if value != "" {
+		product[key] = value
+	}
+}
+
+func setDefault(product hash, key, value string) {
+	if _, ok := product[key]; !ok {
+		product[key] = value
+	}
+}
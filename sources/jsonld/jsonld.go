@@ -0,0 +1,68 @@
+package jsonld
+
+import (
+	"fmt"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// JSONLD - implements Source interface, extracts schema.org products embedded as JSON-LD in the page
+type JSONLD struct {
+	Name      string
+	ErrorCode string
+}
+
+func init() {
+	sources.Register(sources.Info{
+		Name:        "JSONLD",
+		ErrorPrefix: "JSONLD_",
+		Fields:      []string{"sku", "name", "description", "brand", "manufacturer", "model", "mpn", "upc", "ean", "upc14", "isbn10", "isbn13", "color", "size", "url", "images", "weight", "weight_unit", "siterating", "siterating_scale", "reviews_number", "listprice", "listprice_currency", "offers"},
+	}, func(name string) types.Sources {
+		return &JSONLD{Name: name}
+	})
+}
+
+// GetName - return name
+func (j *JSONLD) GetName() string {
+	return j.Name
+}
+
+// GetErrorCode - return code of error encountered while processing request
+func (j *JSONLD) GetErrorCode() string {
+	return j.ErrorCode
+}
+
+// Request - Reuses the page already downloaded by a previous source of the crawl, downloads it otherwise
+func (j *JSONLD) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		j.ErrorCode = code
+	}(code)
+
+	return sources.ReusePage(url, workflow, pipeline, appC)
+}
+
+// Extract - Parses the JSON-LD blocks of the page
+func (j *JSONLD) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		j.ErrorCode = code
+	}(code)
+
+	products := ExtractProducts(workflow.WebResponse.Content, url)
+	if len(products) == 0 {
+		code = "JSONLD_NO_PRODUCT"
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: "no schema.org product found in the JSON-LD of the page"}
+		return code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+	}
+	workflow.Data = types.ExtractionResponse{Products: products, Status: 1}
+	return "", nil
+}
+
+// Normalize - products are mapped to the standard schema while extracting
+func (j *JSONLD) Normalize(workflow *types.CrawlWorkflow, appC *types.Config) {
+	return
+}
@@ -3,6 +3,7 @@ package m101
 import (
 	"fmt"
 
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 )
 
@@ -12,6 +13,18 @@ type M101 struct {
 	ErrorCode string
 }
 
+func init() {
+	sources.Register(sources.Info{
+		Name:        "M101",
+		ErrorPrefix: "M101_",
+		RequiredEnv: []string{"M101_API_KEY"},
+		Cost:        1,
+		Fields:      []string{"sku", "name", "listprice", "listprice_currency", "url", "site", "brand", "images", "offers"},
+	}, func(name string) types.Sources {
+		return &M101{Name: name}
+	})
+}
+
 // GetName - return name
 func (m *M101) GetName() string {
 	return m.Name
@@ -0,0 +1,128 @@
+// Package mapping maps JSON documents (state of pages, responses of APIs) to sem3 products with JSONPath-style paths
+package mapping
+
+import (
+	"fmt"
+	"html"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Semantics3/go-crawler/sources/jsonld"
+)
+
+type hash = map[string]interface{}
+
+// Fields of a mapping which make up the offer of a product
+var offerFields = map[string]bool{"price": true, "currency": true, "availability": true, "condition": true, "seller": true}
+
+var tagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// Fields maps sem3 fields to the paths of their values, relative to the product (@) or absolute ($)
+// images takes all the values of its path, other fields the first one. price, currency, availability (a boolean or a
+// schema.org term), condition and seller make up the offer of the product
+type Fields map[string]*Path
+
+// CompileFields compiles the paths of fields
+func CompileFields(paths map[string]string) (Fields, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fields to map")
+	}
+	fields := make(Fields)
+	for field, path := range paths {
+		compiled, err := Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field, err)
+		}
+		fields[field] = compiled
+	}
+	return fields, nil
+}
+
+// Product maps the product node of a document (root), urls are resolved against base
+// Nodes without a name or sku aren't products and return nil
+func (f Fields) Product(root, node interface{}, base *neturl.URL) map[string]interface{} {
+	product := make(hash)
+	offer := make(hash)
+	for field, path := range f {
+		values := path.Eval(root, node)
+		if field == "images" {
+			images := make([]interface{}, 0)
+			for _, value := range values {
+				if image := Text(value); image != "" {
+					images = append(images, resolveURL(base, image))
+				}
+			}
+			if len(images) > 0 {
+				product["images"] = images
+			}
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		value := Text(values[0])
+		switch field {
+		case "url":
+			value = resolveURL(base, value)
+		case "description":
+			value = strings.Join(strings.Fields(html.UnescapeString(tagRegex.ReplaceAllString(value, " "))), " ")
+		case "price", "listprice":
+			value = jsonld.Price(values[0])
+		case "availability":
+			if available, ok := values[0].(bool); ok {
+				value = "Out of Stock"
+				if available {
+					value = "Available"
+				}
+			} else {
+				value = jsonld.Availability(value)
+			}
+		}
+		if value == "" {
+			continue
+		}
+		if offerFields[field] {
+			offer[field] = value
+		} else {
+			product[field] = value
+		}
+	}
+
+	// Products without any identifying data aren't products (eg. a path selecting more than products)
+	if product["name"] == nil && product["sku"] == nil {
+		return nil
+	}
+	if offer["price"] != nil {
+		product["offers"] = []interface{}{offer}
+		if product["listprice"] == nil {
+			product["listprice"] = offer["price"]
+		}
+		if currency, ok := offer["currency"]; ok && product["listprice_currency"] == nil {
+			product["listprice_currency"] = currency
+		}
+	}
+	return product
+}
+
+// Text returns strings, numbers and booleans as text, other values are empty
+func Text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func resolveURL(base *neturl.URL, link string) string {
+	ref, err := neturl.Parse(link)
+	if base == nil || err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,338 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of the steps of a path
+const (
+	childStep = iota
+	wildcardStep
+	descendantStep
+	indexStep
+	filterStep
+)
+
+// Path is a compiled JSONPath-style expression, supporting:
+//
+//	$ (root of the blob), @ (current product), .key, ['key'], [0], [-1], [*], .*, ..key, ..*
+//	[?(@.path)], [?(@.path == 'value')], [?(@.path != 'value')] (values are strings, numbers, true, false or null)
+//
+// Apollo cache references ({"__ref": "Product:1"}) are resolved against the root of the blob
+type Path struct {
+	relative bool
+	steps    []step
+}
+
+type step struct {
+	kind   int
+	key    string
+	index  int
+	filter *filter
+}
+
+type filter struct {
+	path  *Path
+	op    string
+	value interface{}
+}
+
+// Compile parses a path, relative paths start with @
+func Compile(path string) (*Path, error) {
+	p := &pathParser{s: strings.TrimSpace(path)}
+	q, err := p.path()
+	if err == nil && p.i < len(p.s) {
+		err = fmt.Errorf("unexpected %q at %d", p.s[p.i:], p.i)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %v", path, err)
+	}
+	return q, nil
+}
+
+// Eval returns the values selected by the path from root, or from current for relative paths
+func (q *Path) Eval(root, current interface{}) []interface{} {
+	nodes := []interface{}{root}
+	if q.relative {
+		nodes = []interface{}{current}
+	}
+	for _, s := range q.steps {
+		next := make([]interface{}, 0)
+		for _, node := range nodes {
+			next = s.apply(root, deref(root, node), next)
+		}
+		nodes = next
+	}
+	for i, node := range nodes {
+		nodes[i] = deref(root, node)
+	}
+	return nodes
+}
+
+func (s step) apply(root, node interface{}, selected []interface{}) []interface{} {
+	switch s.kind {
+	case childStep:
+		if object, ok := node.(hash); ok {
+			if value, ok := object[s.key]; ok {
+				selected = append(selected, value)
+			}
+		}
+	case wildcardStep:
+		selected = append(selected, children(node)...)
+	case indexStep:
+		if array, ok := node.([]interface{}); ok {
+			index := s.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index >= 0 && index < len(array) {
+				selected = append(selected, array[index])
+			}
+		}
+	case descendantStep:
+		// references aren't followed, the cache of a blob can be cyclic
+		if object, ok := node.(hash); ok && s.key != "*" {
+			if value, ok := object[s.key]; ok {
+				selected = append(selected, value)
+			}
+		}
+		for _, child := range children(node) {
+			if s.key == "*" {
+				selected = append(selected, child)
+			}
+			selected = s.apply(root, child, selected)
+		}
+	case filterStep:
+		for _, child := range children(node) {
+			if s.filter.matches(root, deref(root, child)) {
+				selected = append(selected, child)
+			}
+		}
+	}
+	return selected
+}
+
+func (f *filter) matches(root, node interface{}) bool {
+	values := f.path.Eval(root, node)
+	if f.op == "" {
+		return len(values) > 0
+	}
+	equal := false
+	for _, value := range values {
+		if fmt.Sprint(value) == fmt.Sprint(f.value) {
+			equal = true
+		}
+	}
+	if f.op == "!=" {
+		return !equal
+	}
+	return equal
+}
+
+// children returns the items of an array or the values of an object, by key
+func children(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case []interface{}:
+		return n
+	case hash:
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(n))
+		for _, key := range keys {
+			values = append(values, n[key])
+		}
+		return values
+	}
+	return nil
+}
+
+// deref returns the object an Apollo cache reference points to ({"__ref": id}, or {"type": "id", "id": id} before
+// Apollo 3), other values as they are
+func deref(root, value interface{}) interface{} {
+	object, ok := value.(hash)
+	if !ok {
+		return value
+	}
+	id, _ := object["__ref"].(string)
+	if id == "" && object["type"] == "id" {
+		id, _ = object["id"].(string)
+	}
+	if id == "" {
+		return value
+	}
+	if cache, ok := root.(hash); ok {
+		if target, ok := cache[id]; ok {
+			return target
+		}
+	}
+	return value
+}
+
+type pathParser struct {
+	s string
+	i int
+}
+
+func (p *pathParser) path() (*Path, error) {
+	q := &Path{}
+	switch {
+	case p.consume("$"):
+	case p.consume("@"):
+		q.relative = true
+	default:
+		return nil, fmt.Errorf("path must start with $ or @")
+	}
+	for p.i < len(p.s) {
+		switch {
+		case p.consume(".."):
+			key := p.name()
+			if key == "" && p.consume("*") {
+				key = "*"
+			}
+			if key == "" {
+				return nil, fmt.Errorf("missing key at %d", p.i)
+			}
+			q.steps = append(q.steps, step{kind: descendantStep, key: key})
+		case p.consume("."):
+			if p.consume("*") {
+				q.steps = append(q.steps, step{kind: wildcardStep})
+				continue
+			}
+			key := p.name()
+			if key == "" {
+				return nil, fmt.Errorf("missing key at %d", p.i)
+			}
+			q.steps = append(q.steps, step{kind: childStep, key: key})
+		case p.consume("["):
+			s, err := p.selector()
+			if err != nil {
+				return nil, err
+			}
+			p.space()
+			if !p.consume("]") {
+				return nil, fmt.Errorf("missing ] at %d", p.i)
+			}
+			q.steps = append(q.steps, s)
+		default:
+			// end of a path in a filter
+			return q, nil
+		}
+	}
+	return q, nil
+}
+
+func (p *pathParser) selector() (step, error) {
+	p.space()
+	switch {
+	case p.consume("*"):
+		return step{kind: wildcardStep}, nil
+	case p.consume("?("):
+		p.space()
+		path, err := p.path()
+		if err != nil {
+			return step{}, err
+		}
+		if !path.relative {
+			return step{}, fmt.Errorf("filter path must start with @")
+		}
+		f := &filter{path: path}
+		p.space()
+		for _, op := range []string{"==", "!="} {
+			if p.consume(op) {
+				f.op = op
+				p.space()
+				if f.value, err = p.literal(); err != nil {
+					return step{}, err
+				}
+				p.space()
+			}
+		}
+		if !p.consume(")") {
+			return step{}, fmt.Errorf("missing ) at %d", p.i)
+		}
+		return step{kind: filterStep, filter: f}, nil
+	case p.i < len(p.s) && (p.s[p.i] == '\'' || p.s[p.i] == '"'):
+		key, err := p.quoted()
+		return step{kind: childStep, key: key}, err
+	}
+	start := p.i
+	if p.i < len(p.s) && p.s[p.i] == '-' {
+		p.i++
+	}
+	for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+	}
+	index, err := strconv.Atoi(p.s[start:p.i])
+	if err != nil {
+		return step{}, fmt.Errorf("invalid selector at %d", start)
+	}
+	return step{kind: indexStep, index: index}, nil
+}
+
+// literal parses the value a filter compares to
+func (p *pathParser) literal() (interface{}, error) {
+	if p.i < len(p.s) && (p.s[p.i] == '\'' || p.s[p.i] == '"') {
+		return p.quoted()
+	}
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(" )", rune(p.s[p.i])) {
+		p.i++
+	}
+	switch value := p.s[start:p.i]; value {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", value)
+		}
+		return number, nil
+	}
+}
+
+func (p *pathParser) quoted() (string, error) {
+	quote := p.s[p.i]
+	end := strings.IndexByte(p.s[p.i+1:], quote)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at %d", p.i)
+	}
+	value := p.s[p.i+1 : p.i+1+end]
+	p.i += end + 2
+	return value, nil
+}
+
+func (p *pathParser) name() string {
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !(c == '_' || c == '$' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func (p *pathParser) consume(token string) bool {
+	if strings.HasPrefix(p.s[p.i:], token) {
+		p.i += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) space() {
+	for p.i < len(p.s) && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
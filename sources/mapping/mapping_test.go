@@ -0,0 +1,51 @@
+package mapping
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MappingSuite struct {
+	suite.Suite
+}
+
+// Test_01_Paths - tests JSONPath-style expressions
+func (suite *MappingSuite) Test_01_Paths() {
+	var root interface{}
+	suite.Require().Nil(json.Unmarshal([]byte(`{"items": [{"id": 1, "tags": ["a", "b"], "kind": "x"}, {"id": 2, "kind": "y", "ref": {"__ref": "Other:3"}}],
+		"Other:3": {"id": 3, "kind": "x"}}`), &root))
+	for path, expected := range map[string][]interface{}{
+		"$.items[0].id":                {float64(1)},
+		"$.items[-1].id":               {float64(2)},
+		"$['items'][*].id":             {float64(1), float64(2)},
+		"$.items[0].tags.*":            {"a", "b"},
+		"$..id":                        {float64(3), float64(1), float64(2)},
+		"$.items[?(@.kind == 'x')].id": {float64(1)},
+		"$.items[?(@.kind!='x')].id":   {float64(2)},
+		"$.items[?(@.tags)].id":        {float64(1)},
+		"$.items[1].ref.kind":          {"x"},
+		"$.missing.id":                 {},
+	} {
+		compiled, err := Compile(path)
+		suite.Require().Nil(err, path)
+		suite.Assert().Equal(expected, compiled.Eval(root, nil), path)
+	}
+
+	// case: relative paths start from the current node
+	compiled, err := Compile("@.kind")
+	suite.Require().Nil(err)
+	suite.Assert().Equal([]interface{}{"y"}, compiled.Eval(root, map[string]interface{}{"kind": "y"}))
+
+	for _, path := range []string{"items", "$.items[", "$.items[?(@.kind == x)]", "$.", "$.items[?($.a)]"} {
+		_, err := Compile(path)
+		suite.Assert().NotNil(err, path)
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestMappingSuite(t *testing.T) {
+	suite.Run(t, new(MappingSuite))
+}
@@ -0,0 +1,317 @@
+package metadata
+
+import (
+	"log"
+	"strings"
+
+	"github.com/Semantics3/go-crawler/sources/jsonld"
+	"github.com/Semantics3/go-crawler/types"
+	"golang.org/x/net/html"
+)
+
+type hash = map[string]interface{}
+
+// Confidence of the fields extracted from each syntax (see types.ConfidenceKey), below that of wrapper output (1)
+// OpenGraph tags are written for link previews rather than for describing products (eg. titles with the site name)
+const (
+	ConfidenceMicrodata = 0.6
+	ConfidenceRDFa      = 0.6
+	ConfidenceOpenGraph = 0.4
+)
+
+// Availability of OpenGraph products (lowercased, without separators) as schema.org terms
+var openGraphAvailabilities = map[string]string{
+	"instock":      "InStock",
+	"available":    "InStock",
+	"outofstock":   "OutOfStock",
+	"oos":          "OutOfStock",
+	"soldout":      "OutOfStock",
+	"preorder":     "PreOrder",
+	"backorder":    "BackOrder",
+	"discontinued": "Discontinued",
+}
+
+// ExtractProducts returns the products described by schema.org microdata and RDFa, and OpenGraph meta tags of the html
+// of pageURL, mapped to the sem3 schema with the confidence of each field
+// OpenGraph tags describe the page, they fill the fields missing from the single product of the page, or are the
+// product when the page has no microdata or RDFa products
+func ExtractProducts(content, pageURL string) []hash {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		log.Printf("METADATA_PARSE_FAILED: (%s) %v\n", pageURL, err)
+		return nil
+	}
+
+	products := make([]hash, 0)
+	for _, structured := range []struct {
+		syntax     syntax
+		confidence float64
+	}{{microdata, ConfidenceMicrodata}, {rdfa, ConfidenceRDFa}} {
+		items := make([]interface{}, 0)
+		structured.syntax.walk(doc, nil, &items)
+		for _, product := range jsonld.ProductsFromNodes(items, pageURL) {
+			products = append(products, withConfidence(product, hash{}, structured.confidence))
+		}
+	}
+
+	meta := make(map[string][]string)
+	collectMeta(doc, meta)
+	node := openGraphNode(meta)
+	if node == nil {
+		return products
+	}
+	openGraph := jsonld.ProductsFromNodes([]interface{}{node}, pageURL)
+	switch {
+	case len(openGraph) == 0:
+	case len(products) == 0:
+		products = append(products, withConfidence(openGraph[0], hash{}, ConfidenceOpenGraph))
+	case len(products) == 1:
+		confidence, _ := products[0][types.ConfidenceKey].(hash)
+		for field, value := range openGraph[0] {
+			if _, ok := products[0][field]; !ok {
+				products[0][field] = value
+				confidence[field] = ConfidenceOpenGraph
+			}
+		}
+	}
+	return products
+}
+
+// withConfidence sets the confidence of the fields of a product which don't have one yet
+func withConfidence(product hash, confidence hash, level float64) hash {
+	for field := range product {
+		if _, ok := confidence[field]; !ok {
+			confidence[field] = level
+		}
+	}
+	product[types.ConfidenceKey] = confidence
+	return product
+}
+
+// syntax reads schema.org items from the attributes of a markup syntax (microdata or RDFa)
+type syntax struct {
+	// Attribute of the elements starting an item, with its types
+	scope string
+	// Attribute listing the properties of the item an element is the value of
+	property string
+	// Attribute identifying the item
+	id string
+	// value of an element which isn't an item
+	value func(n *html.Node) string
+}
+
+var microdata = syntax{scope: "itemtype", property: "itemprop", id: "itemid", value: microdataValue}
+
+var rdfa = syntax{scope: "typeof", property: "property", id: "resource", value: rdfaValue}
+
+// walk collects the items under n, items which are the value of a property of item are nested in it
+func (s syntax) walk(n *html.Node, item hash, items *[]interface{}) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		properties := s.properties(c)
+		if itemTypes, ok := attr(c, s.scope); ok || (s.scope == microdata.scope && hasAttr(c, "itemscope")) {
+			typeList := make([]interface{}, 0)
+			for _, itemType := range strings.Fields(itemTypes) {
+				typeList = append(typeList, itemType)
+			}
+			child := hash{"@type": typeList}
+			if id, _ := attr(c, s.id); id != "" {
+				child["@id"] = id
+			}
+			if item != nil && len(properties) > 0 {
+				for _, property := range properties {
+					addValue(item, property, child)
+				}
+			} else {
+				*items = append(*items, child)
+			}
+			s.walk(c, child, items)
+			continue
+		}
+		if item != nil && len(properties) > 0 {
+			value := s.value(c)
+			for _, property := range properties {
+				addValue(item, property, value)
+			}
+		}
+		s.walk(c, item, items)
+	}
+}
+
+// properties returns the schema.org properties of an element, without their prefix or vocabulary (eg. schema:name)
+// Prefixed properties of other vocabularies (eg. og:title in RDFa) are skipped
+func (s syntax) properties(n *html.Node) []string {
+	value, _ := attr(n, s.property)
+	properties := make([]string, 0)
+	for _, property := range strings.Fields(value) {
+		if i := strings.LastIndex(property, "/"); i >= 0 {
+			property = property[i+1:]
+		} else if i := strings.Index(property, ":"); i >= 0 {
+			if prefix := property[:i]; prefix != "schema" && prefix != "s" {
+				continue
+			}
+			property = property[i+1:]
+		}
+		properties = append(properties, property)
+	}
+	return properties
+}
+
+// microdataValue is the value of a property element as defined by the html microdata spec, content first (commonly
+// used on any element, eg. <span itemprop="price" content="10.00">$10</span>)
+func microdataValue(n *html.Node) string {
+	if content, ok := attr(n, "content"); ok {
+		return content
+	}
+	var key string
+	switch n.Data {
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		key = "src"
+	case "a", "area", "link":
+		key = "href"
+	case "object":
+		key = "data"
+	case "data", "meter":
+		key = "value"
+	case "time":
+		key = "datetime"
+	}
+	if value, ok := attr(n, key); ok && key != "" {
+		return value
+	}
+	return textContent(n)
+}
+
+// rdfaValue is the value of a property element as defined by RDFa lite
+func rdfaValue(n *html.Node) string {
+	for _, key := range []string{"content", "href", "src", "resource"} {
+		if value, ok := attr(n, key); ok {
+			return value
+		}
+	}
+	return textContent(n)
+}
+
+// collectMeta collects the content of the meta tags with a property or name, lowercased
+func collectMeta(n *html.Node, meta map[string][]string) {
+	if n.Type == html.ElementNode && n.Data == "meta" {
+		key, ok := attr(n, "property")
+		if !ok {
+			key, _ = attr(n, "name")
+		}
+		content, _ := attr(n, "content")
+		if key != "" && strings.TrimSpace(content) != "" {
+			key = strings.ToLower(key)
+			meta[key] = append(meta[key], strings.TrimSpace(content))
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectMeta(c, meta)
+	}
+}
+
+// openGraphNode describes the OpenGraph product of a page as a schema.org product, nil when the page isn't a product
+func openGraphNode(meta map[string][]string) hash {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if values := meta[key]; len(values) > 0 {
+				return values[0]
+			}
+		}
+		return ""
+	}
+	price := first("product:price:amount", "og:price:amount")
+	if !strings.Contains(first("og:type"), "product") && price == "" {
+		return nil
+	}
+
+	node := hash{
+		"@type":       "Product",
+		"name":        first("og:title"),
+		"description": first("og:description"),
+		"url":         first("og:url"),
+		"brand":       first("product:brand", "og:brand"),
+		"sku":         first("product:retailer_item_id", "product:sku"),
+		"gtin12":      first("product:upc"),
+		"gtin13":      first("product:ean"),
+		"gtin":        first("product:gtin"),
+		"isbn":        first("product:isbn", "books:isbn"),
+	}
+	images := make([]interface{}, 0)
+	seen := make(map[string]bool)
+	for _, key := range []string{"og:image", "og:image:secure_url", "og:image:url"} {
+		for _, image := range meta[key] {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	node["image"] = images
+	if price != "" {
+		offer := hash{
+			"@type":         "Offer",
+			"price":         price,
+			"priceCurrency": first("product:price:currency", "og:price:currency"),
+		}
+		availability := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(first("product:availability", "og:availability")))
+		if term, ok := openGraphAvailabilities[availability]; ok {
+			offer["availability"] = term
+		}
+		if condition := first("product:condition", "og:condition"); condition != "" {
+			offer["itemCondition"] = strings.Title(strings.ToLower(condition)) + "Condition"
+		}
+		node["offers"] = offer
+	}
+	return node
+}
+
+func addValue(item hash, property string, value interface{}) {
+	if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+		return
+	}
+	switch existing := item[property].(type) {
+	case nil:
+		item[property] = value
+	case []interface{}:
+		item[property] = append(existing, value)
+	default:
+		item[property] = []interface{}{existing, value}
+	}
+}
+
+// textContent returns the text of an element with whitespace collapsed
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	_, ok := attr(n, key)
+	return ok
+}
@@ -0,0 +1,68 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// Metadata - implements Source interface, extracts products from the OpenGraph tags, microdata and RDFa of the page
+type Metadata struct {
+	Name      string
+	ErrorCode string
+}
+
+func init() {
+	sources.Register(sources.Info{
+		Name:        "METADATA",
+		ErrorPrefix: "METADATA_",
+		Fields:      []string{"sku", "name", "description", "brand", "manufacturer", "model", "mpn", "upc", "ean", "upc14", "isbn10", "isbn13", "color", "size", "url", "images", "weight", "weight_unit", "siterating", "siterating_scale", "reviews_number", "listprice", "listprice_currency", "offers"},
+	}, func(name string) types.Sources {
+		return &Metadata{Name: name}
+	})
+}
+
+// GetName - return name
+func (m *Metadata) GetName() string {
+	return m.Name
+}
+
+// GetErrorCode - return code of error encountered while processing request
+func (m *Metadata) GetErrorCode() string {
+	return m.ErrorCode
+}
+
+// Request - Reuses the page already downloaded by a previous source of the crawl, downloads it otherwise
+func (m *Metadata) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		m.ErrorCode = code
+	}(code)
+
+	return sources.ReusePage(url, workflow, pipeline, appC)
+}
+
+// Extract - Parses the meta tags, microdata and RDFa of the page
+func (m *Metadata) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		m.ErrorCode = code
+	}(code)
+
+	products := ExtractProducts(workflow.WebResponse.Content, url)
+	if len(products) == 0 {
+		code = "METADATA_NO_PRODUCT"
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: "no product found in the meta tags, microdata or RDFa of the page"}
+		return code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+	}
+	workflow.Data = types.ExtractionResponse{Products: products, Status: 1}
+	return "", nil
+}
+
+// Normalize - products are mapped to the standard schema while extracting
+func (m *Metadata) Normalize(workflow *types.CrawlWorkflow, appC *types.Config) {
+	return
+}
@@ -0,0 +1,111 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/tests/pagetest"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/stretchr/testify/suite"
+)
+
+// go test ./sources/metadata -update rewrites the golden files from the current output
+var update = flag.Bool("update", false, "update golden files")
+
+const pageURL = "https://shop.example.com/p/1"
+
+type MetadataSuite struct {
+	suite.Suite
+}
+
+// Test_01_Golden - tests the products extracted from the saved pages in testdata against their golden files
+func (suite *MetadataSuite) Test_01_Golden() {
+	pages, err := filepath.Glob("testdata/*.html")
+	suite.Require().Nil(err)
+	suite.Require().NotEmpty(pages)
+	for _, page := range pages {
+		content, err := ioutil.ReadFile(page)
+		suite.Require().Nil(err)
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		suite.Require().Nil(encoder.Encode(ExtractProducts(string(content), pageURL)))
+		got := buffer.Bytes()
+
+		golden := strings.TrimSuffix(page, ".html") + ".golden.json"
+		if *update {
+			suite.Require().Nil(ioutil.WriteFile(golden, got, 0644))
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		suite.Require().Nil(err, "missing golden file, run with -update")
+		suite.Assert().JSONEq(string(expected), string(got), page)
+	}
+}
+
+// Test_02_Extract - tests the source extracts from the page downloaded by a previous source
+func (suite *MetadataSuite) Test_02_Extract() {
+	content, err := ioutil.ReadFile("testdata/microdata.html")
+	suite.Require().Nil(err)
+	source := &Metadata{Name: "METADATA"}
+	workflow := &types.CrawlWorkflow{URL: pageURL, WebResponse: types.WebResponse{Content: string(content), Success: true}}
+
+	canExtract, code, err := source.Request(pageURL, workflow, nil, nil)
+	suite.Assert().Equal(true, canExtract)
+	suite.Assert().Equal("", code)
+	suite.Assert().Nil(err)
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Assert().Equal("", code)
+	suite.Assert().Nil(err)
+	suite.Require().Len(workflow.Data.Products, 1)
+	product := workflow.Data.Products[0]
+	suite.Assert().Equal("PO-SET-2", product["sku"])
+
+	// case: fields get the confidence of the syntax they were extracted from
+	confidence := product[types.ConfidenceKey].(hash)
+	suite.Assert().Equal(ConfidenceMicrodata, confidence["name"])
+	suite.Assert().Equal(ConfidenceOpenGraph, confidence["description"])
+
+	// case: pages which aren't products fail with METADATA_NO_PRODUCT
+	content, err = ioutil.ReadFile("testdata/article.html")
+	suite.Require().Nil(err)
+	workflow.WebResponse.Content = string(content)
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Assert().Equal("METADATA_NO_PRODUCT", code)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal(0, workflow.Data.Status)
+}
+
+// Test_03_ReadsCachedPage - tests pages written to the cache service by proxycloud are extracted from their html
+func (suite *MetadataSuite) Test_03_ReadsCachedPage() {
+	content, err := ioutil.ReadFile("testdata/opengraph.html")
+	suite.Require().Nil(err)
+	proxy := pagetest.NewProxy(map[string]string{pageURL: string(content)})
+	defer proxy.Close()
+	appC := proxy.Config()
+	source := &Metadata{Name: "METADATA"}
+
+	workflow := pagetest.Workflow(pageURL)
+	canExtract, code, err := source.Request(pageURL, workflow, pagetest.Pipeline{}, appC)
+	suite.Require().Nil(err)
+	suite.Assert().True(canExtract)
+	suite.Assert().Equal("", code)
+	code, err = source.Extract(pageURL, workflow, pagetest.Pipeline{}, appC)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Require().Len(workflow.Data.Products, 1)
+	suite.Assert().Equal("HG-WKD-01", workflow.Data.Products[0]["sku"])
+	suite.Assert().Equal("148.00", workflow.Data.Products[0]["listprice"])
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestMetadataSuite(t *testing.T) {
+	suite.Run(t, new(MetadataSuite))
+}
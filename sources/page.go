@@ -0,0 +1,115 @@
+package sources
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Semantics3/go-crawler/request"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+)
+
+// FetchPage downloads the page of a crawl into workflow.WebResponse, from the WARC archive being
+// re-extracted, the page cache or the web
+// Sources running concurrently against copies of a workflow download the page once (see types.SharedPage)
+// Pages written to the cache service by proxycloud hold its placeholder, see ReusePage for the html
+func FetchPage(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+	page := workflow.SharedPage
+	if page == nil {
+		return fetchPage(url, workflow, pipeline, appC)
+	}
+	page.Once.Do(func() {
+		page.CanExtract, page.Code, page.Err = fetchPage(url, workflow, pipeline, appC)
+		page.WebResponse, page.CacheKey, page.CacheExpiry = workflow.WebResponse, workflow.CacheKey, workflow.CacheExpiry
+		page.CrawlTime, page.Warc, page.Session = workflow.CrawlTime, workflow.Warc, workflow.Session
+	})
+	workflow.WebResponse, workflow.CacheKey, workflow.CacheExpiry = page.WebResponse, page.CacheKey, page.CacheExpiry
+	workflow.CrawlTime, workflow.Session = page.CrawlTime, page.Session
+	if page.Warc != nil {
+		warc := *page.Warc
+		workflow.Warc = &warc
+	}
+	return page.CanExtract, page.Code, page.Err
+}
+
+func fetchPage(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+	// 1. Construct request config and Set cache parameters
+	reqConfig, code, err := pipeline.PrepareRequestConfig(workflow)
+	if err != nil {
+		return canExtract, code, err
+	}
+	if workflow.CascadePlan != nil {
+		reqConfig.SourcePlan = strings.Join(workflow.CascadePlan.Sources, ">")
+	}
+	cacheKey := reqConfig.CacheKey
+	workflow.CacheKey = cacheKey
+	workflow.CacheExpiry = pipeline.GetCacheExpiryTime()
+
+	// 2. Read data from the WARC archive being re-extracted or from cache
+	jobParams := workflow.JobParams
+	if archive := request.GetBatchWarcArchive(workflow.JobInput); archive != nil {
+		var found bool
+		if workflow.WebResponse, found = request.ArchivedWebResponse(url, archive); !found {
+			code = "WARC_RECORD_NOT_FOUND"
+			log.Printf("FETCH_PAGE: %s\n", workflow.WebResponse.Error)
+			return false, code, fmt.Errorf("%s", workflow.WebResponse.Error)
+		}
+		workflow.CrawlTime = workflow.WebResponse.Time
+	} else if (jobParams != nil && jobParams.Cache == 1) || pipeline.ShouldReadFromCache(workflow) {
+		utils.ReadDataFromCache(appC.PageCache, cacheKey, workflow)
+	} else {
+		workflow.CrawlTime = time.Now().Unix()
+	}
+
+	// Check if we're able download webpage from cache successfully
+	if workflow.WebResponse.FromCache {
+		log.Printf("FETCH_PAGE: Cache found, (%s) cache_path %s\n", workflow.URL, workflow.CacheKey)
+		canExtract = true
+	} else {
+		log.Printf("FETCH_PAGE: Crawl start (%s)\n", url)
+		workflow.Session = request.AcquireSession(workflow.DomainInfo.DomainName, workflow.DomainInfo, workflow.Geo, jobParams, appC)
+		reqConfig.Session = workflow.Session
+		if workflow.JobInput != nil {
+			reqConfig.Warc = request.GetBatchWarcWriter(workflow.JobInput)
+		}
+		workflow.WebResponse = request.VisitPage(url, &reqConfig, jobParams, &workflow.ProductMetrics, appC)
+		if workflow.WebResponse.WarcRecordID != "" {
+			workflow.Warc = &types.WarcReference{RecordID: workflow.WebResponse.WarcRecordID, File: workflow.WebResponse.WarcFile}
+		}
+		request.ReleaseSession(workflow.Session, workflow.WebResponse, appC)
+		utils.WriteWebResponseToCache(workflow, appC)
+		wr := workflow.WebResponse
+		utils.CollectProductMetrics("latency", wr.TimeTaken, &workflow.ProductMetrics)
+
+		// Decides whether request is eligble for data extraction based on the response status
+		// If web resp status is 500, code should be HTTP_500
+		canExtract, code, err = pipeline.ValidateWebResponse(workflow)
+		if err != nil {
+			return canExtract, code, err
+		}
+	}
+	return
+}
+
+// ReusePage extracts from the page downloaded by a previous source of the crawl, downloads it otherwise
+// Used by sources extracting data from the html of the page in process, the html of pages written to
+// the cache service is read back from it
+func ReusePage(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+	if workflow.WebResponse.Content != "" {
+		log.Printf("FETCH_PAGE: Reusing downloaded page (%s)\n", url)
+	} else if canExtract, code, err = FetchPage(url, workflow, pipeline, appC); err != nil || !canExtract {
+		return canExtract, code, err
+	}
+	if utils.IsCacheWritten(workflow.WebResponse.Content) {
+		content, err := utils.ReadCachedContent(appC.PageCache, workflow.CacheKey)
+		if err != nil {
+			code = "PAGE_CONTENT_UNAVAILABLE"
+			return false, code, cutils.PrintErr(code, fmt.Sprintf("Content of %s was written to the cache service and could not be read back", url), err)
+		}
+		workflow.WebResponse.Content = content
+	}
+	return true, "", nil
+}
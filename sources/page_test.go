@@ -0,0 +1,87 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/Semantics3/go-crawler/tests/pagetest"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/stretchr/testify/suite"
+)
+
+const pageURL = "https://shop.example.com/p/trail-runner-2"
+
+const pageContent = `<html><body><h1>Acme Trail Runner 2</h1></body></html>`
+
+type PageSuite struct {
+	suite.Suite
+	proxy *pagetest.Proxy
+	appC  *types.Config
+}
+
+// SetupTest - Called before each test
+// Pages are crawled with a cache key, proxycloud writes them to the cache service and answers with its placeholder
+func (suite *PageSuite) SetupTest() {
+	suite.proxy = pagetest.NewProxy(map[string]string{pageURL: pageContent})
+	suite.appC = suite.proxy.Config()
+}
+
+// TearDownTest - Called after each test
+func (suite *PageSuite) TearDownTest() {
+	suite.proxy.Close()
+}
+
+// Test_01_ReusePageReadsCachedPage - tests the html of pages written to the cache service is read back
+func (suite *PageSuite) Test_01_ReusePageReadsCachedPage() {
+	workflow := pagetest.Workflow(pageURL)
+	canExtract, code, err := ReusePage(pageURL, workflow, pagetest.Pipeline{}, suite.appC)
+	suite.Require().Nil(err)
+	suite.Assert().True(canExtract)
+	suite.Assert().Equal("", code)
+	suite.Assert().Equal(pageContent, workflow.WebResponse.Content)
+	suite.Assert().Equal("ce/recrawl/"+pageURL, workflow.CacheKey)
+	suite.Assert().Equal(1, suite.proxy.Crawls(pageURL))
+
+	// case: placeholder of the page downloaded by a previous source is read back, not crawled again
+	reused := pagetest.Workflow(pageURL)
+	reused.CacheKey = workflow.CacheKey
+	reused.WebResponse = types.WebResponse{Content: "CACHE_WRITTEN: 57 bytes", Status: 200, Success: true}
+	canExtract, code, err = ReusePage(pageURL, reused, pagetest.Pipeline{}, suite.appC)
+	suite.Require().Nil(err)
+	suite.Assert().True(canExtract)
+	suite.Assert().Equal("", code)
+	suite.Assert().Equal(pageContent, reused.WebResponse.Content)
+	suite.Assert().Equal(1, suite.proxy.Crawls(pageURL))
+
+	// case: html downloaded by a previous source is used as is
+	downloaded := pagetest.Workflow(pageURL)
+	downloaded.WebResponse = types.WebResponse{Content: "<html></html>", Status: 200, Success: true}
+	canExtract, _, err = ReusePage(pageURL, downloaded, pagetest.Pipeline{}, suite.appC)
+	suite.Require().Nil(err)
+	suite.Assert().True(canExtract)
+	suite.Assert().Equal("<html></html>", downloaded.WebResponse.Content)
+	suite.Assert().Equal(1, suite.proxy.Crawls(pageURL))
+}
+
+// Test_02_ReusePageUnavailable - tests placeholders of pages missing in the cache service fail with PAGE_CONTENT_UNAVAILABLE
+func (suite *PageSuite) Test_02_ReusePageUnavailable() {
+	workflow := pagetest.Workflow(pageURL)
+	workflow.CacheKey = "ce/recrawl/" + pageURL
+	workflow.WebResponse = types.WebResponse{Content: "CACHE_WRITTEN: 57 bytes", Status: 200, Success: true}
+	canExtract, code, err := ReusePage(pageURL, workflow, pagetest.Pipeline{}, suite.appC)
+	suite.Assert().False(canExtract)
+	suite.Assert().Equal("PAGE_CONTENT_UNAVAILABLE", code)
+	suite.Assert().NotNil(err)
+
+	// case: pages which can't be crawled fail with the code of the pipeline, not read back
+	missing := pagetest.Workflow("https://shop.example.com/p/missing")
+	canExtract, code, err = ReusePage(missing.URL, missing, pagetest.Pipeline{}, suite.appC)
+	suite.Assert().False(canExtract)
+	suite.Assert().Equal("HTTP_404", code)
+	suite.Assert().NotNil(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPageSuite(t *testing.T) {
+	suite.Run(t, new(PageSuite))
+}
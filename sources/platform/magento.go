@@ -0,0 +1,102 @@
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Product fields requested from the Magento GraphQL API, with the variants of configurable products
+const magentoQuery = `query ($urlKey: String) {
+  products(filter: {url_key: {eq: $urlKey}}) {
+    items {
+      __typename sku name url_key stock_status
+      description { html }
+      media_gallery { url }
+      price_range { minimum_price { final_price { value currency } } }
+      ... on ConfigurableProduct {
+        variants {
+          attributes { code label }
+          product {
+            sku name stock_status
+            media_gallery { url }
+            price_range { minimum_price { final_price { value currency } } }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// Magento 2 stores serve products through the GraphQL API at /graphql, looked up by the url key of the page
+var magento = store{
+	name: "magento",
+	detect: func(page *productPage) bool {
+		return strings.Contains(page.content, "Magento_") || strings.Contains(page.content, "x-magento-init") ||
+			strings.Contains(page.content, "data-mage-init")
+	},
+	products: magentoProducts,
+}
+
+func magentoProducts(page *productPage, fetch fetcher) ([]hash, string, error) {
+	urlKey := strings.TrimSuffix(path.Base(page.url.Path), ".html")
+	if urlKey == "" || urlKey == "." || urlKey == "/" {
+		return nil, "PLATFORM_PRODUCT_NOT_FOUND", fmt.Errorf("%s: no magento url key in the page url", page.url)
+	}
+	body, _ := json.Marshal(hash{"query": magentoQuery, "variables": hash{"urlKey": urlKey}})
+
+	var response hash
+	if code, err := fetch("POST", page.endpoint("/graphql"), string(body), &response); err != nil {
+		return nil, code, err
+	}
+	if graphqlErrors := list(response, "errors"); len(graphqlErrors) > 0 {
+		return nil, "PLATFORM_INVALID_RESPONSE", fmt.Errorf("%s: magento graphql error: %s", page.url, str(graphqlErrors[0], "message"))
+	}
+	items := list(response, "data", "products", "items")
+	if len(items) == 0 {
+		return nil, "", nil
+	}
+	product := items[0]
+	productURL := fmt.Sprintf("%s://%s%s", page.url.Scheme, page.url.Host, page.url.Path)
+
+	variants := list(product, "variants")
+	if len(variants) == 0 {
+		return []hash{magentoProduct(page, product, product, nil, productURL)}, "", nil
+	}
+	products := make([]hash, 0)
+	for _, variant := range variants {
+		products = append(products, magentoProduct(page, product, get(variant, "product"), list(variant, "attributes"), productURL))
+	}
+	return products, "", nil
+}
+
+// magentoProduct maps a product or a variant of parent with its attributes
+func magentoProduct(page *productPage, parent, product interface{}, attributes []interface{}, productURL string) hash {
+	p := hash{
+		"sku":         str(product, "sku"),
+		"name":        str(product, "name"),
+		"description": text(str(parent, "description", "html")),
+		"url":         productURL,
+	}
+	for _, attribute := range attributes {
+		setOption(p, str(attribute, "code"), str(attribute, "label"))
+	}
+
+	images := make([]interface{}, 0)
+	for _, image := range append(list(product, "media_gallery"), list(parent, "media_gallery")...) {
+		images = appendImage(page, images, str(image, "url"))
+	}
+	p["images"] = images
+
+	finalPrice := get(product, "price_range", "minimum_price", "final_price")
+	if price, ok := number(finalPrice, "value"); ok {
+		var available *bool
+		if status := str(product, "stock_status"); status != "" {
+			a := status == "IN_STOCK"
+			available = &a
+		}
+		setPrice(p, formatPrice(price, 0), str(finalPrice, "currency"), available)
+	}
+	return compact(p)
+}
@@ -0,0 +1,156 @@
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	neturl "net/url"
+
+	"github.com/Semantics3/go-crawler/request"
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/types"
+	htmlutils "github.com/Semantics3/sem3-go-crawl-utils/html"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+)
+
+type hash = map[string]interface{}
+
+// Platform - implements Source interface, extracts products from the product JSON endpoints of stores running on
+// e-commerce platforms (Shopify, WooCommerce, Magento) fingerprinted from the page
+type Platform struct {
+	Name      string
+	ErrorCode string
+}
+
+func init() {
+	sources.Register(sources.Info{
+		Name:        "PLATFORM",
+		ErrorPrefix: "PLATFORM_",
+		Fields:      []string{"sku", "name", "description", "brand", "upc", "ean", "upc14", "color", "size", "url", "images", "listprice", "listprice_currency", "offers"},
+	}, func(name string) types.Sources {
+		return &Platform{Name: name}
+	})
+}
+
+// store is an e-commerce platform whose stores expose products through JSON endpoints
+type store struct {
+	name string
+	// detect checks whether the page was served by a store of the platform
+	detect func(page *productPage) bool
+	// products requests the product of the page from the store and maps its variants to sem3 products
+	products func(page *productPage, fetch fetcher) (products []hash, code string, err error)
+}
+
+// Platforms in the order they are fingerprinted
+var stores = []store{shopify, wooCommerce, magento}
+
+// productPage is the downloaded product page of a store
+type productPage struct {
+	url     *neturl.URL
+	content string
+}
+
+// endpoint returns the url of path on the store of the page
+func (page *productPage) endpoint(path string) string {
+	return fmt.Sprintf("%s://%s%s", page.url.Scheme, page.url.Host, path)
+}
+
+// fetcher requests a JSON endpoint of the store and decodes its response into dest
+type fetcher func(method, endpoint, body string, dest interface{}) (code string, err error)
+
+// GetName - return name
+func (p *Platform) GetName() string {
+	return p.Name
+}
+
+// GetErrorCode - return code of error encountered while processing request
+func (p *Platform) GetErrorCode() string {
+	return p.ErrorCode
+}
+
+// Request - Reuses the page already downloaded by a previous source of the crawl, downloads it otherwise
+func (p *Platform) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		p.ErrorCode = code
+	}(code)
+
+	return sources.ReusePage(url, workflow, pipeline, appC)
+}
+
+// Extract - Fingerprints the platform of the store from the page and requests the product from its JSON endpoint
+func (p *Platform) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		p.ErrorCode = code
+	}(code)
+
+	pageURL, err := neturl.Parse(url)
+	if err != nil {
+		return "PLATFORM_INVALID_URL", err
+	}
+	page := &productPage{url: pageURL, content: workflow.WebResponse.Content}
+	for _, s := range stores {
+		if !s.detect(page) {
+			continue
+		}
+		log.Printf("PLATFORM_DETECTED: (%s) %s\n", url, s.name)
+		products, code, err := s.products(page, storeFetcher(url, workflow, appC))
+		if err != nil {
+			workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: err.Error()}
+			return code, err
+		}
+		if len(products) == 0 {
+			code = "PLATFORM_NO_PRODUCT"
+			workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: fmt.Sprintf("%s store returned no product", s.name)}
+			return code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+		}
+		workflow.Data = types.ExtractionResponse{Products: products, Status: 1}
+		return "", nil
+	}
+	code = "PLATFORM_NOT_DETECTED"
+	workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: "page isn't served by a supported e-commerce platform"}
+	return code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+}
+
+// Normalize - products are mapped to the standard schema while extracting
+func (p *Platform) Normalize(workflow *types.CrawlWorkflow, appC *types.Config) {
+	return
+}
+
+// storeFetcher requests endpoints of the store through proxycloud as secondary web requests of the crawl
+func storeFetcher(url string, workflow *types.CrawlWorkflow, appC *types.Config) fetcher {
+	return func(method, endpoint, body string, dest interface{}) (code string, err error) {
+		jobParams := workflow.JobParams
+		if jobParams == nil {
+			jobParams = &ctypes.CrawlJobParams{}
+		}
+		site := ""
+		if workflow.DomainInfo != nil {
+			site = workflow.DomainInfo.DomainName
+		}
+		requestConfig := types.RequestConfig{
+			JobType:        workflow.ProductMetrics.JobType,
+			DomainInfo:     &ctypes.DomainInfo{DomainName: site},
+			ProductMetrics: workflow.ProductMetrics,
+			IsAjax:         true,
+			ParentUrl:      url,
+			Cookie:         workflow.WebResponse.Cookie,
+			Method:         method,
+			Body:           body,
+			Headers:        map[string]string{"Accept": "application/json"},
+			Session:        workflow.Session,
+			Geo:            workflow.Geo,
+		}
+		webResponse := request.VisitPage(endpoint, &requestConfig, jobParams, &workflow.ProductMetrics, appC)
+		if !htmlutils.IsSuccess(webResponse.Status) {
+			return "PLATFORM_REQUEST_FAILED", fmt.Errorf("%s %s failed with status %d: %s", method, endpoint, webResponse.Status, webResponse.Error)
+		}
+		if err = json.Unmarshal([]byte(webResponse.Content), dest); err != nil {
+			return "PLATFORM_INVALID_RESPONSE", fmt.Errorf("%s %s returned invalid json: %v", method, endpoint, err)
+		}
+		return "", nil
+	}
+}
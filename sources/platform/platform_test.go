@@ -0,0 +1,267 @@
+package platform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/tests/pagetest"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type PlatformSuite struct {
+	suite.Suite
+	store    *httptest.Server
+	proxy    *httptest.Server
+	cache    *pagetest.CacheService
+	requests []string
+	appC     *types.Config
+}
+
+// Endpoints of the local store
+var storeEndpoints = map[string]string{
+	"GET /products/tee": `<html><script src="https://cdn.shopify.com/s/shop.js"></script>
+		<script>Shopify.currency = {"active":"USD","rate":"1.0"};</script><h1>Linen Tee</h1></html>`,
+	"GET /products/tee.json": `{"product": {"title": "Linen Tee", "body_html": "<p>Soft <b>linen</b> tee.</p>", "vendor": "Acme",
+		"options": [{"name": "Color", "position": 1}, {"name": "Size", "position": 2}],
+		"images": [{"id": 1, "src": "//cdn.shopify.com/tee-white.jpg"}, {"id": 2, "src": "//cdn.shopify.com/tee-black.jpg"}],
+		"variants": [
+			{"id": 101, "title": "White / M", "sku": "TEE-W-M", "barcode": "012345678905", "price": "19.00", "option1": "White", "option2": "M", "image_id": 1},
+			{"id": 102, "title": "Black / L", "sku": "TEE-B-L", "barcode": "", "price": "21.50", "option1": "Black", "option2": "L", "image_id": 2}
+		]}}`,
+	"GET /products/mug.js": `{"title": "Enamel Mug", "description": "Camp mug", "vendor": "Acme", "options": ["Title"],
+		"images": ["//cdn.shopify.com/mug.jpg"],
+		"variants": [{"id": 201, "title": "Default Title", "sku": "", "price": 1250, "available": false, "option1": "Default Title"}]}`,
+	"GET /wp-json/wc/store/v1/products/42": `{"id": 42, "name": "Wool Scarf", "sku": "SCARF", "permalink": "STORE/product/wool-scarf/",
+		"description": "<p>Merino wool</p>", "brands": [{"name": "Knitco"}], "images": [{"src": "STORE/scarf.jpg"}],
+		"prices": {"price": "2500", "currency_code": "EUR", "currency_minor_unit": 2}, "is_in_stock": true,
+		"variations": [{"id": 43, "attributes": [{"name": "Colour", "value": "Red"}]}, {"id": 44, "attributes": [{"name": "Colour", "value": "Blue"}]}]}`,
+	"GET /wp-json/wc/store/v1/products/43": `{"id": 43, "name": "Wool Scarf - Red", "sku": "SCARF-RED", "permalink": "STORE/product/wool-scarf/?attribute_pa_colour=red",
+		"description": "", "images": [{"src": "STORE/scarf-red.jpg"}],
+		"prices": {"price": "2500", "currency_code": "EUR", "currency_minor_unit": 2}, "is_in_stock": true}`,
+	"GET /wp-json/wc/store/v1/products/44": `{"id": 44, "name": "Wool Scarf - Blue", "sku": "SCARF-BLUE", "permalink": "STORE/product/wool-scarf/?attribute_pa_colour=blue",
+		"description": "", "images": [],
+		"prices": {"price": "2750", "currency_code": "EUR", "currency_minor_unit": 2}, "is_in_stock": false}`,
+	"POST /graphql": `{"data": {"products": {"items": [{"__typename": "ConfigurableProduct", "sku": "MH01", "name": "Chaz Hoodie",
+		"description": {"html": "<p>Fleece hoodie</p>"}, "media_gallery": [{"url": "STORE/media/mh01.jpg"}], "stock_status": "IN_STOCK",
+		"price_range": {"minimum_price": {"final_price": {"value": 52, "currency": "USD"}}},
+		"variants": [
+			{"attributes": [{"code": "color", "label": "Gray"}, {"code": "size", "label": "XS"}], "product": {"sku": "MH01-XS-Gray", "name": "Chaz Hoodie-XS-Gray",
+				"stock_status": "IN_STOCK", "media_gallery": [], "price_range": {"minimum_price": {"final_price": {"value": 52, "currency": "USD"}}}}},
+			{"attributes": [{"code": "color", "label": "Orange"}, {"code": "size", "label": "S"}], "product": {"sku": "MH01-S-Orange", "name": "Chaz Hoodie-S-Orange",
+				"stock_status": "OUT_OF_STOCK", "media_gallery": [{"url": "STORE/media/mh01-orange.jpg"}], "price_range": {"minimum_price": {"final_price": {"value": 49.5, "currency": "USD"}}}}}
+		]}]}}}`,
+}
+
+// SetupTest - Called before each test
+// Starts a local store and a proxycloud router forwarding the requests of the crawler to it
+// Pages requested with a cache key are written to the cache service like proxycloud does
+func (suite *PlatformSuite) SetupTest() {
+	suite.requests = nil
+	suite.cache = pagetest.NewCacheService()
+	suite.store = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		endpoint := r.Method + " " + r.URL.Path
+		suite.requests = append(suite.requests, endpoint+" "+string(body))
+		response, ok := storeEndpoints[endpoint]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.Replace(response, "STORE", suite.store.URL, -1)))
+	}))
+	suite.proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			URL           string `json:"url"`
+			Method        string `json:"method"`
+			Payload       string `json:"payload"`
+			RequestPolicy string `json:"request_policy"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload.Method == "" {
+			payload.Method = "GET"
+		}
+		req, _ := http.NewRequest(payload.Method, payload.URL, strings.NewReader(payload.Payload))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		defer resp.Body.Close()
+		content, _ := ioutil.ReadAll(resp.Body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"url":        payload.URL,
+			"content":    suite.cache.Respond(payload.RequestPolicy, payload.URL, string(content), resp.StatusCode),
+			"success":    resp.StatusCode == http.StatusOK,
+			"statusCode": resp.StatusCode,
+		})
+	}))
+	suite.appC = &types.Config{
+		ConfigData:   &types.ConfigData{ProxyRouter: strings.TrimPrefix(suite.proxy.URL, "http://")},
+		StatsManager: &types.StatsManager{CrawlMetricsChannel: make(chan types.CrawlMetrics, 100)},
+		PageCache:    suite.cache,
+	}
+}
+
+// TearDownTest - Called after each test
+func (suite *PlatformSuite) TearDownTest() {
+	suite.proxy.Close()
+	suite.store.Close()
+}
+
+// extract runs the source on a page of the local store downloaded by a previous source
+func (suite *PlatformSuite) extract(path, content string) (*types.CrawlWorkflow, string, error) {
+	url := suite.store.URL + path
+	source := &Platform{Name: "PLATFORM"}
+	workflow := &types.CrawlWorkflow{
+		URL:         url,
+		DomainInfo:  &ctypes.DomainInfo{DomainName: "store.example.com"},
+		WebResponse: types.WebResponse{Content: content, Success: true},
+	}
+	canExtract, code, err := source.Request(url, workflow, nil, suite.appC)
+	suite.Require().True(canExtract)
+	suite.Require().Nil(err)
+	code, err = source.Extract(url, workflow, nil, suite.appC)
+	suite.Assert().Equal(code, source.GetErrorCode())
+	return workflow, code, err
+}
+
+// Test_01_Shopify - tests every variant of a Shopify product is mapped from its .json endpoint
+func (suite *PlatformSuite) Test_01_Shopify() {
+	page := `<html><script src="https://cdn.shopify.com/s/shop.js"></script>
+		<script>Shopify.currency = {"active":"USD","rate":"1.0"};</script></html>`
+	workflow, code, err := suite.extract("/products/tee", page)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Assert().Equal(1, workflow.Data.Status)
+	suite.Require().Len(workflow.Data.Products, 2)
+
+	white := workflow.Data.Products[0]
+	suite.Assert().Equal("TEE-W-M", white["sku"])
+	suite.Assert().Equal("Linen Tee - White / M", white["name"])
+	suite.Assert().Equal("Soft linen tee.", white["description"])
+	suite.Assert().Equal("Acme", white["brand"])
+	suite.Assert().Equal("White", white["color"])
+	suite.Assert().Equal("M", white["size"])
+	suite.Assert().Equal("012345678905", white["upc"])
+	suite.Assert().Equal(suite.store.URL+"/products/tee?variant=101", white["url"])
+	suite.Assert().Equal([]interface{}{"http://cdn.shopify.com/tee-white.jpg", "http://cdn.shopify.com/tee-black.jpg"}, white["images"])
+	suite.Assert().Equal("19.00", white["listprice"])
+	suite.Assert().Equal("USD", white["listprice_currency"])
+	suite.Assert().Equal([]interface{}{map[string]interface{}{"price": "19.00", "currency": "USD"}}, white["offers"])
+
+	black := workflow.Data.Products[1]
+	suite.Assert().Equal("TEE-B-L", black["sku"])
+	suite.Assert().Equal("21.50", black["listprice"])
+	suite.Assert().Equal("http://cdn.shopify.com/tee-black.jpg", black["images"].([]interface{})[0])
+	suite.Assert().NotContains(black, "upc")
+
+	// case: stores without the .json endpoint are requested at .js, with prices in cents
+	workflow, code, err = suite.extract("/collections/kitchen/products/mug", page)
+	suite.Require().Nil(err)
+	suite.Require().Len(workflow.Data.Products, 1)
+	mug := workflow.Data.Products[0]
+	suite.Assert().Equal("Enamel Mug", mug["name"])
+	suite.Assert().Equal("201", mug["sku"])
+	suite.Assert().Equal("12.50", mug["listprice"])
+	suite.Assert().Equal([]interface{}{map[string]interface{}{"price": "12.50", "currency": "USD", "availability": "Out of Stock"}}, mug["offers"])
+	suite.Assert().Equal([]string{"GET /products/mug.json ", "GET /products/mug.js "}, suite.requests[len(suite.requests)-2:])
+}
+
+// Test_02_WooCommerce - tests the variations of a variable WooCommerce product are requested from the Store API
+func (suite *PlatformSuite) Test_02_WooCommerce() {
+	page := `<html><link rel="stylesheet" href="/wp-content/plugins/woocommerce/assets/css/woocommerce.css">
+		<body class="product-template-default single single-product postid-42 woocommerce"></body></html>`
+	workflow, code, err := suite.extract("/product/wool-scarf/", page)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Require().Len(workflow.Data.Products, 2)
+
+	red := workflow.Data.Products[0]
+	suite.Assert().Equal("SCARF-RED", red["sku"])
+	suite.Assert().Equal("Wool Scarf - Red", red["name"])
+	suite.Assert().Equal("Merino wool", red["description"])
+	suite.Assert().Equal("Knitco", red["brand"])
+	suite.Assert().Equal("Red", red["color"])
+	suite.Assert().Equal([]interface{}{suite.store.URL + "/scarf-red.jpg", suite.store.URL + "/scarf.jpg"}, red["images"])
+	suite.Assert().Equal("25.00", red["listprice"])
+	suite.Assert().Equal("EUR", red["listprice_currency"])
+
+	blue := workflow.Data.Products[1]
+	suite.Assert().Equal("Blue", blue["color"])
+	suite.Assert().Equal([]interface{}{map[string]interface{}{"price": "27.50", "currency": "EUR", "availability": "Out of Stock"}}, blue["offers"])
+
+	// case: pages without the id of the product fail with PLATFORM_PRODUCT_NOT_FOUND
+	_, code, err = suite.extract("/shop/", `<html><body class="woocommerce archive"></body></html>`)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("PLATFORM_PRODUCT_NOT_FOUND", code)
+}
+
+// Test_03_Magento - tests the variants of a configurable Magento product are requested from the GraphQL API
+func (suite *PlatformSuite) Test_03_Magento() {
+	page := `<html><script type="text/x-magento-init">{"*": {"Magento_Ui/js/core/app": {}}}</script></html>`
+	workflow, code, err := suite.extract("/chaz-hoodie.html", page)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Require().Len(suite.requests, 1)
+	suite.Assert().Contains(suite.requests[0], `"variables":{"urlKey":"chaz-hoodie"}`)
+	suite.Require().Len(workflow.Data.Products, 2)
+
+	gray := workflow.Data.Products[0]
+	suite.Assert().Equal("MH01-XS-Gray", gray["sku"])
+	suite.Assert().Equal("Gray", gray["color"])
+	suite.Assert().Equal("XS", gray["size"])
+	suite.Assert().Equal("Fleece hoodie", gray["description"])
+	suite.Assert().Equal(suite.store.URL+"/chaz-hoodie.html", gray["url"])
+	suite.Assert().Equal([]interface{}{suite.store.URL + "/media/mh01.jpg"}, gray["images"])
+	suite.Assert().Equal([]interface{}{map[string]interface{}{"price": "52.00", "currency": "USD", "availability": "Available"}}, gray["offers"])
+
+	orange := workflow.Data.Products[1]
+	suite.Assert().Equal("49.50", orange["listprice"])
+	suite.Assert().Equal("Out of Stock", orange["offers"].([]interface{})[0].(map[string]interface{})["availability"])
+}
+
+// Test_04_Errors - tests the error codes of pages the source can't extract
+func (suite *PlatformSuite) Test_04_Errors() {
+	// case: pages of other platforms fail with PLATFORM_NOT_DETECTED without requesting the store
+	workflow, code, err := suite.extract("/item/1", `<html><body>Hand made</body></html>`)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("PLATFORM_NOT_DETECTED", code)
+	suite.Assert().Equal(0, workflow.Data.Status)
+	suite.Assert().Empty(suite.requests)
+
+	// case: products missing from the store fail with PLATFORM_REQUEST_FAILED
+	_, code, err = suite.extract("/products/gone", `<html><script>Shopify.shop = "acme.myshopify.com";</script></html>`)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("PLATFORM_REQUEST_FAILED", code)
+}
+
+// Test_05_CrawledPage - tests the platform is fingerprinted from the html of a page crawled by the source
+// Crawled pages are written to the cache service and answered with its placeholder
+func (suite *PlatformSuite) Test_05_CrawledPage() {
+	url := suite.store.URL + "/products/tee"
+	source := &Platform{Name: "PLATFORM"}
+	workflow := pagetest.Workflow(url)
+	canExtract, code, err := source.Request(url, workflow, pagetest.Pipeline{}, suite.appC)
+	suite.Require().Nil(err)
+	suite.Assert().Equal(true, canExtract)
+	suite.Assert().Contains(workflow.WebResponse.Content, "cdn.shopify.com")
+
+	code, err = source.Extract(url, workflow, pagetest.Pipeline{}, suite.appC)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Require().Len(workflow.Data.Products, 2)
+	suite.Assert().Equal("TEE-W-M", workflow.Data.Products[0]["sku"])
+	suite.Assert().Equal([]string{"GET /products/tee ", "GET /products/tee.json "}, suite.requests)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPlatformSuite(t *testing.T) {
+	suite.Run(t, new(PlatformSuite))
+}
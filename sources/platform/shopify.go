@@ -0,0 +1,125 @@
+package platform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	shopifyHandleRegex    = regexp.MustCompile(`/products/([^/?#.]+)`)
+	shopifyCanonicalRegex = regexp.MustCompile(`<link[^>]+rel=["']canonical["'][^>]+href=["'][^"']*/products/([^/?#."']+)`)
+	shopifyCurrencyRegex  = regexp.MustCompile(`Shopify\.currency\s*=\s*\{[^}]*"active"\s*:\s*"([A-Z]{3})"`)
+	metaCurrencyRegex     = regexp.MustCompile(`<meta[^>]+property=["'](?:og|product):price:currency["'][^>]+content=["']([A-Z]{3})["']`)
+)
+
+// Shopify stores serve every product as JSON at /products/<handle>.json (prices as decimal strings), and at
+// /products/<handle>.js for the storefront scripts (prices in cents, with the availability of the variants)
+var shopify = store{
+	name: "shopify",
+	detect: func(page *productPage) bool {
+		return strings.Contains(page.content, "cdn.shopify.com") || strings.Contains(page.content, "Shopify.shop") ||
+			strings.Contains(page.content, "ShopifyAnalytics")
+	},
+	products: shopifyProducts,
+}
+
+func shopifyProducts(page *productPage, fetch fetcher) ([]hash, string, error) {
+	handle := ""
+	if match := shopifyHandleRegex.FindStringSubmatch(page.url.Path); match != nil {
+		handle = match[1]
+	} else if match := shopifyCanonicalRegex.FindStringSubmatch(page.content); match != nil {
+		handle = match[1]
+	}
+	if handle == "" {
+		return nil, "PLATFORM_PRODUCT_NOT_FOUND", fmt.Errorf("%s: no shopify product handle in the page", page.url)
+	}
+
+	currency := ""
+	if match := shopifyCurrencyRegex.FindStringSubmatch(page.content); match != nil {
+		currency = match[1]
+	} else if match := metaCurrencyRegex.FindStringSubmatch(page.content); match != nil {
+		currency = match[1]
+	}
+
+	var response hash
+	code, err := fetch("GET", page.endpoint("/products/"+handle+".json"), "", &response)
+	if err == nil {
+		return shopifyVariants(page, get(response, "product"), currency, false), "", nil
+	}
+	var product hash
+	if _, jsErr := fetch("GET", page.endpoint("/products/"+handle+".js"), "", &product); jsErr != nil {
+		return nil, code, err
+	}
+	return shopifyVariants(page, product, currency, true), "", nil
+}
+
+// shopifyVariants maps the variants of a product of either endpoint, cents tells prices are in cents (.js)
+func shopifyVariants(page *productPage, product interface{}, currency string, cents bool) []hash {
+	// option names are strings (.js) or objects with a name (.json)
+	options := make([]string, 0)
+	for _, option := range list(product, "options") {
+		if name, ok := option.(string); ok {
+			options = append(options, name)
+		} else {
+			options = append(options, str(option, "name"))
+		}
+	}
+	images := make([]interface{}, 0)
+	imagesByID := make(map[string]string)
+	for _, image := range list(product, "images") {
+		if src, ok := image.(string); ok {
+			images = appendImage(page, images, src)
+			continue
+		}
+		images = appendImage(page, images, str(image, "src"))
+		imagesByID[str(image, "id")] = str(image, "src")
+	}
+	description := str(product, "body_html")
+	if description == "" {
+		description = str(product, "description")
+	}
+
+	products := make([]hash, 0)
+	for _, variant := range list(product, "variants") {
+		p := hash{
+			"name":        variantName(str(product, "title"), str(variant, "title")),
+			"description": text(description),
+			"brand":       str(product, "vendor"),
+			"url":         fmt.Sprintf("%s://%s%s?variant=%s", page.url.Scheme, page.url.Host, page.url.Path, str(variant, "id")),
+		}
+		p["sku"] = str(variant, "sku")
+		if p["sku"] == "" {
+			p["sku"] = str(variant, "id")
+		}
+		setGTIN(p, str(variant, "barcode"))
+		for i, name := range options {
+			setOption(p, name, str(variant, fmt.Sprintf("option%d", i+1)))
+		}
+
+		variantImages := make([]interface{}, 0)
+		if src := str(variant, "featured_image", "src"); src != "" {
+			variantImages = appendImage(page, variantImages, src)
+		} else if src := imagesByID[str(variant, "image_id")]; src != "" {
+			variantImages = appendImage(page, variantImages, src)
+		}
+		for _, image := range images {
+			variantImages = appendImage(page, variantImages, image.(string))
+		}
+		p["images"] = variantImages
+
+		if price, ok := number(variant, "price"); ok {
+			minorUnit := 0
+			if cents {
+				minorUnit = 2
+			}
+			var available *bool
+			if a, ok := get(variant, "available").(bool); ok {
+				available = &a
+			}
+			setPrice(p, formatPrice(price, minorUnit), currency, available)
+		}
+		products = append(products, compact(p))
+	}
+	return products
+}
@@ -0,0 +1,157 @@
+package platform
+
+import (
+	"fmt"
+	"html"
+	"math"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// get returns the value at path in decoded JSON, nil when missing
+func get(value interface{}, path ...string) interface{} {
+	for _, key := range path {
+		object, ok := value.(hash)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// str returns the value at path as a trimmed string, numbers are formatted without exponent
+func str(value interface{}, path ...string) string {
+	switch v := get(value, path...).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// list returns the array at path, nil when missing
+func list(value interface{}, path ...string) []interface{} {
+	items, _ := get(value, path...).([]interface{})
+	return items
+}
+
+// number returns the value at path as a number, numbers in strings are parsed
+func number(value interface{}, path ...string) (float64, bool) {
+	switch v := get(value, path...).(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// formatPrice formats an amount in minor units (eg. cents) of a currency with the given number of decimals
+func formatPrice(amount float64, minorUnit int) string {
+	return fmt.Sprintf("%.2f", amount/math.Pow10(minorUnit))
+}
+
+// text strips the markup of an html description
+func text(description string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagRegex.ReplaceAllString(description, " "))), " ")
+}
+
+// absolute resolves a possibly relative or protocol relative url against the page
+func absolute(page *productPage, ref string) string {
+	u, err := neturl.Parse(strings.TrimSpace(ref))
+	if err != nil || ref == "" {
+		return ""
+	}
+	return page.url.ResolveReference(u).String()
+}
+
+// appendImage adds the absolute url of an image once
+func appendImage(page *productPage, images []interface{}, image string) []interface{} {
+	image = absolute(page, image)
+	if image == "" {
+		return images
+	}
+	for _, existing := range images {
+		if existing == image {
+			return images
+		}
+	}
+	return append(images, image)
+}
+
+// setGTIN sets the upc, ean or upc14 of a product from a barcode, by its length
+func setGTIN(product hash, barcode string) {
+	barcode = strings.TrimSpace(barcode)
+	if _, err := strconv.ParseUint(barcode, 10, 64); err != nil {
+		return
+	}
+	switch len(barcode) {
+	case 12:
+		product["upc"] = barcode
+	case 13:
+		product["ean"] = barcode
+	case 14:
+		product["upc14"] = barcode
+	}
+}
+
+// setOption sets the color or size of a product from a named variant option
+func setOption(product hash, name, value string) {
+	if value == "" {
+		return
+	}
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "pa_"))
+	switch {
+	case strings.Contains(name, "color") || strings.Contains(name, "colour"):
+		product["color"] = value
+	case strings.Contains(name, "size"):
+		product["size"] = value
+	}
+}
+
+// setPrice sets the list price and the offer of a product
+func setPrice(product hash, price, currency string, available *bool) {
+	if price == "" {
+		return
+	}
+	product["listprice"] = price
+	offer := hash{"price": price}
+	if currency != "" {
+		product["listprice_currency"] = currency
+		offer["currency"] = currency
+	}
+	if available != nil {
+		offer["availability"] = "Out of Stock"
+		if *available {
+			offer["availability"] = "Available"
+		}
+	}
+	product["offers"] = []interface{}{offer}
+}
+
+// variantName appends the title of a variant to the name of its product
+func variantName(name, variant string) string {
+	if variant == "" || strings.EqualFold(variant, "Default Title") || strings.Contains(name, variant) {
+		return name
+	}
+	return name + " - " + variant
+}
+
+// compact removes the empty fields of a product
+func compact(product hash) hash {
+	for field, value := range product {
+		if value == "" {
+			delete(product, field)
+		}
+	}
+	return product
+}
@@ -0,0 +1,104 @@
+package platform
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Variations of a variable product requested at most
+const maxVariations = 30
+
+// Where the id of the product is found on WooCommerce product pages, in order
+var wooCommerceIDRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`<link[^>]+rel=["']shortlink["'][^>]+href=["'][^"']*[?&]p=(\d+)`),
+	regexp.MustCompile(`\bpostid-(\d+)\b`),
+	regexp.MustCompile(`name=["']add-to-cart["'][^>]*value=["'](\d+)["']`),
+	regexp.MustCompile(`data-product_id=["'](\d+)["']`),
+}
+
+// WooCommerce stores serve products through the Store API at /wp-json/wc/store/v1/products/<id>, with prices in minor
+// units of the currency. Variable products list the ids of their variations, which are requested as products
+var wooCommerce = store{
+	name: "woocommerce",
+	detect: func(page *productPage) bool {
+		return strings.Contains(page.content, "woocommerce") || strings.Contains(page.content, "wc-block")
+	},
+	products: wooCommerceProducts,
+}
+
+func wooCommerceProducts(page *productPage, fetch fetcher) ([]hash, string, error) {
+	id := ""
+	for _, regex := range wooCommerceIDRegexes {
+		if match := regex.FindStringSubmatch(page.content); match != nil {
+			id = match[1]
+			break
+		}
+	}
+	if id == "" {
+		return nil, "PLATFORM_PRODUCT_NOT_FOUND", fmt.Errorf("%s: no woocommerce product id in the page", page.url)
+	}
+
+	var product hash
+	if code, err := fetch("GET", page.endpoint("/wp-json/wc/store/v1/products/"+id), "", &product); err != nil {
+		return nil, code, err
+	}
+	variations := list(product, "variations")
+	if len(variations) == 0 {
+		return []hash{wooCommerceProduct(page, product, product, nil)}, "", nil
+	}
+
+	products := make([]hash, 0)
+	for i, variation := range variations {
+		if i == maxVariations {
+			log.Printf("PLATFORM_VARIATIONS_SKIPPED: (%s) %d of %d variations requested\n", page.url, maxVariations, len(variations))
+			break
+		}
+		var variant hash
+		if _, err := fetch("GET", page.endpoint("/wp-json/wc/store/v1/products/"+str(variation, "id")), "", &variant); err != nil {
+			log.Printf("PLATFORM_VARIATION_FAILED: (%s) %v\n", page.url, err)
+			continue
+		}
+		products = append(products, wooCommerceProduct(page, product, variant, list(variation, "attributes")))
+	}
+	return products, "", nil
+}
+
+// wooCommerceProduct maps a product or a variation of parent with its attributes
+func wooCommerceProduct(page *productPage, parent, product hash, attributes []interface{}) hash {
+	p := hash{
+		"sku":         str(product, "sku"),
+		"name":        str(product, "name"),
+		"description": text(str(product, "description")),
+		"url":         str(product, "permalink"),
+	}
+	if p["sku"] == "" {
+		p["sku"] = str(product, "id")
+	}
+	if p["description"] == "" {
+		p["description"] = text(str(parent, "description"))
+	}
+	if brands := list(parent, "brands"); len(brands) > 0 {
+		p["brand"] = str(brands[0], "name")
+	}
+	for _, attribute := range attributes {
+		setOption(p, str(attribute, "name"), str(attribute, "value"))
+	}
+
+	images := make([]interface{}, 0)
+	for _, image := range append(list(product, "images"), list(parent, "images")...) {
+		images = appendImage(page, images, str(image, "src"))
+	}
+	p["images"] = images
+
+	if price, ok := number(product, "prices", "price"); ok {
+		minorUnit, _ := number(product, "prices", "currency_minor_unit")
+		var available *bool
+		if a, ok := get(product, "is_in_stock").(bool); ok {
+			available = &a
+		}
+		setPrice(p, formatPrice(price, int(minorUnit)), str(product, "prices", "currency_code"), available)
+	}
+	return compact(p)
+}
@@ -0,0 +1,105 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/Semantics3/go-crawler/types"
+)
+
+// Constructor creates a data source named name
+type Constructor func(name string) types.Sources
+
+// Info describes a registered data source
+type Info struct {
+	Name string `json:"name"`
+	// Prefix of the error codes of the source (eg. AMAZON_)
+	ErrorPrefix string `json:"error_prefix,omitempty"`
+	// Environment variables the source needs to make requests
+	RequiredEnv []string `json:"required_env,omitempty"`
+	// Cost of a request to the source (overridden by source_costs in config)
+	Cost float64 `json:"cost"`
+	// Product fields the source supplies, empty when it supplies any field
+	Fields []string `json:"fields,omitempty"`
+	// Supervised sources extract with the wrapper of the domain, they need a sitedetail
+	Supervised bool `json:"supervised"`
+}
+
+type registered struct {
+	info        Info
+	constructor Constructor
+}
+
+var registry = struct {
+	sync.RWMutex
+	sources map[string]registered
+}{sources: make(map[string]registered)}
+
+// Register makes a data source available by name, registering a name again replaces the source
+// Sources register themselves on init, see sources/all
+func Register(info Info, constructor Constructor) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.sources[info.Name] = registered{info: info, constructor: constructor}
+}
+
+// Unregister removes a data source (used by tests registering fake sources)
+func Unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.sources, name)
+}
+
+// New creates the data source registered under name
+func New(name string) (types.Sources, error) {
+	registry.RLock()
+	source, ok := registry.sources[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("data source %s is not registered", name)
+	}
+	return source.constructor(name), nil
+}
+
+// Lookup returns the description of the data source registered under name
+func Lookup(name string) (Info, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	source, ok := registry.sources[name]
+	return source.info, ok
+}
+
+// Registered returns the descriptions of all the registered data sources, sorted by name
+func Registered() []Info {
+	registry.RLock()
+	defer registry.RUnlock()
+	infos := make([]Info, 0, len(registry.sources))
+	for _, source := range registry.sources {
+		infos = append(infos, source.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// MissingEnv returns the required environment variables of the source which are not set
+func (info Info) MissingEnv() []string {
+	missing := make([]string, 0)
+	for _, env := range info.RequiredEnv {
+		if os.Getenv(env) == "" {
+			missing = append(missing, env)
+		}
+	}
+	return missing
+}
+
+// OnlySupervised is true when all the data sources are supervised (no data sources means WRAPPER)
+func OnlySupervised(names []string) bool {
+	for _, name := range names {
+		if info, ok := Lookup(name); !ok || !info.Supervised {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package spastate
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	scriptPattern = regexp.MustCompile(`(?is)<script([^>]*)>(.*?)</script>`)
+	jsonTypeRegex = regexp.MustCompile(`(?i)type\s*=\s*["']?application/json`)
+	idRegex       = regexp.MustCompile(`(?i)\bid\s*=\s*["']([^"']+)["']`)
+	// Assignments of state to globals, eg. window.__INITIAL_STATE__ = {...}, window["__APOLLO_STATE__"] = JSON.parse("...")
+	assignmentRegex = regexp.MustCompile(`(?:(?:window|self|globalThis)\s*(?:\.\s*([A-Za-z_$][\w$]*)|\[\s*["']([^"']+)["']\s*\])|(?:var|let|const)\s+(__[A-Z0-9_]+__))\s*=\s*`)
+	scriptWrappers  = strings.NewReplacer("/*<![CDATA[*/", "", "/*]]>*/", "", "<!--", "", "-->", "", "<![CDATA[", "", "]]>", "")
+)
+
+// Nuxt 3 serializes its payload with devalue, a flat array of values referencing each other by index
+const nuxtDataBlob = "__NUXT_DATA__"
+
+// Blob is a state object embedded in a page, named by the id of its script (eg. __NEXT_DATA__) or the global it is
+// assigned to (eg. __INITIAL_STATE__, __APOLLO_STATE__)
+type Blob struct {
+	Name  string
+	Value interface{}
+}
+
+// FindBlobs returns the state blobs of the html of pageURL, JSON scripts with an id and JSON (or JSON.parse of a
+// string) assigned to globals
+// State built by scripts (eg. window.__NUXT__=(function(a){return {...}}(1)) of Nuxt 2) isn't JSON and is skipped
+func FindBlobs(content, pageURL string) []Blob {
+	blobs := make([]Blob, 0)
+	for _, match := range scriptPattern.FindAllStringSubmatch(content, -1) {
+		attributes, body := match[1], strings.TrimSpace(scriptWrappers.Replace(match[2]))
+		if body == "" {
+			continue
+		}
+		if jsonTypeRegex.MatchString(attributes) {
+			id := idRegex.FindStringSubmatch(attributes)
+			if id == nil {
+				continue
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(body), &value); err != nil {
+				log.Printf("SPA_STATE_INVALID_BLOB: (%s) %s: %v\n", pageURL, id[1], err)
+				continue
+			}
+			if values, ok := value.([]interface{}); ok && id[1] == nuxtDataBlob {
+				value = unflatten(values)
+			}
+			blobs = append(blobs, Blob{Name: id[1], Value: value})
+			continue
+		}
+		for _, assignment := range assignmentRegex.FindAllStringSubmatchIndex(body, -1) {
+			name := ""
+			for group := 1; group <= 3; group++ {
+				if assignment[2*group] >= 0 {
+					name = body[assignment[2*group]:assignment[2*group+1]]
+				}
+			}
+			value, err := parseValue(body[assignment[1]:])
+			if err != nil {
+				log.Printf("SPA_STATE_INVALID_BLOB: (%s) %s: %v\n", pageURL, name, err)
+				continue
+			}
+			if value != nil {
+				blobs = append(blobs, Blob{Name: name, Value: value})
+			}
+		}
+	}
+	return blobs
+}
+
+// parseValue parses the JSON object or array at the start of a script, or the string given to JSON.parse
+// Other values (eg. window.dataLayer = window.dataLayer || []) aren't state and return nil
+func parseValue(script string) (interface{}, error) {
+	if strings.HasPrefix(script, "JSON.parse(") {
+		raw, err := jsString(strings.TrimSpace(strings.TrimPrefix(script, "JSON.parse(")))
+		if err != nil {
+			return nil, err
+		}
+		script = raw
+	} else if !strings.HasPrefix(script, "{") && !strings.HasPrefix(script, "[") {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.NewDecoder(strings.NewReader(script)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// jsString decodes the javascript string literal at the start of a script
+func jsString(script string) (string, error) {
+	if script == "" || !strings.ContainsRune(`"'`+"`", rune(script[0])) {
+		return "", fmt.Errorf("JSON.parse of a non literal")
+	}
+	quote := script[0]
+	var b strings.Builder
+	for i := 1; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c != '\\':
+			b.WriteByte(c)
+		case i+1 < len(script):
+			i++
+			switch e := script[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case '0':
+				b.WriteByte(0)
+			case 'x', 'u':
+				size := 2
+				if e == 'u' {
+					size = 4
+				}
+				if i+size >= len(script) {
+					return "", fmt.Errorf("invalid escape in string")
+				}
+				code, err := strconv.ParseUint(script[i+1:i+1+size], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape in string: %v", err)
+				}
+				b.WriteRune(rune(code))
+				i += size
+			default:
+				b.WriteByte(e)
+			}
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// unflatten rebuilds the value serialized by devalue: objects and arrays hold the indexes of their values, reactive
+// wrappers and other types are arrays starting with their name (eg. ["Reactive", 3], ["Date", "2020-01-01"])
+func unflatten(values []interface{}) interface{} {
+	hydrated := make(map[int]interface{})
+	var hydrate func(index interface{}) interface{}
+	hydrate = func(index interface{}) interface{} {
+		n, ok := index.(float64)
+		i := int(n)
+		if !ok || i < 0 || i >= len(values) {
+			return nil
+		}
+		if value, ok := hydrated[i]; ok {
+			return value
+		}
+		switch v := values[i].(type) {
+		case []interface{}:
+			if name, ok := firstString(v); ok {
+				switch name {
+				case "Date", "RegExp", "BigInt":
+					hydrated[i] = nil
+					if len(v) > 1 {
+						hydrated[i] = v[1]
+					}
+				case "Set":
+					set := make([]interface{}, 0, len(v)-1)
+					for _, item := range v[1:] {
+						set = append(set, hydrate(item))
+					}
+					hydrated[i] = set
+				case "Map":
+					object := make(hash)
+					hydrated[i] = object
+					for j := 1; j+1 < len(v); j += 2 {
+						object[fmt.Sprint(hydrate(v[j]))] = hydrate(v[j+1])
+					}
+				case "null":
+					// objects without prototype list their keys with the indexes of their values
+					object := make(hash)
+					hydrated[i] = object
+					for j := 1; j+1 < len(v); j += 2 {
+						object[fmt.Sprint(v[j])] = hydrate(v[j+1])
+					}
+				default:
+					// Reactive, ShallowReactive, Ref, ShallowRef and payload types of plugins wrap a single value
+					hydrated[i] = nil
+					if len(v) > 1 {
+						hydrated[i] = hydrate(v[1])
+					}
+				}
+				return hydrated[i]
+			}
+			array := make([]interface{}, len(v))
+			hydrated[i] = array
+			for j, item := range v {
+				array[j] = hydrate(item)
+			}
+			return array
+		case hash:
+			object := make(hash)
+			hydrated[i] = object
+			for key, item := range v {
+				object[key] = hydrate(item)
+			}
+			return object
+		default:
+			hydrated[i] = v
+			return v
+		}
+	}
+	return hydrate(float64(0))
+}
+
+func firstString(values []interface{}) (string, bool) {
+	if len(values) == 0 {
+		return "", false
+	}
+	s, ok := values[0].(string)
+	return s, ok
+}
@@ -0,0 +1,124 @@
+package spastate
+
+import (
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"sort"
+
+	"github.com/Semantics3/go-crawler/sources/jsonld"
+	"github.com/Semantics3/go-crawler/sources/mapping"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+)
+
+type hash = map[string]interface{}
+
+// WrapperContent is the name of the wrapper content whose entities map state blobs to products
+const WrapperContent = "state"
+
+// Mapping maps the products of a state blob to sem3 fields, declared by wrappers as entities of their "state" content:
+//
+//	{"blob": "__NEXT_DATA__", "products": "$.props.pageProps.product.variants[*]",
+//	 "fields": {"name": "$.props.pageProps.product.name", "sku": "@.sku", "price": "@.price.amount", "images": "@.images[*].url"}}
+//
+// Products is the path of the products in the blob (the blob itself when empty), paths of fields are relative to the
+// product (@) or absolute ($), see mapping.Fields
+type Mapping struct {
+	Blob     string            `json:"blob"`
+	Products string            `json:"products"`
+	Fields   map[string]string `json:"fields"`
+
+	products *mapping.Path
+	fields   mapping.Fields
+}
+
+// Mappings returns the mappings declared by a wrapper, with their paths compiled
+func Mappings(wrapper *ctypes.Wrapper) ([]*Mapping, error) {
+	mappings := make([]*Mapping, 0)
+	if wrapper == nil {
+		return mappings, nil
+	}
+	for _, content := range wrapper.Content {
+		if content.Name != WrapperContent {
+			continue
+		}
+		for _, entity := range content.Entities {
+			raw, _ := json.Marshal(entity)
+			mapping := &Mapping{}
+			if err := json.Unmarshal(raw, mapping); err != nil {
+				return nil, fmt.Errorf("invalid state mapping %s: %v", raw, err)
+			}
+			if err := mapping.compile(); err != nil {
+				return nil, err
+			}
+			mappings = append(mappings, mapping)
+		}
+	}
+	return mappings, nil
+}
+
+func (m *Mapping) compile() (err error) {
+	if m.Blob == "" {
+		return fmt.Errorf("state mapping needs a blob")
+	}
+	products := m.Products
+	if products == "" {
+		products = "$"
+	}
+	if m.products, err = mapping.Compile(products); err != nil {
+		return err
+	}
+	m.fields, err = mapping.CompileFields(m.Fields)
+	return err
+}
+
+// ExtractProducts returns the products of the blobs of pageURL mapped by the mappings of the wrapper, or the
+// schema.org products embedded in the blobs (as JSON-LD nodes) when the wrapper has no mappings
+func ExtractProducts(blobs []Blob, mappings []*Mapping, pageURL string) []hash {
+	if len(mappings) == 0 {
+		nodes := make([]interface{}, 0)
+		for _, blob := range blobs {
+			collectNodes(blob.Value, &nodes)
+		}
+		return jsonld.ProductsFromNodes(nodes, pageURL)
+	}
+
+	base, _ := neturl.Parse(pageURL)
+	products := make([]hash, 0)
+	for _, m := range mappings {
+		for _, blob := range blobs {
+			if blob.Name != m.Blob {
+				continue
+			}
+			for _, node := range m.products.Eval(blob.Value, nil) {
+				if product := m.fields.Product(blob.Value, node, base); len(product) > 0 {
+					products = append(products, product)
+				}
+			}
+		}
+	}
+	return products
+}
+
+// collectNodes collects the schema.org nodes (with @type) anywhere in a blob
+func collectNodes(value interface{}, nodes *[]interface{}) {
+	switch v := value.(type) {
+	case hash:
+		if _, ok := v["@type"]; ok {
+			*nodes = append(*nodes, v)
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectNodes(v[key], nodes)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectNodes(child, nodes)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package spastate
+
+import (
+	"fmt"
+
+	"github.com/Semantics3/go-crawler/sources"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+)
+
+// SPAState - implements Source interface, extracts products from the state embedded in pages of single page
+// applications (__NEXT_DATA__, __NUXT_DATA__, window.__INITIAL_STATE__, __APOLLO_STATE__, ...) without rendering
+type SPAState struct {
+	Name      string
+	ErrorCode string
+}
+
+func init() {
+	sources.Register(sources.Info{
+		Name:        "SPA_STATE",
+		ErrorPrefix: "SPA_STATE_",
+		Fields:      []string{"sku", "name", "description", "brand", "mpn", "upc", "ean", "color", "size", "url", "images", "listprice", "listprice_currency", "offers"},
+	}, func(name string) types.Sources {
+		return &SPAState{Name: name}
+	})
+}
+
+// GetName - return name
+func (s *SPAState) GetName() string {
+	return s.Name
+}
+
+// GetErrorCode - return code of error encountered while processing request
+func (s *SPAState) GetErrorCode() string {
+	return s.ErrorCode
+}
+
+// Request - Reuses the page already downloaded by a previous source of the crawl, downloads it otherwise
+func (s *SPAState) Request(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (canExtract bool, code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		s.ErrorCode = code
+	}(code)
+
+	return sources.ReusePage(url, workflow, pipeline, appC)
+}
+
+// Extract - Parses the state blobs of the page and maps them with the state mappings of the wrapper
+func (s *SPAState) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
+
+	// set the error code before return (datadog metric tracking)
+	defer func(c string) {
+		s.ErrorCode = code
+	}(code)
+
+	blobs := FindBlobs(workflow.WebResponse.Content, url)
+	if len(blobs) == 0 {
+		code = "SPA_STATE_NOT_FOUND"
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: "no state blob found in the page"}
+		return code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+	}
+
+	var wrapper *ctypes.Wrapper
+	if workflow.DomainInfo != nil {
+		wrapper = &workflow.DomainInfo.Wrapper
+	}
+	mappings, err := Mappings(wrapper)
+	if err != nil {
+		code = "SPA_STATE_INVALID_MAPPING"
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: err.Error()}
+		return code, fmt.Errorf("%s: %v", url, err)
+	}
+
+	products := ExtractProducts(blobs, mappings, url)
+	if len(products) == 0 {
+		code = "SPA_STATE_NO_PRODUCT"
+		workflow.Data = types.ExtractionResponse{Status: 0, Code: code, Message: "no product found in the state blobs of the page"}
+		return code, fmt.Errorf("%s: %s", url, workflow.Data.Message)
+	}
+	workflow.Data = types.ExtractionResponse{Products: products, Status: 1}
+	return "", nil
+}
+
+// Normalize - products are mapped to the standard schema while extracting
+func (s *SPAState) Normalize(workflow *types.CrawlWorkflow, appC *types.Config) {
+	return
+}
@@ -0,0 +1,164 @@
+package spastate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/tests/pagetest"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+const pageURL = "https://shop.example.com/p/1"
+
+type SPAStateSuite struct {
+	suite.Suite
+}
+
+func (suite *SPAStateSuite) page(name string) string {
+	content, err := ioutil.ReadFile("testdata/" + name)
+	suite.Require().Nil(err)
+	return string(content)
+}
+
+// wrapper declares state mappings as a wrapper would
+func (suite *SPAStateSuite) wrapper(mappings string) ctypes.Wrapper {
+	var entities []map[string]interface{}
+	suite.Require().Nil(json.Unmarshal([]byte(mappings), &entities))
+	return ctypes.Wrapper{Content: []ctypes.WrapperContent{{Name: "products"}, {Name: WrapperContent, Entities: entities}}}
+}
+
+// Test_01_FindBlobs - tests state blobs are found in script tags and global assignments
+func (suite *SPAStateSuite) Test_01_FindBlobs() {
+	blobs := FindBlobs(suite.page("next_data.html"), pageURL)
+	suite.Require().Len(blobs, 1)
+	suite.Assert().Equal("__NEXT_DATA__", blobs[0].Name)
+
+	// case: JSON.parse of a string literal
+	blobs = FindBlobs(suite.page("apollo.html"), pageURL)
+	suite.Require().Len(blobs, 1)
+	suite.Assert().Equal("__APOLLO_STATE__", blobs[0].Name)
+	suite.Assert().Contains(blobs[0].Value, "Product:55")
+
+	// case: devalue payload of Nuxt 3 is unflattened
+	blobs = FindBlobs(suite.page("nuxt_data.html"), pageURL)
+	suite.Require().Len(blobs, 2)
+	suite.Assert().Equal("__NUXT_DATA__", blobs[0].Name)
+	suite.Assert().Equal(map[string]interface{}{
+		"data":  map[string]interface{}{"product-42": map[string]interface{}{"name": "Camp Stove", "sku": "CS-42", "price": 39.95}},
+		"state": map[string]interface{}{},
+	}, blobs[0].Value)
+
+	// case: scripts building the state aren't JSON
+	blobs = FindBlobs(`<script>window.__NUXT__=(function(a){return {name:a}}("x"));window["__STATE__"] = {"a": 1};</script>`, pageURL)
+	suite.Require().Len(blobs, 1)
+	suite.Assert().Equal(Blob{Name: "__STATE__", Value: map[string]interface{}{"a": float64(1)}}, blobs[0])
+}
+
+// Test_02_Extract - tests products are mapped from the blobs of the page by the mappings of the wrapper
+func (suite *SPAStateSuite) Test_02_Extract() {
+	source := &SPAState{Name: "SPA_STATE"}
+	workflow := &types.CrawlWorkflow{
+		URL:         pageURL,
+		DomainInfo:  &ctypes.DomainInfo{DomainName: "shop.example.com"},
+		WebResponse: types.WebResponse{Content: suite.page("next_data.html"), Success: true},
+	}
+	workflow.DomainInfo.Wrapper = suite.wrapper(`[{"blob": "__NEXT_DATA__", "products": "$.props.pageProps.product.variants[*]", "fields": {
+		"name": "$.props.pageProps.product.name", "brand": "$.props.pageProps.product.brand.name", "description": "$.props.pageProps.product.description",
+		"sku": "@.sku", "color": "@.options[?(@.name == 'color')].value", "size": "@.options[?(@.name == 'size')].value",
+		"images": "@.images[*].url", "price": "@.price.amount", "currency": "$.props.pageProps.currency", "availability": "@.inStock"}}]`)
+
+	canExtract, code, err := source.Request(pageURL, workflow, nil, nil)
+	suite.Assert().True(canExtract)
+	suite.Assert().Nil(err)
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Assert().Equal(1, workflow.Data.Status)
+	suite.Require().Len(workflow.Data.Products, 2)
+	suite.Assert().Equal(map[string]interface{}{
+		"name":               "Trail Runner 2",
+		"brand":              "Stride",
+		"description":        "Light & grippy trail shoe.",
+		"sku":                "TR2-BLU-9",
+		"color":              "Blue",
+		"size":               "9",
+		"images":             []interface{}{"https://shop.example.com/img/tr2-blue.jpg"},
+		"listprice":          "89.99",
+		"listprice_currency": "USD",
+		"offers":             []interface{}{map[string]interface{}{"price": "89.99", "currency": "USD", "availability": "Available"}},
+	}, workflow.Data.Products[0])
+	suite.Assert().Equal("74.50", workflow.Data.Products[1]["listprice"])
+	suite.Assert().Equal("Out of Stock", workflow.Data.Products[1]["offers"].([]interface{})[0].(map[string]interface{})["availability"])
+
+	// case: Apollo cache references are resolved
+	workflow.WebResponse.Content = suite.page("apollo.html")
+	workflow.DomainInfo.Wrapper = suite.wrapper(`[{"blob": "__APOLLO_STATE__", "products": "$.ROOT_QUERY.*", "fields": {
+		"name": "@.title", "sku": "@.sku", "price": "@.price.amount", "currency": "@.price.currencyCode", "availability": "@.availability"}}]`)
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Require().Nil(err)
+	suite.Require().Len(workflow.Data.Products, 1)
+	suite.Assert().Equal("KT-55", workflow.Data.Products[0]["sku"])
+	suite.Assert().Equal([]interface{}{map[string]interface{}{"price": "1299.00", "currency": "EUR", "availability": "Available"}}, workflow.Data.Products[0]["offers"])
+
+	// case: schema.org products embedded in the state are extracted without mappings
+	workflow.WebResponse.Content = suite.page("initial_state.html")
+	workflow.DomainInfo.Wrapper = ctypes.Wrapper{}
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Require().Nil(err)
+	suite.Require().Len(workflow.Data.Products, 1)
+	suite.Assert().Equal("DL-7", workflow.Data.Products[0]["sku"])
+	suite.Assert().Equal("24.00", workflow.Data.Products[0]["listprice"])
+
+	// case: mappings of blobs missing from the page
+	workflow.WebResponse.Content = suite.page("nuxt_data.html")
+	workflow.DomainInfo.Wrapper = suite.wrapper(`[{"blob": "__NEXT_DATA__", "fields": {"name": "$.name"}}]`)
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("SPA_STATE_NO_PRODUCT", code)
+
+	// case: invalid paths fail with SPA_STATE_INVALID_MAPPING
+	workflow.DomainInfo.Wrapper = suite.wrapper(`[{"blob": "__NUXT_DATA__", "fields": {"name": "data.name"}}]`)
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("SPA_STATE_INVALID_MAPPING", code)
+	suite.Assert().Equal("SPA_STATE_INVALID_MAPPING", source.GetErrorCode())
+
+	// case: pages without state fail with SPA_STATE_NOT_FOUND
+	workflow.WebResponse.Content = `<html><script>var x = 1;</script></html>`
+	code, err = source.Extract(pageURL, workflow, nil, nil)
+	suite.Assert().NotNil(err)
+	suite.Assert().Equal("SPA_STATE_NOT_FOUND", code)
+	suite.Assert().Equal(0, workflow.Data.Status)
+}
+
+// Test_03_ReadsCachedPage - tests the state blobs of pages written to the cache service by proxycloud are found
+func (suite *SPAStateSuite) Test_03_ReadsCachedPage() {
+	proxy := pagetest.NewProxy(map[string]string{pageURL: suite.page("next_data.html")})
+	defer proxy.Close()
+	appC := proxy.Config()
+	source := &SPAState{Name: "SPA_STATE"}
+
+	workflow := pagetest.Workflow(pageURL)
+	workflow.DomainInfo.Wrapper = suite.wrapper(`[{"blob": "__NEXT_DATA__", "products": "$.props.pageProps.product.variants[*]", "fields": {
+		"name": "$.props.pageProps.product.name", "sku": "@.sku", "price": "@.price.amount", "currency": "$.props.pageProps.currency"}}]`)
+	canExtract, code, err := source.Request(pageURL, workflow, pagetest.Pipeline{}, appC)
+	suite.Require().Nil(err)
+	suite.Assert().True(canExtract)
+	suite.Assert().Equal("", code)
+	suite.Assert().Len(FindBlobs(workflow.WebResponse.Content, pageURL), 1)
+	code, err = source.Extract(pageURL, workflow, pagetest.Pipeline{}, appC)
+	suite.Require().Nil(err)
+	suite.Assert().Equal("", code)
+	suite.Require().Len(workflow.Data.Products, 2)
+	suite.Assert().Equal("TR2-BLU-9", workflow.Data.Products[0]["sku"])
+	suite.Assert().Equal("TR2-RED-10", workflow.Data.Products[1]["sku"])
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSPAStateSuite(t *testing.T) {
+	suite.Run(t, new(SPAStateSuite))
+}
@@ -0,0 +1,270 @@
+package supervised
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Semantics3/go-crawler/request"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/utils"
+	"github.com/Semantics3/go-crawler/warc"
+	"github.com/Semantics3/sem3-go-crawl-utils/html"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+)
+
+// Defaults for ajax fan-out of a single workflow
+// Both can be overridden through job params (ajax_concurrency, ajax_request_budget)
+const (
+	defaultAjaxConcurrency   = 8
+	defaultAjaxRequestBudget = 100
+	// Failed ajax requests are requested again when CE asks for them, up to this many times
+	maxAjaxAttempts = 3
+)
+
+// ajaxFanout visits secondary web requests of a workflow with a bounded worker pool
+// Workers never touch the workflow, all the responses are merged by the caller's goroutine
+type ajaxFanout struct {
+	url         string
+	workflow    *types.CrawlWorkflow
+	appC        *types.Config
+	concurrency int
+	budget      int
+	requested   int
+	// Requests which succeeded, and the number of times each request was made
+	resolved map[string]bool
+	attempts map[string]int
+	cache    *request.AjaxCache
+	warc     *warc.Writer
+	archive  *warc.Archive
+}
+
+// ajaxJob is a single secondary web request handed over to a worker
+type ajaxJob struct {
+	index         int
+	ajaxConfig    types.AjaxURL
+	requestConfig types.RequestConfig
+	jobParams     *ctypes.CrawlJobParams
+}
+
+// ajaxResult is the response of an ajax job along with metrics collected while visiting it
+type ajaxResult struct {
+	ajaxConfig     types.AjaxURL
+	webResponse    types.WebResponse
+	productMetrics types.ProductMetrics
+	cacheHit       bool
+}
+
+func newAjaxFanout(url string, workflow *types.CrawlWorkflow, appC *types.Config) *ajaxFanout {
+	f := &ajaxFanout{
+		url:         url,
+		workflow:    workflow,
+		appC:        appC,
+		concurrency: defaultAjaxConcurrency,
+		budget:      defaultAjaxRequestBudget,
+		resolved:    make(map[string]bool),
+		attempts:    make(map[string]int),
+	}
+	if workflow.JobInput != nil {
+		f.cache = request.GetBatchAjaxCache(workflow.JobInput)
+		f.warc = request.GetBatchWarcWriter(workflow.JobInput)
+		f.archive = request.GetBatchWarcArchive(workflow.JobInput)
+		if c, ok := cutils.GetIntKey(workflow.JobInput.JobParams, "ajax_concurrency"); ok && c > 0 {
+			f.concurrency = c
+		}
+		if b, ok := cutils.GetIntKey(workflow.JobInput.JobParams, "ajax_request_budget"); ok && b > 0 {
+			f.budget = b
+		}
+	}
+	return f
+}
+
+// ajaxRequestKey identifies identical ajax requests
+// Cache key is derived from the url by CE, method and body differentiate POST requests
+func ajaxRequestKey(ajaxConfig types.AjaxURL) string {
+	id := ajaxConfig.CacheKey
+	if id == "" {
+		id = ajaxConfig.URL
+	}
+	return fmt.Sprintf("%s|%s|%s", ajaxConfig.Method, id, ajaxConfig.Body)
+}
+
+// ajaxCacheKey identifies ajax responses which can be shared by the workflows of a batch
+// Responses depend on the cookie, session and geo they are requested with (carts, pricing, inventory)
+func ajaxCacheKey(job ajaxJob) string {
+	config := job.requestConfig
+	session := ""
+	if config.Session != nil {
+		session = config.Session.ID
+	}
+	cookie := ""
+	if config.Cookie != "" {
+		cookie, _ = utils.Md5Hash(config.Cookie)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", ajaxRequestKey(job.ajaxConfig), session, utils.GeoName(config.Geo), cookie)
+}
+
+// pending removes duplicate ajax requests and the ones already resolved in earlier iterations
+// Failed requests are made again when CE asks for them again, until they run out of attempts
+func (f *ajaxFanout) pending(ajaxURLs []types.AjaxURL) (unique []types.AjaxURL) {
+	unique = make([]types.AjaxURL, 0, len(ajaxURLs))
+	iteration := make(map[string]bool)
+	for _, ajaxConfig := range ajaxURLs {
+		key := ajaxRequestKey(ajaxConfig)
+		if f.resolved[key] || iteration[key] {
+			log.Printf("CRAWL_AJAX_DUPLICATE: URL: %s, AJAX_URL: %s, KEY: %s\n", f.url, ajaxConfig.URL, key)
+			continue
+		}
+		if f.attempts[key] >= maxAjaxAttempts {
+			log.Printf("CRAWL_AJAX_ATTEMPTS_EXHAUSTED: URL: %s, AJAX_URL: %s, KEY: %s failed %d times\n", f.url, ajaxConfig.URL, key, f.attempts[key])
+			continue
+		}
+		iteration[key] = true
+		unique = append(unique, ajaxConfig)
+	}
+	return unique
+}
+
+// budgeted truncates ajax requests to the budget left for the workflow
+// Requests over the budget are dropped (and counted in extraction metrics) rather than failing the page
+func (f *ajaxFanout) budgeted(ajaxURLs []types.AjaxURL) []types.AjaxURL {
+	remaining := f.budget - f.requested
+	if remaining < 0 {
+		remaining = 0
+	}
+	if len(ajaxURLs) <= remaining {
+		return ajaxURLs
+	}
+	for _, ajaxConfig := range ajaxURLs[remaining:] {
+		log.Printf("CRAWL_AJAX_BUDGET_EXCEEDED: URL: %s, AJAX_URL: %s dropped, budget of %d requests is used up\n", f.url, ajaxConfig.URL, f.budget)
+	}
+	f.workflow.ExtractionMetrics.AjaxDropped += len(ajaxURLs) - remaining
+	return ajaxURLs[:remaining]
+}
+
+// visit requests all the ajax urls and merges responses into the workflow
+func (f *ajaxFanout) visit(ajaxURLs []types.AjaxURL) {
+	workflow := f.workflow
+	numAjaxRequests := len(ajaxURLs)
+	f.requested += numAjaxRequests
+
+	// Construct all the jobs upfront, so that workers only read their own copy
+	jobs := make(chan ajaxJob, numAjaxRequests)
+	for i, ajaxConfig := range ajaxURLs {
+		f.attempts[ajaxRequestKey(ajaxConfig)]++
+		jobs <- f.newJob(i, ajaxConfig)
+	}
+	close(jobs)
+
+	workers := f.concurrency
+	if workers > numAjaxRequests {
+		workers = numAjaxRequests
+	}
+	results := make(chan ajaxResult, numAjaxRequests)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for job := range jobs {
+				results <- f.fetch(job, numAjaxRequests)
+			}
+		}()
+	}
+
+	for numAjaxResponse := 1; numAjaxResponse <= numAjaxRequests; numAjaxResponse++ {
+		result := <-results
+		webResponse := result.webResponse
+		if html.IsSuccess(webResponse.Status) {
+			f.resolved[ajaxRequestKey(result.ajaxConfig)] = true
+			delete(workflow.AjaxFailedStatusMap, result.ajaxConfig.CacheKey)
+			utils.WriteResponseToCache(webResponse.URL, result.ajaxConfig.CacheKey, workflow.CacheExpiry, workflow.CrawlTime, webResponse, f.appC)
+		} else {
+			workflow.AjaxFailedStatusMap[result.ajaxConfig.CacheKey] = webResponse.Status
+		}
+		collectAjaxProductMetrics(&workflow.ProductMetrics, &result.productMetrics)
+		if f.cache != nil {
+			if result.cacheHit {
+				workflow.ExtractionMetrics.AjaxCacheHits++
+			} else {
+				workflow.ExtractionMetrics.AjaxCacheMisses++
+			}
+		}
+		utils.CollectScreenshots(workflow, webResponse)
+		if webResponse.WarcRecordID != "" {
+			if workflow.Warc == nil {
+				workflow.Warc = &types.WarcReference{}
+			}
+			workflow.Warc.AjaxRecordIDs = append(workflow.Warc.AjaxRecordIDs, webResponse.WarcRecordID)
+		}
+		logMessage := fmt.Sprintf("CRAWL_AJAX_URL_END: AJAX_RESPONSE_COUNT: (%d/%d), URL: %s, AJAX_URL: %s\n", numAjaxResponse, numAjaxRequests, f.url, webResponse.URL)
+		utils.PrintResponseDetails(webResponse.Status, logMessage)
+	}
+}
+
+func (f *ajaxFanout) newJob(index int, ajaxConfig types.AjaxURL) ajaxJob {
+	workflow := f.workflow
+	ajaxJobParams := workflow.JobParams
+	if ajaxConfig.AjaxJobParams != nil {
+		ajaxJobParams = ajaxConfig.AjaxJobParams
+	}
+
+	cookie := ajaxConfig.Cookie
+	if cookie == "" {
+		cookie = workflow.WebResponse.Cookie
+	}
+
+	requestConfig := types.RequestConfig{
+		JobType: workflow.ProductMetrics.JobType,
+		// We cannot use the parent domain info here even though it may seem intuitive to
+		// do so because a secondary web request can be made to a different domain (eg. Grainger)
+		// If we set the domain to the parent's, all secondary Proxy Cloud requests may fail since
+		// the domain would be seen as having changed.
+		DomainInfo:     &ctypes.DomainInfo{},
+		ProductMetrics: workflow.ProductMetrics,
+		IsAjax:         true,
+		ParentUrl:      f.url,
+		Cookie:         cookie,
+		CacheKey:       ajaxConfig.CacheKey,
+		CacheExpiry:    workflow.CacheExpiry,
+		Method:         ajaxConfig.Method,
+		Body:           ajaxConfig.Body,
+		Headers:        ajaxConfig.Headers,
+		Timeout:        ajaxConfig.Timeout,
+		Session:        workflow.Session,
+		Geo:            workflow.Geo,
+		Warc:           f.warc,
+		Archive:        f.archive,
+	}
+	return ajaxJob{index: index, ajaxConfig: ajaxConfig, requestConfig: requestConfig, jobParams: ajaxJobParams}
+}
+
+// fetch visits a single ajax url, metrics are collected on a copy owned by the worker
+func (f *ajaxFanout) fetch(job ajaxJob, numAjaxRequests int) ajaxResult {
+	result := ajaxResult{ajaxConfig: job.ajaxConfig}
+	result.productMetrics = types.ProductMetrics{
+		Site:    job.requestConfig.ProductMetrics.Site,
+		JobType: job.requestConfig.ProductMetrics.JobType,
+	}
+
+	logMessage := fmt.Sprintf("CRAWL_AJAX_URL_START: AJAX_REQUEST_COUNT: (%d/%d), URL: %s, AJAX_URL: %s\n", job.index+1, numAjaxRequests, f.url, job.ajaxConfig.URL)
+	utils.PrintResponseDetails(0, logMessage)
+	visit := func() types.WebResponse {
+		return request.VisitPage(job.ajaxConfig.URL, &job.requestConfig, job.jobParams, &result.productMetrics, f.appC)
+	}
+
+	// Responses fetched by other workflows of the batch with the same cookie, session and geo are reused as is
+	if f.cache == nil {
+		result.webResponse = visit()
+	} else {
+		result.webResponse, result.cacheHit = f.cache.Fetch(ajaxCacheKey(job), visit)
+		if result.cacheHit {
+			log.Printf("CRAWL_AJAX_CACHE_HIT: URL: %s, AJAX_URL: %s\n", f.url, job.ajaxConfig.URL)
+		}
+	}
+	return result
+}
+
+// Add up the request counters collected by an ajax worker
+func collectAjaxProductMetrics(productMetrics *types.ProductMetrics, ajaxMetrics *types.ProductMetrics) {
+	utils.CollectProductMetrics("latency", ajaxMetrics.Latency, productMetrics)
+	utils.CollectProductMetrics("url_count", ajaxMetrics.UrlCount, productMetrics)
+	utils.CollectProductMetrics("retry_count", ajaxMetrics.RetryCount, productMetrics)
+}
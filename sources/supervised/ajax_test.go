@@ -0,0 +1,141 @@
+package supervised
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/storage"
+	"github.com/Semantics3/go-crawler/types"
+	"github.com/Semantics3/go-crawler/warc"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type AjaxSuite struct {
+	suite.Suite
+}
+
+func (suite *AjaxSuite) newWorkflow(jobParams map[string]interface{}) *types.CrawlWorkflow {
+	return &types.CrawlWorkflow{
+		URL:                 "https://example.com/p/1",
+		JobInput:            &ctypes.Batch{JobParams: jobParams},
+		JobParams:           &ctypes.CrawlJobParams{},
+		AjaxFailedStatusMap: make(map[string]int),
+	}
+}
+
+func (suite *AjaxSuite) Test_01_FanoutLimitsFromJobParams() {
+	f := newAjaxFanout("https://example.com/p/1", suite.newWorkflow(nil), nil)
+	suite.Equal(defaultAjaxConcurrency, f.concurrency)
+	suite.Equal(defaultAjaxRequestBudget, f.budget)
+
+	w := suite.newWorkflow(map[string]interface{}{"ajax_concurrency": 2, "ajax_request_budget": 5})
+	f = newAjaxFanout(w.URL, w, nil)
+	suite.Equal(2, f.concurrency)
+	suite.Equal(5, f.budget)
+}
+
+// archive serves ajax responses of the urls from a WARC archive, other urls fail with a 404
+func (suite *AjaxSuite) archive(urls ...string) *warc.Archive {
+	dir, err := ioutil.TempDir("", "ajax")
+	suite.Require().Nil(err)
+	defer os.RemoveAll(dir)
+	store, err := storage.NewLocalStore(dir, "")
+	suite.Require().Nil(err)
+	archive := warc.NewArchive()
+	if len(urls) == 0 {
+		return archive
+	}
+	w := warc.NewWriter(store, "ajax", 0)
+	var record warc.Record
+	for _, url := range urls {
+		record, err = w.WriteExchange(warc.Exchange{URL: url, Status: 200, Content: "{}"})
+		suite.Require().Nil(err)
+	}
+	suite.Require().Nil(w.Close())
+	data, err := store.Get(record.File)
+	suite.Require().Nil(err)
+	suite.Require().Nil(archive.Read(bytes.NewReader(data)))
+	return archive
+}
+
+func (suite *AjaxSuite) Test_02_PendingRetriesFailedRequests() {
+	w := suite.newWorkflow(nil)
+	f := newAjaxFanout(w.URL, w, nil)
+	f.archive = suite.archive("https://example.com/price?id=1")
+
+	first := f.pending([]types.AjaxURL{
+		{URL: "https://example.com/price?id=1", CacheKey: "CE/recrawl/example_com/a"},
+		{URL: "https://example.com/price?id=1", CacheKey: "CE/recrawl/example_com/a"},
+		{URL: "https://example.com/stock", Method: "POST", Body: `{"id":1}`, CacheKey: "CE/recrawl/example_com/b"},
+		{URL: "https://example.com/stock", Method: "POST", Body: `{"id":2}`, CacheKey: "CE/recrawl/example_com/c"},
+	})
+	suite.Len(first, 3)
+	f.visit(first)
+	suite.Equal(map[string]int{"CE/recrawl/example_com/b": 404, "CE/recrawl/example_com/c": 404}, w.AjaxFailedStatusMap)
+
+	// Resolved requests are skipped, failed ones are requested again
+	second := f.pending([]types.AjaxURL{
+		{URL: "https://example.com/price?id=1", CacheKey: "CE/recrawl/example_com/a"},
+		{URL: "https://example.com/stock", Method: "POST", Body: `{"id":1}`, CacheKey: "CE/recrawl/example_com/b"},
+		{URL: "https://example.com/stock", Method: "POST", Body: `{"id":3}`, CacheKey: "CE/recrawl/example_com/d"},
+	})
+	suite.Len(second, 2)
+	suite.Equal(`{"id":1}`, second[0].Body)
+	suite.Equal(`{"id":3}`, second[1].Body)
+
+	// Until they run out of attempts
+	retry := second[:1]
+	for attempt := 2; attempt <= maxAjaxAttempts; attempt++ {
+		f.visit(retry)
+	}
+	suite.Len(f.pending(retry), 0)
+	suite.Equal(maxAjaxAttempts, f.attempts[ajaxRequestKey(retry[0])])
+}
+
+func (suite *AjaxSuite) Test_03_VisitTruncatesToBudget() {
+	w := suite.newWorkflow(map[string]interface{}{"ajax_request_budget": 1})
+	f := newAjaxFanout(w.URL, w, nil)
+	f.archive = suite.archive("https://example.com/a")
+
+	ajaxURLs := f.budgeted([]types.AjaxURL{{URL: "https://example.com/a"}, {URL: "https://example.com/b"}})
+	suite.Equal([]types.AjaxURL{{URL: "https://example.com/a"}}, ajaxURLs)
+	suite.Equal(1, w.ExtractionMetrics.AjaxDropped)
+	f.visit(ajaxURLs)
+	suite.Equal(1, f.requested)
+
+	// Nothing is left once the budget is used up
+	suite.Len(f.budgeted([]types.AjaxURL{{URL: "https://example.com/c"}}), 0)
+	suite.Equal(2, w.ExtractionMetrics.AjaxDropped)
+}
+
+func (suite *AjaxSuite) Test_04_AjaxCacheKeySeparatesSessionsAndGeos() {
+	w := suite.newWorkflow(nil)
+	f := newAjaxFanout(w.URL, w, nil)
+	ajaxConfig := types.AjaxURL{URL: "https://example.com/cart", CacheKey: "CE/recrawl/example_com/a"}
+	key := ajaxCacheKey(f.newJob(0, ajaxConfig))
+	suite.Equal(key, ajaxCacheKey(f.newJob(1, ajaxConfig)))
+
+	w.Session = &types.CrawlSession{ID: "s1", Cookie: "cart=1"}
+	withSession := ajaxCacheKey(f.newJob(0, ajaxConfig))
+	suite.NotEqual(key, withSession)
+	w.Session = &types.CrawlSession{ID: "s2", Cookie: "cart=2"}
+	suite.NotEqual(withSession, ajaxCacheKey(f.newJob(0, ajaxConfig)))
+
+	w.Session = nil
+	w.Geo = &types.GeoTarget{Country: "DE"}
+	suite.NotEqual(key, ajaxCacheKey(f.newJob(0, ajaxConfig)))
+
+	w.Geo = nil
+	w.WebResponse.Cookie = "zip=10001"
+	withCookie := ajaxCacheKey(f.newJob(0, ajaxConfig))
+	suite.NotEqual(key, withCookie)
+	ajaxConfig.Cookie = "zip=94105"
+	suite.NotEqual(withCookie, ajaxCacheKey(f.newJob(0, ajaxConfig)))
+}
+
+func TestAjaxTestSuite(t *testing.T) {
+	suite.Run(t, new(AjaxSuite))
+}
@@ -3,11 +3,9 @@ package supervised
 import (
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/Semantics3/go-crawler/request"
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
-	"github.com/Semantics3/go-crawler/utils"
 )
 
 // Supervised type (implementing Sources interface type)
@@ -16,6 +14,12 @@ type Supervised struct {
 	ErrorCode string
 }
 
+func init() {
+	sources.Register(sources.Info{Name: "WRAPPER", Supervised: true}, func(name string) types.Sources {
+		return &Supervised{Name: name}
+	})
+}
+
 // GetName - return name
 func (sp *Supervised) GetName() string {
 	return sp.Name
@@ -43,41 +47,7 @@ func (sp *Supervised) Request(url string, workflow *types.CrawlWorkflow, pipelin
 		return false, code, fmt.Errorf("%s doesn't have any sitedetail defined", workflow.DomainInfo.DomainName)
 	}
 
-	// 1. Construct request config and Set cache parameters
-	reqConfig, code, err := pipeline.PrepareRequestConfig(workflow)
-	if err != nil {
-		return canExtract, code, err
-	}
-	cacheKey := reqConfig.CacheKey
-	workflow.CacheKey = cacheKey
-	workflow.CacheExpiry = pipeline.GetCacheExpiryTime()
-
-	// 2. Read data from cache
-	jobParams := workflow.JobParams
-	if (jobParams != nil && jobParams.Cache == 1) || pipeline.ShouldReadFromCache(workflow) {
-		utils.ReadDataFromCache(appC.ConfigData.CacheService, cacheKey, workflow)
-	} else {
-		workflow.CrawlTime = time.Now().Unix()
-	}
-
-	// Check if we're able download webpage from cache successfully
-	if workflow.WebResponse.FromCache {
-		log.Printf("SUPERVISED_REQUEST: Cache found, (%s) cache_path %s\n", workflow.URL, workflow.CacheKey)
-		canExtract = true
-	} else {
-		log.Printf("SUPERVISED_REQUEST: Crawl start (%s)\n", url)
-		workflow.WebResponse = request.VisitPage(url, &reqConfig, jobParams, &workflow.ProductMetrics, appC)
-		wr := workflow.WebResponse
-		utils.CollectProductMetrics("latency", wr.TimeTaken, &workflow.ProductMetrics)
-
-		// Decides whether request is eligble for data extraction based on the response status
-		// If web resp status is 500, code should be HTTP_500
-		canExtract, code, err = pipeline.ValidateWebResponse(workflow)
-		if err != nil {
-			return canExtract, code, err
-		}
-	}
-	return
+	return sources.FetchPage(url, workflow, pipeline, appC)
 }
 
 func (sp *Supervised) Extract(url string, workflow *types.CrawlWorkflow, pipeline types.Pipeline, appC *types.Config) (code string, err error) {
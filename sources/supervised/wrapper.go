@@ -7,12 +7,10 @@ import (
 	"log"
 	"time"
 
-	"github.com/Semantics3/go-crawler/request"
 	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 	"github.com/Semantics3/sem3-go-crawl-utils/html"
-	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
 )
 
@@ -91,81 +89,24 @@ func extractDataUsingWrapper(url string, workflow *types.CrawlWorkflow, appC *ty
 }
 
 func ExtractDataForAjaxRequests(url string, workflow *types.CrawlWorkflow, appC *types.Config) (code string, err error) {
-	jobParams := workflow.JobParams
 	iteration := 1
 	workflow.AjaxFailedStatusMap = make(map[string]int)
+	fanout := newAjaxFanout(url, workflow, appC)
 
 	for len(workflow.Data.UnresolvedAjaxURLs) > 0 {
-		index := 0
 		start := time.Now()
-		numAjaxRequests := len(workflow.Data.UnresolvedAjaxURLs)
-		ajaxResponseChan := make(chan func() (types.WebResponse, types.AjaxURL), numAjaxRequests)
-		log.Printf("CRAWL_AJAX_START: (%s), ITERATION: %d, AJAX_REQUESTS_COUNT: %d\n", url, iteration, numAjaxRequests)
-
-		// Visit all the ajax calls concurrently and pass them through channel
-		// 1. To handle race conditions while updating product metrics
-		// 2. To make sure all responses are collected before proceeding to extraction
-		for index < numAjaxRequests {
-			go func(counter int) {
-				var requestConfig types.RequestConfig
-				var ajaxJobParams = jobParams
-				ajaxConfig := workflow.Data.UnresolvedAjaxURLs[counter]
-				if ajaxConfig.AjaxJobParams != nil {
-					ajaxJobParams = ajaxConfig.AjaxJobParams
-				}
-
-				var cookie string
-				if ajaxConfig.Cookie != "" {
-					cookie = ajaxConfig.Cookie
-				} else {
-					cookie = workflow.WebResponse.Cookie
-				}
-
-				requestConfig = types.RequestConfig{
-					JobType: workflow.ProductMetrics.JobType,
-					// We cannot use the parent domain info here even though it may seem intuitive to
-					// do so because a secondary web request can be made to a different domain (eg. Grainger)
-					// If we set the domain to the parent's, all secondary Proxy Cloud requests may fail since
-					// the domain would be seen as having changed.
-					DomainInfo:     &ctypes.DomainInfo{},
-					ProductMetrics: workflow.ProductMetrics,
-					IsAjax:         true,
-					ParentUrl:      url,
-					Cookie:         cookie,
-					CacheKey:       ajaxConfig.CacheKey,
-					CacheExpiry:    workflow.CacheExpiry,
-					Method:         ajaxConfig.Method,
-					Body:           ajaxConfig.Body,
-					Headers:        ajaxConfig.Headers,
-					Timeout:        ajaxConfig.Timeout,
-				}
-
-				counter++
-				logMessage := fmt.Sprintf("CRAWL_AJAX_URL_START: AJAX_REQUEST_COUNT: (%d/%d), URL: %s, AJAX_URL: %s\n", counter, numAjaxRequests, url, ajaxConfig.URL)
-				utils.PrintResponseDetails(0, logMessage)
-				webResponse := request.VisitPage(ajaxConfig.URL, &requestConfig, ajaxJobParams, &workflow.ProductMetrics, appC)
-				if !html.IsSuccess(webResponse.Status) {
-					workflow.AjaxFailedStatusMap[ajaxConfig.CacheKey] = webResponse.Status
-				}
-				ajaxResponseChan <- (func() (types.WebResponse, types.AjaxURL) {
-					return webResponse, ajaxConfig
-				})
-			}(index)
-			index++
-		}
 
-		numAjaxResponse := 1
-		for numAjaxResponse <= numAjaxRequests {
-			// Collect ajax responses
-			webResponse, _ := (<-ajaxResponseChan)()
-			utils.CollectScreenshots(workflow, webResponse)
-			logMessage := fmt.Sprintf("CRAWL_AJAX_URL_END: AJAX_RESPONSE_COUNT: (%d/%d), URL: %s, AJAX_URL: %s\n", numAjaxResponse, numAjaxRequests, url, webResponse.URL)
-			utils.PrintResponseDetails(webResponse.Status, logMessage)
-			numAjaxResponse++
+		// Identical ajax requests are visited only once per workflow, failed ones are retried (see pending)
+		// If CE asks only for resolved requests or the budget is used up, another iteration won't resolve them
+		ajaxURLs := fanout.budgeted(fanout.pending(workflow.Data.UnresolvedAjaxURLs))
+		if len(ajaxURLs) == 0 {
+			log.Printf("CRAWL_AJAX_NOTHING_NEW: (%s), ITERATION: %d, none of the %d unresolved ajax requests can be requested\n", url, iteration, len(workflow.Data.UnresolvedAjaxURLs))
+			break
 		}
+		log.Printf("CRAWL_AJAX_START: (%s), ITERATION: %d, AJAX_REQUESTS_COUNT: %d, CONCURRENCY: %d\n", url, iteration, len(ajaxURLs), fanout.concurrency)
 
-		// Close the channel to communicate completion to channel's senders/receivers
-		close(ajaxResponseChan)
+		// Visit all the ajax calls with a bounded worker pool
+		fanout.visit(ajaxURLs)
 
 		duration := utils.ComputeDuration(start)
 		utils.CollectProductMetrics("latency", duration, &workflow.ProductMetrics)
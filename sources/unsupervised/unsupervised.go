@@ -20,6 +20,12 @@ type Unsupervised struct {
 	ErrorCode string
 }
 
+func init() {
+	sources.Register(sources.Info{Name: "UNSUPERVISED", ErrorPrefix: "UNSUPERVISED_"}, func(name string) types.Sources {
+		return &Unsupervised{Name: name}
+	})
+}
+
 // GetName - return name
 func (usp *Unsupervised) GetName() string {
 	return usp.Name
@@ -74,7 +80,7 @@ func (usp *Unsupervised) Request(url string, workflow *types.CrawlWorkflow, pipe
 	// Set expiry for the cache object
 	var expiry int32 = 60 * 60 // seconds (1 hour)
 
-	err = utils.WriteDataToCache(url, appC.ConfigData.CacheService, cacheKey, uResponse, expiry)
+	err = utils.WriteDataToCache(url, appC.PageCache, cacheKey, uResponse, expiry)
 	if err != nil {
 		code = "UNSUPERVISED_WRITING_TO_CACHE_FAILED"
 		err = cutils.PrintErr(code, fmt.Sprintf("writing unsupervised response for %s to the cache failed\n", url), err)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Semantics3/go-crawler/fixture"
 	"github.com/Semantics3/go-crawler/utils"
 	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
 	"github.com/Semantics3/sem3-go-data-consumer/rpc"
@@ -17,7 +18,11 @@ type hash = map[string]interface{}
 func MakeRPCRequest(rpcClient *rpc.RPCClient, mode string, url string, method string, args []interface{}, dest interface{}) error {
 
 	start := time.Now()
-	resp, err := rpcClient.Call(method, args...)
+	var resp interface{}
+	err := fixture.Replay(fixture.KindRPC, fmt.Sprintf("%s %s %s %s", mode, method, url, fixture.ArgsKey(args...)), &resp, func() (err error) {
+		resp, err = rpcClient.Call(method, args...)
+		return err
+	})
 	if err != nil {
 		return cutils.PrintErr("EXTRACTION_FAILED_RPC", fmt.Sprintf("failed %s rpc call for %s", mode, url), err)
 	}
@@ -109,6 +109,12 @@ func writeCrawlMetricsToDatadog(statsdClient *statsd.Client, metricName string,
 		fmt.Sprintf("render_pool:%s", cm.RenderPool),
 		fmt.Sprintf("status:%s", cm.Status),
 	}
+	if cm.Geo != "" {
+		tags = append(tags, fmt.Sprintf("geo:%s", cm.Geo))
+	}
+	if cm.SourcePlan != "" {
+		tags = append(tags, fmt.Sprintf("source_plan:%s", cm.SourcePlan))
+	}
 
 	var fieldLevelMetricName string
 
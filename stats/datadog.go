@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
+	"github.com/Semantics3/go-crawler/sources"
 	"github.com/Semantics3/go-crawler/types"
 	"github.com/Semantics3/go-crawler/utils"
 )
@@ -91,9 +92,10 @@ func sendDataSourceMetricsToDatadog(statsdClient *statsd.Client, source, site, j
 
 	// Add error code as tag
 	// Remove any data source specific prefixes from code as we're adding it as a tag
-	identifiers := []string{"UNSUPERVISED_", "DIFFBOT_", "AMAZON_", "M101_"}
-	for _, identifier := range identifiers {
-		errorCode = strings.Replace(errorCode, identifier, "", -1)
+	for _, info := range sources.Registered() {
+		if info.ErrorPrefix != "" {
+			errorCode = strings.Replace(errorCode, info.ErrorPrefix, "", -1)
+		}
 	}
 	tags = append(tags, fmt.Sprintf("error:%s", errorCode))
 
@@ -101,3 +103,37 @@ func sendDataSourceMetricsToDatadog(statsdClient *statsd.Client, source, site, j
 	log.Printf("DATADOG metric: %s, tags: %v\n", fieldLevelMetricName, tags)
 	statsdClient.Incr(fieldLevelMetricName, tags, float64(count))
 }
+
+// WriteConflictMetricsToDatadog counts the fields on which sources disagreed while merging
+func WriteConflictMetricsToDatadog(counts map[string]int, workflow *types.CrawlWorkflow, appC *types.Config) {
+	if appC.StatsdClient == nil {
+		return
+	}
+	metricName := "crawler.merge.conflicts.count"
+	for field, count := range counts {
+		tags := []string{
+			fmt.Sprintf("field:%s", field),
+			fmt.Sprintf("site:%s", workflow.DomainInfo.DomainName),
+			fmt.Sprintf("job_type:%s", workflow.ProductMetrics.JobType),
+		}
+		log.Printf("DATADOG metric: %s, tags: %v, count: %d\n", metricName, tags, count)
+		appC.StatsdClient.Count(metricName, int64(count), tags, 1)
+	}
+}
+
+// WriteFieldSourceMetricsToDatadog counts the product fields supplied by each data source
+func WriteFieldSourceMetricsToDatadog(counts map[string]int, workflow *types.CrawlWorkflow, appC *types.Config) {
+	if appC.StatsdClient == nil {
+		return
+	}
+	metricName := "crawler.data_source.fields.count"
+	for source, count := range counts {
+		tags := []string{
+			fmt.Sprintf("source:%s", strings.ToLower(source)),
+			fmt.Sprintf("site:%s", workflow.DomainInfo.DomainName),
+			fmt.Sprintf("job_type:%s", workflow.ProductMetrics.JobType),
+		}
+		log.Printf("DATADOG metric: %s, tags: %v, count: %d\n", metricName, tags, count)
+		appC.StatsdClient.Count(metricName, int64(count), tags, 1)
+	}
+}
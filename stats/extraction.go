@@ -24,7 +24,7 @@ func initializeBatchExtractionMetrics(sm *types.StatsManager, em types.Extractio
 		sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType] = make(map[string]interface{})
 	}
 
-	intKeys := []string{"url_count", "iterations", "value"}
+	intKeys := []string{"url_count", "iterations", "ajax_cache_hits", "ajax_cache_misses", "ajax_dropped", "value"}
 	floatKeys := []string{"s3", "products", "links", "preprocess", "total"}
 
 	for _, k := range intKeys {
@@ -48,6 +48,9 @@ func aggregateExtractionMetrics(sm *types.StatsManager, em types.ExtractionMetri
 	total := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["total"].(float64) + em.Total
 	iterations := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["iterations"].(int) + em.Iterations
 	urlCount := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["url_count"].(int) + em.UrlCount
+	ajaxCacheHits := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["ajax_cache_hits"].(int) + em.AjaxCacheHits
+	ajaxCacheMisses := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["ajax_cache_misses"].(int) + em.AjaxCacheMisses
+	ajaxDropped := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["ajax_dropped"].(int) + em.AjaxDropped
 	value := sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["value"].(int) + em.Value
 
 	// Update batch stats
@@ -58,6 +61,9 @@ func aggregateExtractionMetrics(sm *types.StatsManager, em types.ExtractionMetri
 	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["total"] = total
 	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["url_count"] = urlCount
 	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["iterations"] = iterations
+	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["ajax_cache_hits"] = ajaxCacheHits
+	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["ajax_cache_misses"] = ajaxCacheMisses
+	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["ajax_dropped"] = ajaxDropped
 	sm.BatchExtractionMetrics[em.Customer][em.Site][em.JobType]["value"] = value
 }
 
@@ -107,13 +113,19 @@ func writeExtractionMetricsToDatadog(statsdClient *statsd.Client, metricName str
 
 	// Collect metrics for which we would need number of occurances
 	var fieldLevelMetricName string
-	counts := []string{"iterations", "url_count"}
+	counts := []string{"iterations", "url_count", "ajax_cache_hits", "ajax_cache_misses", "ajax_dropped"}
 	for _, count := range counts {
 		switch count {
 		case "url_count":
 			fieldLevelMetricName = fmt.Sprintf("%s.%s", metricName, "ajax.count")
 		case "iterations":
 			fieldLevelMetricName = fmt.Sprintf("%s.%s", metricName, "iterations.count")
+		case "ajax_cache_hits":
+			fieldLevelMetricName = fmt.Sprintf("%s.%s", metricName, "ajax_cache.hits.count")
+		case "ajax_cache_misses":
+			fieldLevelMetricName = fmt.Sprintf("%s.%s", metricName, "ajax_cache.misses.count")
+		case "ajax_dropped":
+			fieldLevelMetricName = fmt.Sprintf("%s.%s", metricName, "ajax_dropped.count")
 		}
 		if val, ok := cutils.GetInt64Key(extractionMetricsJson, count); ok {
 			statsdClient.Count(fieldLevelMetricName, val, tags, 1)
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore writes blobs under a directory on the local filesystem
+// Meant for dev setups and tests, where s3 credentials aren't available
+type LocalStore struct {
+	dir    string
+	prefix string
+}
+
+// NewLocalStore creates a store rooted at dir (created if missing)
+func NewLocalStore(dir, prefix string) (*LocalStore, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "go-crawler")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory %s: %v", dir, err)
+	}
+	return &LocalStore{dir: dir, prefix: prefix}, nil
+}
+
+// Name of the backend
+func (s *LocalStore) Name() string {
+	return BackendLocal
+}
+
+// Put writes data to {dir}/{prefix}/{key}
+func (s *LocalStore) Put(key string, data []byte) (string, error) {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("file://%s", path), nil
+}
+
+// Get reads data written against key
+func (s *LocalStore) Get(key string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(key))
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(join(s.prefix, key)))
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+
+	s3Cache "github.com/Semantics3/sem3-go-crawl-utils/webcache/s3"
+)
+
+// S3Store writes blobs to a s3 bucket, credentials are read from vault
+type S3Store struct {
+	bucket string
+	prefix string
+	client *s3Cache.Client
+}
+
+// NewS3Store creates a store writing to bucket
+func NewS3Store(bucket, prefix string) (*S3Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket is mandatory for s3 storage")
+	}
+	client, err := s3Cache.MakeS3Client(&s3Cache.ClientOpts{
+		BucketName:        bucket,
+		GetCredsFromVault: true,
+		VaultKey:          "engineering/s3/crawl-user",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating s3 client for %s failed with error %v", bucket, err)
+	}
+	return &S3Store{bucket: bucket, prefix: prefix, client: client}, nil
+}
+
+// Name of the backend
+func (s *S3Store) Name() string {
+	return BackendS3
+}
+
+// Put uploads data to s3://{bucket}/{prefix}/{key}
+func (s *S3Store) Put(key string, data []byte) (string, error) {
+	key = join(s.prefix, key)
+	if err := s.client.UploadData(key, string(data), false); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+// Get downloads data written against key
+func (s *S3Store) Get(key string) ([]byte, error) {
+	return s.client.DownloadDataFromS3(join(s.prefix, key))
+}
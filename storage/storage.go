@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Backends supported by NewStore
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Store persists blobs (screenshots, archives etc.) against a key
+// Keys are slash separated relative paths, backends decide where they end up
+type Store interface {
+	// Name of the backend
+	Name() string
+	// Put writes data against key and returns its location (s3://bucket/key, file:///dir/key)
+	Put(key string, data []byte) (location string, err error)
+	// Get reads data written against key
+	Get(key string) ([]byte, error)
+}
+
+// Config selects and configures a storage backend
+type Config struct {
+	// local (default) or s3
+	Backend string `json:"backend"`
+	// Root directory of the local backend
+	Dir string `json:"dir,omitempty"`
+	// Bucket of the s3 backend
+	Bucket string `json:"bucket,omitempty"`
+	// Prepended to all the keys (eg. screenshots/)
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// NewStore creates the store configured in c
+func NewStore(c *Config) (Store, error) {
+	if c == nil {
+		return nil, fmt.Errorf("storage not configured")
+	}
+	switch strings.ToLower(c.Backend) {
+	case BackendLocal, "":
+		return NewLocalStore(c.Dir, c.Prefix)
+	case BackendS3:
+		return NewS3Store(c.Bucket, c.Prefix)
+	}
+	return nil, fmt.Errorf("unsupported storage backend %s", c.Backend)
+}
+
+// join prefixes a key, making sure the result never escapes the root of the store
+func join(prefix, key string) string {
+	key = strings.TrimLeft(key, "/")
+	key = strings.Replace(key, "..", "", -1)
+	if prefix == "" {
+		return key
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimRight(prefix, "/"), key)
+}
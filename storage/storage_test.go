@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StorageSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupTest - Called before each test
+func (suite *StorageSuite) SetupTest() {
+	suite.dir, _ = ioutil.TempDir("", "storage")
+}
+
+// TearDownTest - Called after each test
+func (suite *StorageSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *StorageSuite) Test_01_LocalStorePutGet() {
+	store, err := NewStore(&Config{Backend: "local", Dir: suite.dir, Prefix: "screenshots/"})
+	suite.Nil(err)
+	suite.Equal(BackendLocal, store.Name())
+
+	location, err := store.Put("default/202610/19/3/example.com/product/abc.png", []byte("png"))
+	suite.Nil(err)
+	suite.Equal("file://"+filepath.Join(suite.dir, "screenshots/default/202610/19/3/example.com/product/abc.png"), location)
+
+	data, err := store.Get("default/202610/19/3/example.com/product/abc.png")
+	suite.Nil(err)
+	suite.Equal("png", string(data))
+}
+
+func (suite *StorageSuite) Test_02_KeysNeverEscapeTheRoot() {
+	store, _ := NewLocalStore(suite.dir, "")
+	location, err := store.Put("../../etc/passwd", []byte("x"))
+	suite.Nil(err)
+	suite.Equal("file://"+filepath.Join(suite.dir, "etc/passwd"), location)
+}
+
+func (suite *StorageSuite) Test_03_UnsupportedBackend() {
+	_, err := NewStore(&Config{Backend: "gcs"})
+	suite.NotNil(err)
+	_, err = NewStore(nil)
+	suite.NotNil(err)
+}
+
+func TestStorageTestSuite(t *testing.T) {
+	suite.Run(t, new(StorageSuite))
+}
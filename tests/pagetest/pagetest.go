@@ -0,0 +1,189 @@
+// Package pagetest crawls pages for unit tests of the sources the way proxycloud does with the cache service:
+// pages crawled with a cache key are written to the cache service and answered with the CACHE_WRITTEN placeholder
+package pagetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/types"
+	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
+)
+
+var cacheKeyRegex = regexp.MustCompile(`cache_key:([^;]+);`)
+
+// CacheService is an in-memory cache service
+type CacheService struct {
+	mu   sync.Mutex
+	docs map[string][]byte
+}
+
+// NewCacheService creates an empty cache service
+func NewCacheService() *CacheService {
+	return &CacheService{docs: make(map[string][]byte)}
+}
+
+func (c *CacheService) Name() string {
+	return pagecache.BackendCacheService
+}
+
+func (c *CacheService) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[key]
+	if !ok {
+		return nil, pagecache.ErrNotFound
+	}
+	return doc, nil
+}
+
+func (c *CacheService) Put(key string, doc []byte, expiry int32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs[key] = doc
+	return nil
+}
+
+func (c *CacheService) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.docs, key)
+	return nil
+}
+
+func (c *CacheService) Stat(key string) (pagecache.Stat, error) {
+	return pagecache.Stat{}, fmt.Errorf("not implemented")
+}
+
+// Respond writes the page to the cache service when the request policy has a cache key and returns the placeholder
+// proxycloud answers with, the page otherwise
+func (c *CacheService) Respond(requestPolicy string, url string, content string, status int) string {
+	match := cacheKeyRegex.FindStringSubmatch(requestPolicy)
+	if match == nil {
+		return content
+	}
+	doc, _ := json.Marshal(ctypes.WebResponse{URL: url, Content: content, Status: status, Success: status == http.StatusOK})
+	c.Put(match[1], doc, 0)
+	return fmt.Sprintf("CACHE_WRITTEN: %d bytes", len(content))
+}
+
+// Proxy is a proxycloud router serving pages by url
+type Proxy struct {
+	*httptest.Server
+	Cache *CacheService
+	Pages map[string]string
+
+	mu     sync.Mutex
+	crawls map[string]int
+}
+
+// NewProxy starts a proxy serving pages, urls missing in pages respond with 404
+func NewProxy(pages map[string]string) *Proxy {
+	p := &Proxy{Cache: NewCacheService(), Pages: pages, crawls: make(map[string]int)}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			URL           string `json:"url"`
+			RequestPolicy string `json:"request_policy"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		p.mu.Lock()
+		p.crawls[payload.URL]++
+		p.mu.Unlock()
+
+		content, ok := p.Pages[payload.URL]
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusNotFound
+		} else {
+			content = p.Cache.Respond(payload.RequestPolicy, payload.URL, content, status)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"url": payload.URL, "content": content, "success": ok, "statusCode": status})
+	}))
+	return p
+}
+
+// Crawls returns the number of times url was crawled
+func (p *Proxy) Crawls(url string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.crawls[url]
+}
+
+// Config returns a config crawling through the proxy with the cache service as page cache
+func (p *Proxy) Config() *types.Config {
+	return &types.Config{
+		ConfigData:   &types.ConfigData{ProxyRouter: strings.TrimPrefix(p.URL, "http://")},
+		StatsManager: &types.StatsManager{CrawlMetricsChannel: make(chan types.CrawlMetrics, 100)},
+		PageCache:    p.Cache,
+	}
+}
+
+// Workflow returns the workflow of a crawl of url which no source has downloaded yet
+func Workflow(url string) *types.CrawlWorkflow {
+	return &types.CrawlWorkflow{
+		URL:        url,
+		JobType:    "recrawl",
+		JobParams:  &ctypes.CrawlJobParams{},
+		DomainInfo: &ctypes.DomainInfo{DomainName: "shop.example.com"},
+	}
+}
+
+// Pipeline crawls every page with a cache key and never reads the cache
+type Pipeline struct{}
+
+func (p Pipeline) PreCrawlOps(task string, workflow *types.CrawlWorkflow, appC *types.Config) (string, string, error) {
+	return workflow.URL, "", nil
+}
+
+func (p Pipeline) ShouldReadFromRdstore(workflow *types.CrawlWorkflow) bool {
+	return false
+}
+
+func (p Pipeline) GetCacheExpiryTime() int32 {
+	return 3600
+}
+
+func (p Pipeline) ValidateDomainInfo(workflow *types.CrawlWorkflow) (string, error) {
+	return "", nil
+}
+
+func (p Pipeline) ValidateWebResponse(workflow *types.CrawlWorkflow) (bool, string, error) {
+	if status := workflow.WebResponse.Status; status != http.StatusOK {
+		return false, fmt.Sprintf("HTTP_%d", status), fmt.Errorf("%s responded with status %d", workflow.URL, status)
+	}
+	return true, "", nil
+}
+
+func (p Pipeline) ValidateExtractionResponse(workflow *types.CrawlWorkflow) (string, error) {
+	return "", nil
+}
+
+func (p Pipeline) PrepareRequestConfig(workflow *types.CrawlWorkflow) (types.RequestConfig, string, error) {
+	return types.RequestConfig{
+		JobType:    workflow.JobType,
+		DomainInfo: workflow.DomainInfo,
+		CacheKey:   fmt.Sprintf("ce/%s/%s", workflow.JobType, workflow.URL),
+	}, "", nil
+}
+
+func (p Pipeline) ShouldReadFromCache(workflow *types.CrawlWorkflow) bool {
+	return false
+}
+
+func (p Pipeline) TransformError(code string, err error) (string, error) {
+	return code, err
+}
+
+func (p Pipeline) ShouldCallPostCrawlOpsOnFailure(workflow *types.CrawlWorkflow) bool {
+	return false
+}
+
+func (p Pipeline) PostCrawlOps(task string, workflow *types.CrawlWorkflow, appC *types.Config) (string, error) {
+	return "", nil
+}
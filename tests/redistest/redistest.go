@@ -0,0 +1,389 @@
+// Package redistest provides an in-memory redis for unit tests of code using redigo pools
+// Only the commands used by the crawler are supported: GET, SET (NX, XX, EX), MGET, DEL, EXISTS, INCR, EXPIRE,
+// TTL, HGET, HSET, HDEL, HINCRBY, HGETALL, WATCH/UNWATCH and MULTI/EXEC through Send
+package redistest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Server holds the keys shared by all the connections of its pool
+type Server struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hashes  map[string]map[string]string
+	expiry  map[string]time.Time
+	// Bumped on every write of a key, EXEC is aborted when a watched key changed
+	versions map[string]int64
+	// Commands received, in order
+	Commands []string
+}
+
+// NewServer creates an empty server
+func NewServer() *Server {
+	return &Server{strings: make(map[string]string), hashes: make(map[string]map[string]string), expiry: make(map[string]time.Time), versions: make(map[string]int64)}
+}
+
+// Pool returns a redigo pool connected to the server
+func (s *Server) Pool() *redis.Pool {
+	return &redis.Pool{Dial: func() (redis.Conn, error) {
+		return &conn{server: s}, nil
+	}}
+}
+
+// Keys returns the live keys starting with prefix
+func (s *Server) Keys(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0)
+	for key := range s.strings {
+		if strings.HasPrefix(key, prefix) && !s.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range s.hashes {
+		if strings.HasPrefix(key, prefix) && !s.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Get returns the string value of key
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expired(key) {
+		return "", false
+	}
+	value, ok := s.strings[key]
+	return value, ok
+}
+
+// Set stores a string value
+func (s *Server) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strings[key] = value
+	delete(s.expiry, key)
+}
+
+func (s *Server) expired(key string) bool {
+	at, ok := s.expiry[key]
+	if ok && !time.Now().Before(at) {
+		delete(s.strings, key)
+		delete(s.hashes, key)
+		delete(s.expiry, key)
+		s.versions[key]++
+		return true
+	}
+	return false
+}
+
+func (s *Server) exists(key string) bool {
+	if s.expired(key) {
+		return false
+	}
+	_, isString := s.strings[key]
+	_, isHash := s.hashes[key]
+	return isString || isHash
+}
+
+func (s *Server) version(key string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired(key)
+	return s.versions[key]
+}
+
+func (s *Server) do(command string, args []string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doLocked(command, args)
+}
+
+// exec runs the commands of a transaction, nothing is run when a watched key changed since WATCH
+func (s *Server) exec(watched map[string]int64, queued [][]string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, version := range watched {
+		s.expired(key)
+		if s.versions[key] != version {
+			return nil
+		}
+	}
+	replies := make([]interface{}, 0, len(queued))
+	for _, q := range queued {
+		reply, err := s.doLocked(q[0], q[1:])
+		if err != nil {
+			reply = redis.Error(err.Error())
+		}
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+var errWrongType = redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+var hashCommands = map[string]bool{"HGET": true, "HSET": true, "HDEL": true, "HINCRBY": true, "HGETALL": true}
+
+func (s *Server) doLocked(command string, args []string) (interface{}, error) {
+	s.Commands = append(s.Commands, strings.TrimSpace(command+" "+strings.Join(args, " ")))
+	arity := map[string]int{"GET": 1, "SET": 2, "MGET": 1, "DEL": 1, "EXISTS": 1, "INCR": 1, "EXPIRE": 2, "TTL": 1, "HGET": 2, "HSET": 3, "HDEL": 2, "HINCRBY": 3, "HGETALL": 1}
+	if n, ok := arity[command]; !ok {
+		return nil, fmt.Errorf("redistest: unsupported command %s", command)
+	} else if len(args) < n {
+		return nil, fmt.Errorf("redistest: wrong number of arguments for %s", command)
+	}
+	key := args[0]
+	s.expired(key)
+	_, isString := s.strings[key]
+	_, isHash := s.hashes[key]
+	if (hashCommands[command] && isString) || ((command == "GET" || command == "INCR") && isHash) {
+		return nil, errWrongType
+	}
+
+	switch command {
+	case "GET":
+		if value, ok := s.strings[key]; ok {
+			return []byte(value), nil
+		}
+		return nil, nil
+	case "SET":
+		var nx, xx bool
+		var ttl int
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "EX":
+				i++
+				if i < len(args) {
+					ttl, _ = strconv.Atoi(args[i])
+				}
+			}
+		}
+		if (nx && s.exists(key)) || (xx && !s.exists(key)) {
+			return nil, nil
+		}
+		delete(s.hashes, key)
+		s.strings[key] = args[1]
+		s.versions[key]++
+		delete(s.expiry, key)
+		if ttl > 0 {
+			s.expiry[key] = time.Now().Add(time.Duration(ttl) * time.Second)
+		}
+		return "OK", nil
+	case "MGET":
+		values := make([]interface{}, len(args))
+		for i, k := range args {
+			s.expired(k)
+			if value, ok := s.strings[k]; ok {
+				values[i] = []byte(value)
+			}
+		}
+		return values, nil
+	case "DEL":
+		deleted := int64(0)
+		for _, k := range args {
+			if s.exists(k) {
+				deleted++
+				s.versions[k]++
+			}
+			delete(s.strings, k)
+			delete(s.hashes, k)
+			delete(s.expiry, k)
+		}
+		return deleted, nil
+	case "EXISTS":
+		existing := int64(0)
+		for _, k := range args {
+			if s.exists(k) {
+				existing++
+			}
+		}
+		return existing, nil
+	case "INCR":
+		n, _ := strconv.ParseInt(s.strings[key], 10, 64)
+		n++
+		s.strings[key] = strconv.FormatInt(n, 10)
+		s.versions[key]++
+		return n, nil
+	case "EXPIRE":
+		if !s.exists(key) {
+			return int64(0), nil
+		}
+		seconds, _ := strconv.Atoi(args[1])
+		s.expiry[key] = time.Now().Add(time.Duration(seconds) * time.Second)
+		s.versions[key]++
+		return int64(1), nil
+	case "TTL":
+		if !s.exists(key) {
+			return int64(-2), nil
+		}
+		at, ok := s.expiry[key]
+		if !ok {
+			return int64(-1), nil
+		}
+		return int64(time.Until(at).Seconds() + 0.5), nil
+	case "HGET":
+		if value, ok := s.hashes[key][args[1]]; ok {
+			return []byte(value), nil
+		}
+		return nil, nil
+	case "HSET":
+		if len(args)%2 == 0 {
+			return nil, fmt.Errorf("redistest: wrong number of arguments for %s", command)
+		}
+		if s.hashes[key] == nil {
+			s.hashes[key] = make(map[string]string)
+		}
+		added := int64(0)
+		for i := 1; i < len(args); i += 2 {
+			if _, existed := s.hashes[key][args[i]]; !existed {
+				added++
+			}
+			s.hashes[key][args[i]] = args[i+1]
+		}
+		s.versions[key]++
+		return added, nil
+	case "HDEL":
+		deleted := int64(0)
+		for _, field := range args[1:] {
+			if _, ok := s.hashes[key][field]; ok {
+				deleted++
+				delete(s.hashes[key], field)
+			}
+		}
+		if deleted > 0 {
+			s.versions[key]++
+		}
+		if len(s.hashes[key]) == 0 {
+			delete(s.hashes, key)
+		}
+		return deleted, nil
+	case "HINCRBY":
+		by, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return nil, redis.Error("ERR value is not an integer or out of range")
+		}
+		if s.hashes[key] == nil {
+			s.hashes[key] = make(map[string]string)
+		}
+		n, _ := strconv.ParseInt(s.hashes[key][args[1]], 10, 64)
+		n += by
+		s.hashes[key][args[1]] = strconv.FormatInt(n, 10)
+		s.versions[key]++
+		return n, nil
+	case "HGETALL":
+		values := make([]interface{}, 0)
+		for field, value := range s.hashes[key] {
+			values = append(values, []byte(field), []byte(value))
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+// conn is a connection of the pool, commands sent with Send are queued till EXEC (or Flush)
+type conn struct {
+	server  *Server
+	queued  [][]string
+	pending []interface{}
+	watched map[string]int64
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Err() error {
+	return nil
+}
+
+func (c *conn) Do(command string, args ...interface{}) (interface{}, error) {
+	command = strings.ToUpper(command)
+	switch command {
+	case "":
+		// Like redigo, an empty command flushes and reads all the pending replies
+		c.Flush()
+		c.pending = nil
+		return nil, nil
+	case "MULTI":
+		return "OK", nil
+	case "WATCH":
+		if c.watched == nil {
+			c.watched = make(map[string]int64)
+		}
+		for _, key := range stringArgs(args) {
+			c.watched[key] = c.server.version(key)
+		}
+		return "OK", nil
+	case "UNWATCH":
+		c.watched = nil
+		return "OK", nil
+	case "EXEC":
+		replies := c.server.exec(c.watched, c.queued)
+		c.queued, c.watched = nil, nil
+		return replies, nil
+	}
+	return c.server.do(command, stringArgs(args))
+}
+
+func (c *conn) Send(command string, args ...interface{}) error {
+	command = strings.ToUpper(command)
+	switch command {
+	case "MULTI":
+		return nil
+	case "DISCARD":
+		c.queued, c.watched = nil, nil
+		return nil
+	}
+	c.queued = append(c.queued, append([]string{command}, stringArgs(args)...))
+	return nil
+}
+
+// Flush runs queued commands outside of a transaction, their replies are read with Receive
+func (c *conn) Flush() error {
+	for _, q := range c.queued {
+		reply, err := c.server.do(q[0], q[1:])
+		if err != nil {
+			reply = redis.Error(err.Error())
+		}
+		c.pending = append(c.pending, reply)
+	}
+	c.queued = nil
+	return nil
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return nil, fmt.Errorf("redistest: no pending reply")
+	}
+	reply := c.pending[0]
+	c.pending = c.pending[1:]
+	return reply, nil
+}
+
+func stringArgs(args []interface{}) []string {
+	flat := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch a := arg.(type) {
+		case []byte:
+			flat = append(flat, string(a))
+		case string:
+			flat = append(flat, a)
+		default:
+			flat = append(flat, fmt.Sprint(a))
+		}
+	}
+	return flat
+}
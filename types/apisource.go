@@ -0,0 +1,48 @@
+package types
+
+type (
+	// APISourceConfig defines a data source backed by a JSON product API, in the api_sources of the config or as json
+	// in the api_sources redis hash (by source name)
+	APISourceConfig struct {
+		// Url of the request, {url}, {sku}, {site} and {id} (first group of IDPattern matched on the url) are replaced
+		// with their query escaped values, {env:NAME} with environment variables
+		URL     string            `json:"url"`
+		Method  string            `json:"method,omitempty"`
+		Body    string            `json:"body,omitempty"`
+		Headers map[string]string `json:"headers,omitempty"`
+		// Regular expression matched on the url of the crawl for {id}, eg. "/dp/([A-Z0-9]{10})"
+		IDPattern string         `json:"id_pattern,omitempty"`
+		Auth      *APISourceAuth `json:"auth,omitempty"`
+		// Requests per second across all the workers, unlimited when 0
+		RateLimit int64 `json:"rate_limit,omitempty"`
+		// Timeout of a request in seconds
+		Timeout int     `json:"timeout,omitempty"`
+		Cost    float64 `json:"cost,omitempty"`
+		// Path of the products in the response (the response itself when empty), and paths of their fields
+		// (see sources/mapping)
+		Products   string               `json:"products,omitempty"`
+		Fields     map[string]string    `json:"fields"`
+		Pagination *APISourcePagination `json:"pagination,omitempty"`
+	}
+
+	// APISourceAuth sets the credential read from an environment variable on requests
+	APISourceAuth struct {
+		// bearer (Authorization: Bearer), basic (Env holds user:password), header or query (Name is the header or
+		// query param)
+		Type string `json:"type"`
+		Env  string `json:"env"`
+		Name string `json:"name,omitempty"`
+	}
+
+	// APISourcePagination requests the next pages of a response until a page has no products or MaxPages is reached
+	APISourcePagination struct {
+		// page (Param counts pages from Start), offset (Param moves by Size from Start), cursor (Param is the value
+		// at Next in the previous page) or next_url (Next is the path of the url of the next page)
+		Type     string `json:"type"`
+		Param    string `json:"param,omitempty"`
+		Start    int    `json:"start,omitempty"`
+		Size     int    `json:"size,omitempty"`
+		Next     string `json:"next,omitempty"`
+		MaxPages int    `json:"max_pages,omitempty"`
+	}
+)
@@ -0,0 +1,23 @@
+package types
+
+type (
+	// CascadeBudget limits the sources tried by a CASCADE merge, zero values are unlimited
+	CascadeBudget struct {
+		// Cost in the units of source_costs (config)
+		Cost float64 `json:"cost,omitempty"`
+		// Latency in milliseconds
+		Latency float64 `json:"latency,omitempty"`
+	}
+
+	// CascadePlan is the order the sources of a CASCADE merge were tried in
+	CascadePlan struct {
+		Sources []string      `json:"sources"`
+		Budget  CascadeBudget `json:"budget"`
+		Tried   []string      `json:"tried"`
+		// Sources left out with the reason (cost_budget, latency_budget)
+		Skipped map[string]string `json:"skipped,omitempty"`
+		// Cost spent and time taken (milliseconds) by the tried sources
+		Cost    float64 `json:"cost"`
+		Latency float64 `json:"latency"`
+	}
+)
@@ -3,6 +3,8 @@ package types
 import (
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/Jeffail/tunny"
+	"github.com/Semantics3/go-crawler/pagecache"
+	"github.com/Semantics3/go-crawler/storage"
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 	s3Cache "github.com/Semantics3/sem3-go-crawl-utils/webcache/s3"
 	publish "github.com/Semantics3/sem3-go-data-consumer/publish"
@@ -26,6 +28,9 @@ type (
 		Filename       string `json:"file"`
 		WorkerID       string `json:"worker_id"`
 		JobServerURL   string `json:"jobserver"`
+		FixtureMode    string `json:"fixture-mode"`
+		FixtureDir     string `json:"fixture-dir"`
+		WarcInput      string `json:"warc"`
 	}
 
 	ConfigData struct {
@@ -57,6 +62,17 @@ type (
 		ConsumerSitePoolConfig     map[string]int               `json:"consume_site_pool_map"`
 		PGSkus                     *PGSkus                      `json:"pg_skus"`
 		SourceConfig               map[string]map[string]string `json:"source_config"`
+		AjaxCacheTTL               int                          `json:"ajax_cache_ttl"`
+		SessionPool                *SessionPoolConfig           `json:"session_pool"`
+		Geo                        map[string]*GeoProfile       `json:"geo"`
+		ScreenshotStorage          *storage.Config              `json:"screenshot_storage"`
+		PageCache                  *pagecache.Config            `json:"page_cache"`
+		SWRRefreshInterval         int                          `json:"swr_refresh_interval"`
+		Warc                       *WarcConfig                  `json:"warc"`
+		// Cost of a request to each data source (eg. AMAZON, M101), used to plan CASCADE merges
+		SourceCosts map[string]float64 `json:"source_costs"`
+		// Data sources backed by JSON product APIs, by name (see sources/apisource)
+		APISources map[string]*APISourceConfig `json:"api_sources"`
 	}
 
 	Config struct {
@@ -80,6 +96,9 @@ type (
 		ConsumerSitePoolMap            map[string]*tunny.Pool `json:"consumer_site_pool_map"`
 		PGRaw                          *pg.DB                 //NOTE: Skus db connection.
 		TranslateRPCClient             *s3rpc.RPCClient
+		ScreenshotStore                storage.Store
+		PageCache                      pagecache.PageCache
+		WarcStore                      storage.Store
 	}
 
 	PGSkus struct {
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"sync"
+
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 )
 
@@ -14,6 +16,12 @@ type (
 		DomainInfo          *ctypes.DomainInfo       `json:"domainInfo,omitempty"`
 		RdstoreData         *ctypes.RdstoreParentSKU `json:"rdstore_data,omitempty"`
 		WebResponse         WebResponse              `json:"webResponse"`
+		Session             *CrawlSession            `json:"session,omitempty"`
+		Geo                 *GeoTarget               `json:"geo,omitempty"`
+		Warc                *WarcReference           `json:"warc,omitempty"`
+		MergePlan           *MergePlan               `json:"merge_plan,omitempty"`
+		CascadePlan         *CascadePlan             `json:"cascade_plan,omitempty"`
+		SharedPage          *SharedPage              `json:"-"`
 		AjaxFailedStatusMap map[string]int           `json:"ajax_failed_status_map"`
 		Data                ExtractionResponse       `json:"data"`
 		ProductMetrics      ProductMetrics           `json:"product_metrics"`
@@ -27,6 +35,9 @@ type (
 		CacheExpiry          int32  `json:"cache_expiry"`
 		UnsupervisedCacheKey string `json:"unsupervised_cache_key"`
 		CrawlTime            int64  `json:"crawl_time"`
+		// Set when the response was served from cache (stale-while-revalidate), age in seconds
+		FromCache bool  `json:"from_cache"`
+		Age       int64 `json:"age,omitempty"`
 
 		Status         int     `json:"status"`
 		FailureType    *string `json:"failuretype"`
@@ -41,6 +52,20 @@ type (
 		SendFailureAsFeedback bool                 `json:"send_failure_as_feedback"`
 	}
 
+	// SharedPage is the page downloaded once for the sources of a crawl extracting concurrently (MERGE_ALL)
+	SharedPage struct {
+		Once        sync.Once
+		WebResponse WebResponse
+		CacheKey    string
+		CacheExpiry int32
+		CrawlTime   int64
+		Warc        *WarcReference
+		Session     *CrawlSession
+		CanExtract  bool
+		Code        string
+		Err         error
+	}
+
 	ForwardedCrawlWorkflow struct {
 		DomainInfo  *ctypes.DomainInfo `json:"domainInfo,omitempty"`
 		Data        ExtractionResponse `json:"data"`
@@ -21,6 +21,26 @@ type (
 		UnresolvedAjaxURLs          []AjaxURL                     `json:"unresolved_ajax_urls,omitempty"`
 		OverridingWebResponseStatus int                           `json:"overriding_webresponse_status"`
 		WrapperFilterResults        map[string]bool               `json:"wrapper_filter_results"`
+		// Variations of a source which couldn't be aligned with the variations of other sources while merging
+		UnmatchedVariations []UnmatchedVariation `json:"unmatched_variations,omitempty"`
+		// Sources which failed to extract data while merging (MERGE_ALL)
+		SourceFailures []SourceFailure `json:"source_failures,omitempty"`
+		// Fields on which sources disagreed while merging
+		Conflicts []FieldConflict `json:"conflicts,omitempty"`
+	}
+
+	SourceFailure struct {
+		Source  string `json:"source"`
+		Code    string `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	UnmatchedVariation struct {
+		Source string `json:"source"`
+		// Index of the product in the products of the source
+		Index int    `json:"index"`
+		Sku   string `json:"sku,omitempty"`
+		Name  string `json:"name,omitempty"`
 	}
 
 	UnsupervisedResponse struct {
@@ -0,0 +1,27 @@
+package types
+
+type (
+	// GeoProfile describes how requests are made from a country
+	// Configured per country code under `geo` in the config, sites can override parts of it
+	GeoProfile struct {
+		// Locale used when the geo job param doesn't specify one (eg. de-DE)
+		DefaultLocale string `json:"default_locale"`
+		// Proxy pools with exit nodes in the country
+		Pools []string `json:"pools"`
+		// Additional request headers (Accept-Language is derived from the locale unless set here)
+		Headers map[string]string `json:"headers,omitempty"`
+		// Cookies pinning currency/region on the site (eg. "currency=EUR;country=DE;")
+		Cookie string `json:"cookie,omitempty"`
+		// Site specific overrides, merged over the country profile
+		Sites map[string]*GeoProfile `json:"sites,omitempty"`
+	}
+
+	// GeoTarget is the geo a workflow is crawled from, resolved from the geo job param
+	GeoTarget struct {
+		Country string            `json:"country"`
+		Locale  string            `json:"locale,omitempty"`
+		Pools   []string          `json:"pools,omitempty"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Cookie  string            `json:"cookie,omitempty"`
+	}
+)
@@ -0,0 +1,49 @@
+package types
+
+// ConfidenceKey of a product holds the confidence (0 to 1) of fields of sources which extract heuristically,
+// eg. {"listprice": 0.4}. Fields without a confidence have confidence 1
+const ConfidenceKey = "_confidence"
+
+type (
+	// MergeConfig configures merging for a domain and/or customer, stored as json in the merge_config redis hash
+	MergeConfig struct {
+		MergeMode   string   `json:"merge_mode,omitempty"`
+		DataSources []string `json:"data_sources,omitempty"`
+		// CASCADE merges move on to the next source until every required key has a value
+		RequiredKeys    []string               `json:"required_keys,omitempty"`
+		MergePreference map[string]interface{} `json:"merge_preference,omitempty"`
+		Conflicts       *ConflictConfig        `json:"conflicts,omitempty"`
+	}
+
+	// ConflictConfig configures detection of fields on which sources disagree
+	ConflictConfig struct {
+		// Relative tolerance of numeric fields, eg. {"listprice": 0.1} reports prices differing by more than 10%
+		Numeric map[string]float64 `json:"numeric,omitempty"`
+		// Fields compared case insensitively, eg. ["listprice_currency", "brand"]
+		Categorical []string `json:"categorical,omitempty"`
+		// report (default) only reports conflicts, flag also marks conflicting products for QA, fail fails the crawl
+		Action string `json:"action,omitempty"`
+	}
+
+	// FieldConflict is a field of a product on which sources disagree
+	FieldConflict struct {
+		// Index of the merged product
+		Product int    `json:"product"`
+		Field   string `json:"field"`
+		// Value supplied by each source
+		Values map[string]interface{} `json:"values"`
+		// Source the merged value was taken from
+		Picked string `json:"picked,omitempty"`
+		// Largest relative difference of numeric values ((max - min) / min)
+		Deviation float64 `json:"deviation,omitempty"`
+	}
+
+	// MergePlan is the merge configuration in effect for a crawl
+	MergePlan struct {
+		MergeConfig
+		// Fields of the merge_config redis hash the config was read from, least specific first
+		Layers []string `json:"layers,omitempty"`
+		// Where each setting comes from: request, merge_config, domain_source_map or default
+		Origin map[string]string `json:"origin"`
+	}
+)
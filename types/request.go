@@ -3,6 +3,7 @@ package types
 import (
 	"net/http"
 
+	"github.com/Semantics3/go-crawler/warc"
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 )
 
@@ -14,6 +15,7 @@ type (
 		Cookie        string            `json:"cookie,omitempty"`
 		RequestId     string            `json:"request_id,omitempty"`
 		Headers       map[string]string `json:"headers,omitempty"`
+		Geo           string            `json:"geo,omitempty"`
 	}
 
 	RequestConfig struct {
@@ -32,6 +34,17 @@ type (
 		IsRetry        bool   `json:"is_retry"`
 		ScreenshotPath string `json:"screenshot_path"`
 
+		// Pooled session to make the request with (cookie, user agent and pool affinity)
+		Session *CrawlSession `json:"session,omitempty"`
+		// Geo the request is made from (pools, headers and cookies)
+		Geo *GeoTarget `json:"geo,omitempty"`
+		// Writer recording the request and response as WARC records (nil when export is disabled)
+		Warc *warc.Writer `json:"-"`
+		// Archive the request is answered from (re-extraction of WARC files)
+		Archive *warc.Archive `json:"-"`
+		// Order of the sources planned for the CASCADE merge, eg. WRAPPER>M101
+		SourcePlan string `json:"source_plan,omitempty"`
+
 		// Post request specific
 		Method  string            `json:"method,omitempty"`
 		Headers map[string]string `json:"headers,omitempty"`
@@ -56,6 +69,9 @@ type (
 		TimeTaken      float64                `json:"timeTaken"`
 		ScreenshotPath []string               `json:"screenshot_path"`
 		Headers        ctypes.ResponseHeaders `json:"response_headers"`
+		// WARC record of the response, when WARC export is enabled
+		WarcRecordID string `json:"warc_record_id,omitempty"`
+		WarcFile     string `json:"warc_file,omitempty"`
 	}
 
 	AjaxURL struct {
@@ -96,4 +112,46 @@ type (
 		FailureType    string `json:"failuretype,omitempty"`
 		FailureMessage string `json:"failuremessage,omitempty"`
 	}
+
+	// ScreenshotOptions control how a page is captured and where the image ends up
+	ScreenshotOptions struct {
+		// Browser viewport as WIDTHxHEIGHT (eg. 1366x768)
+		Viewport string `json:"viewport,omitempty"`
+		// Capture the whole page instead of the viewport
+		FullPage bool `json:"full_page,omitempty"`
+		// Capture only the element matching the css selector
+		Selector string `json:"selector,omitempty"`
+		// Return the image (base64) in the response
+		Inline bool `json:"inline,omitempty"`
+		// Write the image to screenshot storage (defaults to true)
+		Store *bool `json:"store,omitempty"`
+	}
+
+	ScreenshotBatchRequest struct {
+		URLs          []string `json:"urls"`
+		Customer      string   `json:"customer,omitempty"`
+		Pools         []string `json:"pools,omitempty"`
+		RequestPolicy string   `json:"request_policy,omitempty"`
+		Timeout       int      `json:"timeout,omitempty"`
+		ScreenshotOptions
+	}
+
+	ScreenshotResult struct {
+		URL    string `json:"url"`
+		Status int    `json:"status"`
+		// Location of the stored image (s3://... or file://...)
+		Screenshot string `json:"screenshot,omitempty"`
+		// Base64 encoded image, only when inline is requested
+		Image          string `json:"image,omitempty"`
+		FailureType    string `json:"failuretype,omitempty"`
+		FailureMessage string `json:"failuremessage,omitempty"`
+	}
+
+	ScreenshotBatchResponse struct {
+		Status  int                `json:"status"`
+		Results []ScreenshotResult `json:"results"`
+		// Request level failures (invalid input)
+		FailureType    string `json:"failuretype,omitempty"`
+		FailureMessage string `json:"failuremessage,omitempty"`
+	}
 )
@@ -0,0 +1,37 @@
+package types
+
+type (
+	// CrawlSession is a warmed browsing session of a site handed out to workflows
+	// Sessions are pooled per site in redis slots and rotated across tasks
+	CrawlSession struct {
+		ID   string `json:"id"`
+		Site string `json:"site"`
+		// Country the session was created from, sessions are pooled per site and geo
+		Geo string `json:"geo,omitempty"`
+		// Slot of the pool holding the session
+		Slot int `json:"slot"`
+		// Sessions are handed out once warmed up
+		Ready     bool   `json:"ready"`
+		Cookie    string `json:"cookie"`
+		UserAgent string `json:"user_agent,omitempty"`
+		// Proxy pools the session is pinned to (pool which served the warm-up request)
+		Pools      []string `json:"pools,omitempty"`
+		CreatedAt  int64    `json:"created_at"`
+		LastUsedAt int64    `json:"last_used_at"`
+		Uses       int      `json:"uses"`
+		// Consecutive failed requests made with the session
+		Failures int `json:"failures"`
+	}
+
+	// SessionPoolConfig defines limits of per-site session pools
+	SessionPoolConfig struct {
+		// Default # of sessions per site (wrapper can override)
+		Size int `json:"size"`
+		// Sessions older than ttl (seconds) are retired
+		TTL int `json:"ttl"`
+		// Sessions are retired after these many consecutive temp errors
+		MaxFailures int `json:"max_failures"`
+		// User agents assigned to new sessions (round robin)
+		UserAgents []string `json:"user_agents"`
+	}
+)
@@ -26,6 +26,10 @@ type (
 		NodePool string `json:"node_pool"`
 		// Render pool that was used for the request
 		RenderPool string `json:"render_pool"`
+		// Country (and locale) the request was made from, empty when no geo was requested
+		Geo string `json:"geo"`
+		// Order of the sources planned for the CASCADE merge, empty for other merges
+		SourcePlan string `json:"source_plan"`
 		// Web response status code as a string
 		Status string `json:"status"`
 		// True indicates a secondary web request
@@ -89,6 +93,11 @@ type (
 		UrlCount int `json:"url_count"`
 		// # of iterations made back&forth between crawler and ce
 		Iterations int `json:"iterations"`
+		// # of ajax requests served by (or fetched into) the batch ajax cache
+		AjaxCacheHits   int `json:"ajax_cache_hits"`
+		AjaxCacheMisses int `json:"ajax_cache_misses"`
+		// # of ajax requests dropped as they were over the ajax request budget
+		AjaxDropped int `json:"ajax_dropped"`
 		// Always 1
 		Value int `json:"value"`
 	}
@@ -0,0 +1,23 @@
+package types
+
+import "github.com/Semantics3/go-crawler/storage"
+
+type (
+	// WarcConfig enables WARC export of crawled responses
+	// Batches with job param `warc: 1` and batches of the listed customers are exported
+	WarcConfig struct {
+		Storage   *storage.Config `json:"storage"`
+		Customers []string        `json:"customers,omitempty"`
+		// Files are rotated once they reach this size in bytes (100MB by default)
+		MaxFileSize int `json:"max_file_size,omitempty"`
+	}
+
+	// WarcReference points to the WARC records written for a workflow
+	WarcReference struct {
+		// WARC-Record-ID of the primary response
+		RecordID string `json:"record_id,omitempty"`
+		// Storage key of the WARC file holding the primary response
+		File          string   `json:"file,omitempty"`
+		AjaxRecordIDs []string `json:"ajax_record_ids,omitempty"`
+	}
+)
@@ -4,13 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/Semantics3/go-crawler/pagecache"
 	"github.com/Semantics3/go-crawler/types"
+	htmlutils "github.com/Semantics3/sem3-go-crawl-utils/html"
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
-	htmlCache "github.com/Semantics3/sem3-go-crawl-utils/webcache/html"
-	s3Cache "github.com/Semantics3/sem3-go-crawl-utils/webcache/s3"
 )
 
 // Marshal struct to a JSON string but ensure that the process is repeatable
@@ -33,40 +34,69 @@ func canonicalJsonMarshal(c types.CacheKeyConfig, url string) (string, error) {
 // Send nil values as request config, as respective fields
 // are needed only for ajax requests
 func ConstructCacheId(url string, domain string, jobType string, workflow *types.CrawlWorkflow, wrapperBrowser ctypes.WrapperBrowser) (string, error) {
-	var c types.CacheKeyConfig
+	c := CacheKeyFields(url, domain, workflow, wrapperBrowser)
+	canonicalJson, rHash, err := HashCacheKeyFields(c, url)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("CACHE_KEY_FIELDS: %s, CACHE_KEY_GENERATED: %s\n", canonicalJson, rHash)
+	return rHash, nil
+}
+
+// CacheKeyFields collects the request fields which identify a cached page
+func CacheKeyFields(url string, domain string, workflow *types.CrawlWorkflow, wrapperBrowser ctypes.WrapperBrowser) (c types.CacheKeyConfig) {
 	c.Url = url
 	c.Domain = domain
 	c.Headers = GetRequestHeaders(nil, wrapperBrowser)
 	c.RequestPolicy = GetRequestPolicy(workflow.JobParams, wrapperBrowser)
 	c.Cookie = GetCookies(c.RequestPolicy, nil, workflow.JobParams, wrapperBrowser)
+	// Different geos must never share cached html
+	c.Geo = GeoName(workflow.Geo)
 
 	// Get request_id
 	if workflow.RequestId != "" {
 		c.RequestId = workflow.RequestId
 	}
+	return c
+}
 
-	canonicalJson, err := canonicalJsonMarshal(c, url)
+// HashCacheKeyFields returns the canonical json of the fields and its md5 hash (cache id)
+func HashCacheKeyFields(c types.CacheKeyConfig, url string) (canonicalJson string, rHash string, err error) {
+	canonicalJson, err = canonicalJsonMarshal(c, url)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	rHash, err := Md5Hash(canonicalJson)
+	rHash, err = Md5Hash(canonicalJson)
 	if err != nil {
-		return "", cutils.PrintErr("CREATING_MD5HASH_FAILED", fmt.Sprintf("Failed to create md5hash for: %s", canonicalJson), err)
+		return "", "", cutils.PrintErr("CREATING_MD5HASH_FAILED", fmt.Sprintf("Failed to create md5hash for: %s", canonicalJson), err)
 	}
-	log.Printf("CACHE_KEY_FIELDS: %s, CACHE_KEY_GENERATED: %s\n", canonicalJson, string(rHash))
-	return string(rHash), nil
+	return canonicalJson, rHash, nil
+}
+
+// CacheKeyPrefix is the part of cache keys shared by all pages of a site
+// Keys are {cache folder}/{job type}/{site with dots replaced by underscores}/{cache id}
+func CacheKeyPrefix(cacheFolder string, jobType string, site string) string {
+	if cacheFolder == "" {
+		cacheFolder = "ce"
+	}
+	return fmt.Sprintf("%s/%s/%s/", cacheFolder, jobType, strings.Replace(site, ".", "_", -1))
+}
+
+// ConstructCacheKey returns the cache key of a page from its cache id
+func ConstructCacheKey(cacheFolder string, jobType string, site string, cacheId string) string {
+	return CacheKeyPrefix(cacheFolder, jobType, site) + cacheId
 }
 
 // ReadDataFromCache Read html data for the request from cache
 // Checks if request is eligible for reading from cache based on
 // 1. Flag sent through job params
 // 2. When was the object written to cache (ttl)
-func ReadDataFromCache(cacheServiceHost, cacheKey string, workflow *types.CrawlWorkflow) {
+func ReadDataFromCache(cache pagecache.PageCache, cacheKey string, workflow *types.CrawlWorkflow) {
 	start := time.Now()
 	jobParams := workflow.JobParams
 
 	// Lookup against cache
-	bodyBytes, err := htmlCache.DownloadHtmlUsingCacheService(cacheServiceHost, cacheKey)
+	bodyBytes, err := cache.Get(cacheKey)
 	if err != nil {
 		log.Println(err.Error())
 		return
@@ -136,14 +166,14 @@ func ReadDataFromCache(cacheServiceHost, cacheKey string, workflow *types.CrawlW
 	}
 }
 
-// WriteDataToCache - uploads data to cache using cache service
-func WriteDataToCache(url, cacheService, cacheKey string, data interface{}, expiry int32) error {
+// WriteDataToCache - uploads data to the page cache
+func WriteDataToCache(url string, cache pagecache.PageCache, cacheKey string, data interface{}, expiry int32) error {
 	cacheContent, err := json.Marshal(data)
 	if err != nil {
 		err = cutils.PrintErr("WEBCACHE_JSON_ENCODE_ERROR", fmt.Sprintf("Error encoding cache document: %v\n", data), err)
 		return err
 	} else {
-		_, err = htmlCache.UploadHtmlUsingCacheService(cacheService, cacheKey, cacheContent, expiry)
+		err = cache.Put(cacheKey, cacheContent, expiry)
 		if err != nil {
 			err = cutils.PrintErr("WEBCACHE_WRITE_ERROR", fmt.Sprintf("Error writing to cache : (%s) %s\n", url, cacheKey), err)
 			return err
@@ -153,93 +183,55 @@ func WriteDataToCache(url, cacheService, cacheKey string, data interface{}, expi
 	return nil
 }
 
-// ReadDataFromS3 Read html data for the request from S3
-// Checks if request is eligible for reading from cache based on
-// 1. Flag sent through job params
-// 2. When was the object written to cache (ttl)
-func ReadDataFromS3(cacheKey string, workflow *types.CrawlWorkflow, s3Client *s3Cache.Client) {
-	start := time.Now()
-
-	// Default cache expiry time is 60 mins
-	ttl := workflow.JobParams.CacheTtl
-	if ttl == 0 {
-		ttl = 1 * 60 * 60
-	}
+// WriteWebResponseToCache caches the crawled page of a workflow
+// Proxycloud writes pages to the cache service itself (cache_key in request policy),
+// pages are written by the crawler only for the other page cache backends
+func WriteWebResponseToCache(workflow *types.CrawlWorkflow, appC *types.Config) {
+	WriteResponseToCache(workflow.URL, workflow.CacheKey, workflow.CacheExpiry, workflow.CrawlTime, workflow.WebResponse, appC)
+}
 
-	// Download data from S3
-	bodyBytes, err := s3Client.DownloadDataFromS3(cacheKey)
-	if err != nil {
-		log.Println(err.Error())
+// WriteResponseToCache caches a crawled response (page or ajax) under cacheKey, see WriteWebResponseToCache
+func WriteResponseToCache(url, cacheKey string, expiry int32, crawlTime int64, wr types.WebResponse, appC *types.Config) {
+	if appC == nil || pagecache.IsWrittenByProxycloud(appC.PageCache) || wr.FromCache || cacheKey == "" || !htmlutils.IsSuccess(wr.Status) {
 		return
 	}
-
-	// Decompress data
-	decompressedContentBytes, err := htmlCache.DecompressTextContent(bodyBytes)
-	if err != nil {
-		log.Println(err.Error())
-		return
+	doc := ctypes.WebResponse{
+		URL:         wr.URL,
+		RedirectURL: wr.Redirect,
+		Content:     wr.Content,
+		Success:     wr.Success,
+		Status:      wr.Status,
+		StatusCode:  wr.Status,
+		TimeTaken:   wr.TimeTaken,
+		Time:        crawlTime,
+		Message:     wr.Message,
+		Cookie:      wr.Cookie,
+		Headers:     wr.Headers,
+	}
+	if doc.Time == 0 {
+		doc.Time = time.Now().Unix()
+	}
+	if err := WriteDataToCache(url, appC.PageCache, cacheKey, doc, expiry); err == nil {
+		log.Printf("CACHE_WRITTEN: (%s) %s, backend %s\n", url, cacheKey, appC.PageCache.Name())
 	}
+}
 
-	// If lookup is successful, uncompress and decode to struct
-	resp := &ctypes.WebResponse{}
-	err = json.Unmarshal(decompressedContentBytes, resp)
-	if err != nil {
-		log.Printf("CACHE_READ_JSON_DECODE_ERROR: location: %s, error: %v", cacheKey, err)
-		return
+// ReadCachedContent reads the content of a page cached under cacheKey
+// Used to recover the html of a page when proxycloud returned the CACHE_WRITTEN placeholder
+func ReadCachedContent(cache pagecache.PageCache, cacheKey string) (string, error) {
+	if cache == nil {
+		return "", cutils.PrintErr("CACHE_READ_ERROR", fmt.Sprintf("No page cache to read %s from", cacheKey), fmt.Errorf("page cache is not configured"))
 	}
-
-	// Check if cached data is obsolete
-	if ttl > 0 {
-		end := time.Now()
-		duration := end.Sub(time.Unix(resp.Time, 0))
-		seconds := int(duration.Seconds())
-		if seconds >= ttl {
-			log.Printf("CACHE_READ_TTL_EXCEEDED_ERROR: location: %s, age: %d seconds", cacheKey, seconds)
-			return
-		}
+	bodyBytes, err := cache.Get(cacheKey)
+	if err != nil {
+		return "", cutils.PrintErr("CACHE_READ_ERROR", fmt.Sprintf("Failed to read %s from %s", cacheKey, cache.Name()), err)
 	}
-
-	if resp.Content != "" {
-		// Construct web response object
-		workflow.WebResponse = types.WebResponse{
-			URL:       resp.URL,
-			Redirect:  resp.RedirectURL,
-			Status:    resp.Status,
-			Success:   resp.Success,
-			Time:      resp.Time,
-			Cookie:    resp.Cookie,
-			Headers:   resp.Headers,
-			Message:   resp.Message,
-			Content:   fmt.Sprintf("CACHE_READ_FROM_S3: (%s) %s", workflow.URL, cacheKey),
-			FromCache: true,
-			Attempts:  1,
-			TimeTaken: ComputeDuration(start),
-		}
-		workflow.CrawlTime = resp.Time
-	} else {
-		log.Printf("CRAWL_CACHEMISS: (%s) s3path %s\n", workflow.URL, workflow.CacheKey)
-		// FIXME: this is not the right place for this
-		workflow.CrawlTime = time.Now().Unix()
+	resp := &ctypes.WebResponse{}
+	if err = json.Unmarshal(bodyBytes, resp); err != nil {
+		return "", cutils.PrintErr("CACHE_READ_JSON_DECODE_ERROR", fmt.Sprintf("Failed to decode %s", cacheKey), err)
 	}
-}
-
-// WriteDataToS3 - uploads data to s3
-func WriteDataToS3(url, cacheKey string, data interface{}, s3Client *s3Cache.Client) error {
-	cacheContent, err := json.Marshal(data)
-	if err != nil {
-		err = cutils.PrintErr("WEBCACHE_JSON_ENCODE_ERROR", fmt.Sprintf("Error encoding cache document: %v\n", data), err)
-		return err
-	} else {
-		// Compress data
-		compressedContentBytes := htmlCache.CompressTextContent(cacheContent)
-
-		// Set expiry for the cache object
-		err = s3Client.UploadData(cacheKey, string(compressedContentBytes), false)
-		if err != nil {
-			err = cutils.PrintErr("WEBCACHE_WRITE_ERROR", fmt.Sprintf("Error writing to cache : (%s) %s\n", url, cacheKey), err)
-			return err
-		}
+	if resp.Content == "" || IsCacheWritten(resp.Content) {
+		return "", cutils.PrintErr("CACHE_READ_EMPTY_CONTENT", fmt.Sprintf("No content cached under %s", cacheKey), fmt.Errorf("empty content"))
 	}
-
-	return nil
+	return resp.Content, nil
 }
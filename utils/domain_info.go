@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Semantics3/go-crawler/fixture"
 	"github.com/Semantics3/sem3-go-crawl-utils/jobs"
 	ctypes "github.com/Semantics3/sem3-go-crawl-utils/types"
 )
@@ -21,7 +22,11 @@ func GetDomainName(url, wrapperServiceURI string) (domain string, err error) {
 		"url": url,
 	}
 	reqURL := fmt.Sprintf("http://%s/domain/url", wrapperServiceURI)
-	bodyBytes, err := jobs.RequestUrl("POST", reqURL, reqBody, "proxy-node")
+	var bodyBytes []byte
+	err = fixture.Replay(fixture.KindService, fmt.Sprintf("POST %s %s", reqURL, url), &bodyBytes, func() (err error) {
+		bodyBytes, err = jobs.RequestUrl("POST", reqURL, reqBody, "proxy-node")
+		return err
+	})
 	if err != nil {
 		return domain, fmt.Errorf("DOMAIN_EXTRACT_FETCH_ERR: url: %s, %v", url, err)
 	}
@@ -81,7 +86,12 @@ func requestWrapperService(url, jobType, wrapperServiceURI string, sendWrapper i
 		"send_wrapper": sendWrapper,
 	}
 	reqURL := fmt.Sprintf("http://%s/domain/info", wrapperServiceURI)
-	bodyBytes, err := jobs.RequestUrl("POST", reqURL, reqBody, fmt.Sprintf("%s-crawler", jobType))
+	var bodyBytes []byte
+	fixtureKey := fmt.Sprintf("POST %s %s %s %d", reqURL, url, jobType, sendWrapper)
+	err := fixture.Replay(fixture.KindService, fixtureKey, &bodyBytes, func() (err error) {
+		bodyBytes, err = jobs.RequestUrl("POST", reqURL, reqBody, fmt.Sprintf("%s-crawler", jobType))
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("FETCH_ERR: %v", err)
 	}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Semantics3/go-crawler/types"
+	cutils "github.com/Semantics3/sem3-go-crawl-utils/utils"
+)
+
+// ParseGeo reads country and locale from the geo job param
+// Accepts a country code with an optional locale ("DE", "CH:fr-CH")
+// or an object ({"country": "CH", "locale": "fr-CH"})
+func ParseGeo(jobParams map[string]interface{}) (country string, locale string, ok bool) {
+	if geo, found := cutils.GetStringKey(jobParams, "geo"); found {
+		parts := strings.SplitN(geo, ":", 2)
+		country = parts[0]
+		if len(parts) == 2 {
+			locale = parts[1]
+		}
+	} else if geo, found := cutils.GetMapInterface(jobParams, "geo"); found {
+		country, _ = cutils.GetStringKey(geo, "country")
+		locale, _ = cutils.GetStringKey(geo, "locale")
+	}
+	country = strings.ToUpper(strings.TrimSpace(country))
+	return country, strings.TrimSpace(locale), country != ""
+}
+
+// ResolveGeo maps the geo job param of a workflow to pools, headers and cookies
+// Returns nil when no geo was requested, and an error when the country isn't configured
+func ResolveGeo(workflow *types.CrawlWorkflow, appC *types.Config) (geo *types.GeoTarget, code string, err error) {
+	if workflow.JobInput == nil {
+		return nil, "", nil
+	}
+	country, locale, ok := ParseGeo(workflow.JobInput.JobParams)
+	if !ok {
+		return nil, "", nil
+	}
+
+	profile, found := appC.ConfigData.Geo[country]
+	if !found || profile == nil {
+		code = "GEO_NOT_SUPPORTED"
+		return nil, code, cutils.PrintErr(code, fmt.Sprintf("no geo profile configured for country %s", country), workflow.URL)
+	}
+
+	geo = &types.GeoTarget{
+		Country: country,
+		Locale:  profile.DefaultLocale,
+		Pools:   profile.Pools,
+		Cookie:  profile.Cookie,
+		Headers: make(map[string]string),
+	}
+	for k, v := range profile.Headers {
+		geo.Headers[k] = v
+	}
+
+	// Site overrides take precedence over the country profile
+	if workflow.DomainInfo != nil {
+		if site, found := profile.Sites[workflow.DomainInfo.DomainName]; found && site != nil {
+			if site.DefaultLocale != "" {
+				geo.Locale = site.DefaultLocale
+			}
+			if len(site.Pools) > 0 {
+				geo.Pools = site.Pools
+			}
+			if site.Cookie != "" {
+				geo.Cookie = MergeCookies(geo.Cookie, site.Cookie)
+			}
+			for k, v := range site.Headers {
+				geo.Headers[k] = v
+			}
+		}
+	}
+
+	// Locale requested in job params always wins
+	if locale != "" {
+		geo.Locale = locale
+	}
+	if _, found := geo.Headers["Accept-Language"]; !found && geo.Locale != "" {
+		geo.Headers["Accept-Language"] = acceptLanguage(geo.Locale)
+	}
+
+	log.Printf("GEO_RESOLVED: URL: %s, COUNTRY: %s, LOCALE: %s, POOLS: %v\n", workflow.URL, geo.Country, geo.Locale, geo.Pools)
+	return geo, "", nil
+}
+
+// GeoName identifies a geo in metrics and cache keys (eg. CH:fr-CH)
+func GeoName(geo *types.GeoTarget) string {
+	if geo == nil {
+		return ""
+	}
+	if geo.Locale == "" {
+		return geo.Country
+	}
+	return fmt.Sprintf("%s:%s", geo.Country, geo.Locale)
+}
+
+// Accept-Language header preferring the locale and then its language
+// Example: fr-CH => "fr-CH,fr;q=0.9"
+func acceptLanguage(locale string) string {
+	language := strings.SplitN(locale, "-", 2)[0]
+	if language == locale {
+		return locale
+	}
+	return fmt.Sprintf("%s,%s;q=0.9", locale, language)
+}
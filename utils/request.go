@@ -58,6 +58,14 @@ func GetScreenshotPath(site string, url string, isProductUrl bool, requestPolicy
 
 // Construct screenshot path for a request
 func constructScreenshotPath(site string, url string, isProductUrl bool, requestPolicy string, customer string) (screenshotPath string) {
+	screenshotPath = fmt.Sprintf("s3://sem3-web-prod/%s", ScreenshotKey(site, url, isProductUrl, requestPolicy, customer))
+	log.Printf("SITE: %s, URL: %s, PRODUCT_URL: %t, SCREENSHOT_PATH: %s\n", site, url, isProductUrl, screenshotPath)
+	return screenshotPath
+}
+
+// ScreenshotKey constructs the storage key of a screenshot
+// screenshots/{customer}/{yyyymm}/{dd}/{hour}/{site}/{page_type}/{md5(url)}
+func ScreenshotKey(site string, url string, isProductUrl bool, requestPolicy string, customer string) string {
 
 	timeString := time.Now().Format("01-02-2006")
 	entities := strings.Split(timeString, "-")
@@ -82,28 +90,133 @@ func constructScreenshotPath(site string, url string, isProductUrl bool, request
 
 	// TODO: Catch error
 	hashDigest, _ := Md5Hash(url)
-	screenshotPath = fmt.Sprintf("s3://sem3-web-prod/screenshots/%s/%s/%s/%s/", customer, dateString, site, pageType)
-	screenshotPath = fmt.Sprintf("%s%s", screenshotPath, hashDigest)
-	log.Printf("SITE: %s, URL: %s, PRODUCT_URL: %t, SCREENSHOT_PATH: %s\n", site, url, isProductUrl, screenshotPath)
+	return fmt.Sprintf("screenshots/%s/%s/%s/%s/%s", customer, dateString, site, pageType, hashDigest)
+}
 
-	return screenshotPath
+// GetInlineScreenshotPolicy adds capture options to the request policy
+// Proxycloud returns the image in the response (screenshot:inline) instead of uploading it
+// Example: viewport:1366x768;full_page:1;screenshot_selector:I3ByaWNl;screenshot:inline;
+func GetInlineScreenshotPolicy(requestPolicy string, options types.ScreenshotOptions) (string, error) {
+	if requestPolicy != "" && !strings.HasSuffix(requestPolicy, ";") {
+		requestPolicy = fmt.Sprintf("%s;", requestPolicy)
+	}
+	if options.Viewport != "" {
+		if !viewportRegex.MatchString(options.Viewport) {
+			return "", fmt.Errorf("invalid viewport %s, expected WIDTHxHEIGHT", options.Viewport)
+		}
+		requestPolicy = fmt.Sprintf("%sviewport:%s;", requestPolicy, options.Viewport)
+	}
+	if options.FullPage {
+		requestPolicy = fmt.Sprintf("%sfull_page:1;", requestPolicy)
+	}
+	if options.Selector != "" {
+		// Selectors can contain ; and :, hence encoded
+		requestPolicy = fmt.Sprintf("%sscreenshot_selector:%s;", requestPolicy, b64.StdEncoding.EncodeToString([]byte(options.Selector)))
+	}
+	return fmt.Sprintf("%sscreenshot:inline;", requestPolicy), nil
 }
 
+var viewportRegex = regexp.MustCompile(`^\d{2,5}x\d{2,5}$`)
+
 // Set cookies
 func GetCookies(requestPolicy string, config *types.RequestConfig, jobParams *ctypes.CrawlJobParams, wrapperBrowser ctypes.WrapperBrowser) (cookie string) {
 
 	if config != nil && config.IsAjax == true && config.Cookie != "" {
 		// For ajax requests, set cookie received from parent request
-		cookie = config.Cookie
+		cookie = NormalizeCookie(config.Cookie)
+	} else if config != nil && config.Session != nil && config.Session.Cookie != "" {
+		// Pooled sessions carry cookies collected across tasks
+		cookie = config.Session.Cookie
+	} else if _, _, ok := GetSessionPoolDirective(wrapperBrowser); ok {
+		// Session pool directive is meant for the crawler, never send it to proxycloud
+		cookie = ""
 	} else if wrapperBrowser.Cookie == "1" && requestPolicy == "" {
 		cookie = "SESSION:2;"
 	} else if wrapperBrowser.Cookie != "" && wrapperBrowser.Cookie != "1" {
 		cookie = wrapperBrowser.Cookie
 	}
 
+	// Pin currency/region of the geo, proxycloud directives (SESSION:2;) are left untouched
+	if config != nil && config.Geo != nil && config.Geo.Cookie != "" {
+		if cookie == "" || strings.Contains(cookie, "=") {
+			cookie = MergeCookies(cookie, config.Geo.Cookie)
+		}
+	}
+
 	return
 }
 
+// GetSessionPoolDirective parses the session pool directive set as wrapper browser cookie
+// Example: "SESSION_POOL:5;WARMUP:https://www.example.com/;" => pool of 5 sessions warmed up by visiting the home page
+// Size is 0 when not specified, so that the configured default is used
+func GetSessionPoolDirective(wrapperBrowser ctypes.WrapperBrowser) (size int, warmupURL string, ok bool) {
+	if !strings.HasPrefix(strings.ToUpper(wrapperBrowser.Cookie), "SESSION_POOL") {
+		return 0, "", false
+	}
+	for _, component := range strings.Split(wrapperBrowser.Cookie, ";") {
+		i := strings.Index(component, ":")
+		if i < 0 {
+			continue
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(component[:i])), strings.TrimSpace(component[i+1:])
+		switch key {
+		case "SESSION_POOL":
+			size, _ = strconv.Atoi(value)
+		case "WARMUP":
+			warmupURL = value
+		}
+	}
+	return size, warmupURL, true
+}
+
+// MergeCookies overrides cookies in current with the ones set in updated
+func MergeCookies(current, updated string) string {
+	names := make([]string, 0)
+	values := make(map[string]string)
+	for _, c := range []string{NormalizeCookie(current), NormalizeCookie(updated)} {
+		for _, pair := range strings.Split(c, ";") {
+			i := strings.Index(pair, "=")
+			if i < 0 {
+				continue
+			}
+			name := pair[:i]
+			if _, ok := values[name]; !ok {
+				names = append(names, name)
+			}
+			values[name] = pair[i+1:]
+		}
+	}
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s;", name, values[name])
+	}
+	return b.String()
+}
+
+// Cookie attributes which can show up when a Set-Cookie header is passed on as a cookie
+var cookieAttributes = []string{"path", "domain", "expires", "max-age", "secure", "httponly", "samesite", "priority"}
+
+// NormalizeCookie parses a cookie string into `name=value;` pairs
+// 1. Values can themselves contain = (base64 values, signed sessions)
+// 2. Empty values are retained (secure_customer_sig=)
+// 3. Set-Cookie attributes and pairs without a name are dropped
+// Example: "uid=lo_2arJ; sid=1:kgKY+nZw==; Path=/" => "uid=lo_2arJ;sid=1:kgKY+nZw==;"
+func NormalizeCookie(cookie string) string {
+	var b strings.Builder
+	for _, pair := range strings.Split(cookie, ";") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			continue
+		}
+		name, value := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		if name == "" || cutils.StringInSlice(strings.ToLower(name), cookieAttributes) {
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s;", name, value)
+	}
+	return b.String()
+}
+
 // Get request timeout
 func GetRequestTimeout(jobParams *ctypes.CrawlJobParams, wrapperBrowser ctypes.WrapperBrowser) (timeout int) {
 	timeout = 60
@@ -141,10 +254,10 @@ func GetProxyPools(jobParams *ctypes.CrawlJobParams, wrapperBrowser ctypes.Wrapp
 }
 
 func GetRequestHeaders(config *types.RequestConfig, wrapperBrowser ctypes.WrapperBrowser) (requestHeaders map[string]string) {
-	requestHeaders = make(map[string]string)
-
-	if wrapperBrowser.RequestHeaders != nil {
-		requestHeaders = wrapperBrowser.RequestHeaders
+	// Headers of the wrapper browser are shared by all the crawls of the site, never update them in place
+	requestHeaders = make(map[string]string, len(wrapperBrowser.RequestHeaders))
+	for k, v := range wrapperBrowser.RequestHeaders {
+		requestHeaders[k] = v
 	}
 
 	if wrapperBrowser.UserAgent != "" {
@@ -158,6 +271,18 @@ func GetRequestHeaders(config *types.RequestConfig, wrapperBrowser ctypes.Wrappe
 		requestHeaders["Referer"] = config.ParentUrl
 	}
 
+	// Requests of a session must look like they come from the same browser
+	if config != nil && config.Session != nil && config.Session.UserAgent != "" {
+		requestHeaders["User-Agent"] = config.Session.UserAgent
+	}
+
+	// Language (and any other) headers expected from visitors of the geo
+	if config != nil && config.Geo != nil {
+		for k, v := range config.Geo.Headers {
+			requestHeaders[k] = v
+		}
+	}
+
 	// Always give precedence to config
 	// Preprocess script might send custom headers for ajax requests
 	if config != nil && config.Headers != nil {
@@ -196,6 +321,7 @@ func ConstructRequestConfig(url string, site string, isAjax bool, workflow *type
 	config.DomainInfo = workflow.DomainInfo
 	config.IsAjax = isAjax
 	config.JobType = workflow.ProductMetrics.JobType
+	config.Geo = workflow.Geo
 
 	return
 }
@@ -244,12 +370,19 @@ func PrintResponseDetails(status int, details string) {
 	log.Println(formatLog(details))
 }
 
+// Proxycloud returns this placeholder instead of the page when it writes the page to the cache service
+var cacheWrittenRegex = regexp.MustCompile(`CACHE_WRITTEN: (\d+) bytes`)
+
+// IsCacheWritten reports whether content is the placeholder of a page written to the cache service
+func IsCacheWritten(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(content), "CACHE_WRITTEN: ") && cacheWrittenRegex.MatchString(content)
+}
+
 func GetContentLength(content string) (int, error) {
 	var length int
 	var err error
-	re, _ := regexp.Compile(`CACHE_WRITTEN: (\d+) bytes`)
-	if re.MatchString(content) {
-		matches := re.FindStringSubmatch(content)
+	if cacheWrittenRegex.MatchString(content) {
+		matches := cacheWrittenRegex.FindStringSubmatch(content)
 		if length, err = strconv.Atoi(matches[1]); err != nil {
 			return 0, fmt.Errorf("Capturing content size failed with error %v\n", err)
 		}
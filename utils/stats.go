@@ -43,6 +43,8 @@ func UpdateCrawlMetrics(site string, config *types.RequestConfig, webResponse *t
 		crawlMetrics.RenderPool = "no_render"
 	}
 
+	crawlMetrics.Geo = GeoName(config.Geo)
+	crawlMetrics.SourcePlan = config.SourcePlan
 	crawlMetrics.ContentLength = webResponse.ResponseSize
 	crawlMetrics.Status = strconv.Itoa(webResponse.Status)
 	crawlMetrics.IsAjax = strconv.FormatBool(config.IsAjax)
@@ -0,0 +1,164 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is an archived http response read from a WARC file
+type Response struct {
+	RecordID string
+	URL      string
+	Date     time.Time
+	Status   int
+	Headers  map[string]string
+	Content  string
+}
+
+// Archive indexes the responses of WARC files by target url
+// Later records of a url replace earlier ones
+type Archive struct {
+	responses map[string]*Response
+	urls      []string
+}
+
+// NewArchive creates an empty archive
+func NewArchive() *Archive {
+	return &Archive{responses: make(map[string]*Response)}
+}
+
+// Get returns the archived response of url
+func (a *Archive) Get(url string) (*Response, bool) {
+	response, ok := a.responses[url]
+	if !ok {
+		response, ok = a.responses[strings.TrimSuffix(url, "/")]
+	}
+	return response, ok
+}
+
+// URLs returns the archived urls in the order they were first read
+func (a *Archive) URLs() []string {
+	return a.urls
+}
+
+// Len returns the number of archived urls
+func (a *Archive) Len() int {
+	return len(a.urls)
+}
+
+// Read adds response records of a WARC file (.warc or .warc.gz) to the archive
+// Other record types (request, warcinfo, revisit, metadata) are skipped
+func (a *Archive) Read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	for {
+		headers, block, err := readRecord(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if headers["warc-type"] != "response" || !strings.HasPrefix(headers["content-type"], "application/http") {
+			continue
+		}
+		response, err := parseResponse(headers, block)
+		if err != nil {
+			return fmt.Errorf("failed to parse warc record %s: %v", headers["warc-record-id"], err)
+		}
+		if _, ok := a.responses[response.URL]; !ok {
+			a.urls = append(a.urls, response.URL)
+		}
+		a.responses[response.URL] = response
+	}
+}
+
+// Read the next record, header names are lower cased
+func readRecord(br *bufio.Reader) (headers map[string]string, block []byte, err error) {
+	// Skip blank lines separating records
+	var line string
+	for line == "" {
+		if line, err = br.ReadString('\n'); err != nil {
+			if err == io.EOF && strings.TrimSpace(line) == "" {
+				return nil, nil, io.EOF
+			}
+			return nil, nil, err
+		}
+		line = strings.TrimSpace(line)
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, nil, fmt.Errorf("invalid warc record version line %q", line)
+	}
+
+	headers = make(map[string]string)
+	for {
+		if line, err = br.ReadString('\n'); err != nil {
+			return nil, nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			headers[strings.ToLower(strings.TrimSpace(line[:i]))] = strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	length, err := strconv.Atoi(headers["content-length"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid warc record content length %q", headers["content-length"])
+	}
+	block = make([]byte, length)
+	if _, err = io.ReadFull(br, block); err != nil {
+		return nil, nil, err
+	}
+	return headers, block, nil
+}
+
+func parseResponse(headers map[string]string, block []byte) (*Response, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if body, err = gzip.NewReader(resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	content, err := ioutil.ReadAll(body)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	response := &Response{
+		RecordID: headers["warc-record-id"],
+		URL:      strings.Trim(headers["warc-target-uri"], "<>"),
+		Status:   resp.StatusCode,
+		Headers:  make(map[string]string, len(resp.Header)),
+		Content:  string(content),
+	}
+	response.Date, _ = time.Parse(time.RFC3339, headers["warc-date"])
+	for name := range resp.Header {
+		response.Headers[name] = resp.Header.Get(name)
+	}
+	return response, nil
+}
@@ -0,0 +1,240 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Semantics3/go-crawler/storage"
+)
+
+// DefaultMaxFileSize is the (compressed) size after which a WARC file is rotated
+const DefaultMaxFileSize = 100 * 1024 * 1024
+
+// Exchange is a request made through proxycloud along with its response
+type Exchange struct {
+	URL            string
+	Method         string
+	RequestHeaders map[string]string
+	RequestBody    string
+	Status         int
+	// Headers reported by proxycloud (proxy node, render node etc.)
+	ResponseHeaders map[string]string
+	Content         string
+	Date            time.Time
+}
+
+// Record identifies the records written for an exchange
+type Record struct {
+	// WARC-Record-ID of the response record
+	ID string `json:"record_id"`
+	// WARC-Record-ID of the request record
+	RequestID string `json:"request_record_id"`
+	// Storage key of the WARC file holding the records
+	File string `json:"file"`
+}
+
+// Writer appends gzipped WARC records to files which are written to storage on rotation
+// Each record is its own gzip member, as expected by WARC readers (.warc.gz)
+type Writer struct {
+	store       storage.Store
+	prefix      string
+	maxFileSize int
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	file    string
+	seq     int
+	records int
+}
+
+// NewWriter creates a writer naming files {prefix}-{seq}.warc.gz
+func NewWriter(store storage.Store, prefix string, maxFileSize int) *Writer {
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+	return &Writer{store: store, prefix: prefix, maxFileSize: maxFileSize}
+}
+
+// WriteExchange writes request and response records of an exchange
+func (w *Writer) WriteExchange(e Exchange) (Record, error) {
+	if e.Date.IsZero() {
+		e.Date = time.Now()
+	}
+	if e.Method == "" {
+		e.Method = http.MethodGet
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == "" {
+		w.open()
+	}
+	record := Record{ID: newRecordID(), RequestID: newRecordID(), File: w.file}
+	date := e.Date.UTC().Format(time.RFC3339)
+
+	requestHeaders := map[string]string{
+		"WARC-Type":          "request",
+		"WARC-Record-ID":     record.RequestID,
+		"WARC-Date":          date,
+		"WARC-Target-URI":    e.URL,
+		"WARC-Concurrent-To": record.ID,
+		"Content-Type":       "application/http; msgtype=request",
+	}
+	if err := w.write(requestHeaders, requestBlock(e)); err != nil {
+		return record, err
+	}
+
+	responseHeaders := map[string]string{
+		"WARC-Type":          "response",
+		"WARC-Record-ID":     record.ID,
+		"WARC-Date":          date,
+		"WARC-Target-URI":    e.URL,
+		"WARC-Concurrent-To": record.RequestID,
+		"Content-Type":       "application/http; msgtype=response",
+	}
+	if err := w.write(responseHeaders, responseBlock(e)); err != nil {
+		return record, err
+	}
+	w.records++
+
+	if w.buf.Len() >= w.maxFileSize {
+		if err := w.flush(); err != nil {
+			return record, err
+		}
+	}
+	return record, nil
+}
+
+// Close writes the current file to storage
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+// Start a new file with a warcinfo record
+func (w *Writer) open() {
+	w.seq++
+	w.file = fmt.Sprintf("%s-%05d.warc.gz", w.prefix, w.seq)
+	w.records = 0
+	info := []byte("software: go-crawler\r\nformat: WARC File Format 1.1\r\n")
+	w.write(map[string]string{
+		"WARC-Type":      "warcinfo",
+		"WARC-Record-ID": newRecordID(),
+		"WARC-Date":      time.Now().UTC().Format(time.RFC3339),
+		"WARC-Filename":  w.file,
+		"Content-Type":   "application/warc-fields",
+	}, info)
+}
+
+func (w *Writer) flush() error {
+	if w.file == "" || w.records == 0 {
+		return nil
+	}
+	file, records := w.file, w.records
+	location, err := w.store.Put(file, w.buf.Bytes())
+	w.buf.Reset()
+	w.file = ""
+	if err != nil {
+		return fmt.Errorf("failed to write warc file %s: %v", file, err)
+	}
+	log.Printf("WARC_FILE_WRITTEN: %s, RECORDS: %d\n", location, records)
+	return nil
+}
+
+// Write a single record as a gzip member
+func (w *Writer) write(headers map[string]string, block []byte) error {
+	headers["Content-Length"] = fmt.Sprintf("%d", len(block))
+	headers["WARC-Block-Digest"] = digest(block)
+
+	var record bytes.Buffer
+	record.WriteString("WARC/1.1\r\n")
+	// WARC-Type first, rest sorted so that records are reproducible
+	fmt.Fprintf(&record, "WARC-Type: %s\r\n", headers["WARC-Type"])
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if name != "WARC-Type" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&record, "%s: %s\r\n", name, headers[name])
+	}
+	record.WriteString("\r\n")
+	record.Write(block)
+	record.WriteString("\r\n\r\n")
+
+	gz := gzip.NewWriter(&w.buf)
+	if _, err := gz.Write(record.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func requestBlock(e Exchange) []byte {
+	var b bytes.Buffer
+	path, host := e.URL, ""
+	if i := strings.Index(e.URL, "://"); i >= 0 {
+		rest := e.URL[i+3:]
+		if j := strings.Index(rest, "/"); j >= 0 {
+			host, path = rest[:j], rest[j:]
+		} else {
+			host, path = rest, "/"
+		}
+	}
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", e.Method, path)
+	if host != "" {
+		fmt.Fprintf(&b, "Host: %s\r\n", host)
+	}
+	writeHeaders(&b, e.RequestHeaders)
+	b.WriteString("\r\n")
+	b.WriteString(e.RequestBody)
+	return b.Bytes()
+}
+
+func responseBlock(e Exchange) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", e.Status, http.StatusText(e.Status))
+	writeHeaders(&b, e.ResponseHeaders)
+	fmt.Fprintf(&b, "Content-Length: %d\r\n", len(e.Content))
+	b.WriteString("\r\n")
+	b.WriteString(e.Content)
+	return b.Bytes()
+}
+
+func writeHeaders(b *bytes.Buffer, headers map[string]string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s: %s\r\n", name, headers[name])
+	}
+}
+
+func digest(block []byte) string {
+	sum := sha1.Sum(block)
+	return fmt.Sprintf("sha1:%s", base32.StdEncoding.EncodeToString(sum[:]))
+}
+
+// Record ids are random (v4) uuids
+func newRecordID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
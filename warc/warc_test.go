@@ -0,0 +1,117 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Semantics3/go-crawler/storage"
+	"github.com/stretchr/testify/suite"
+)
+
+type WarcSuite struct {
+	suite.Suite
+	dir   string
+	store storage.Store
+}
+
+// SetupTest - Called before each test
+func (suite *WarcSuite) SetupTest() {
+	suite.dir, _ = ioutil.TempDir("", "warc")
+	suite.store, _ = storage.NewLocalStore(suite.dir, "")
+}
+
+// TearDownTest - Called after each test
+func (suite *WarcSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+// Read back all the records of a .warc.gz file (one gzip member per record)
+func (suite *WarcSuite) readRecords(file string) []string {
+	data, err := suite.store.Get(file)
+	suite.Nil(err)
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	suite.Nil(err)
+	content, err := ioutil.ReadAll(gz)
+	suite.Nil(err)
+	records := strings.Split(string(content), "WARC/1.1\r\n")
+	return records[1:]
+}
+
+func (suite *WarcSuite) Test_01_WriteExchange() {
+	w := NewWriter(suite.store, "sem3/20261019/crawl-b1", 0)
+	record, err := w.WriteExchange(Exchange{
+		URL:             "https://www.example.com/product/1?a=b",
+		RequestHeaders:  map[string]string{"User-Agent": "Mozilla/5.0"},
+		Status:          200,
+		ResponseHeaders: map[string]string{"X-Node-Pool": "us_residential"},
+		Content:         "<html></html>",
+	})
+	suite.Nil(err)
+	suite.Equal("sem3/20261019/crawl-b1-00001.warc.gz", record.File)
+	suite.True(strings.HasPrefix(record.ID, "<urn:uuid:"))
+	suite.NotEqual(record.ID, record.RequestID)
+
+	// Nothing is written until the file is closed
+	_, err = suite.store.Get(record.File)
+	suite.NotNil(err)
+	suite.Nil(w.Close())
+
+	records := suite.readRecords(record.File)
+	suite.Len(records, 3)
+	suite.Contains(records[0], "WARC-Type: warcinfo")
+	suite.Contains(records[1], "WARC-Type: request")
+	suite.Contains(records[1], "WARC-Concurrent-To: "+record.ID)
+	suite.Contains(records[1], "GET /product/1?a=b HTTP/1.1\r\nHost: www.example.com\r\nUser-Agent: Mozilla/5.0\r\n")
+	suite.Contains(records[2], "WARC-Record-ID: "+record.ID)
+	suite.Contains(records[2], "WARC-Target-URI: https://www.example.com/product/1?a=b")
+	suite.Contains(records[2], "HTTP/1.1 200 OK\r\nX-Node-Pool: us_residential\r\nContent-Length: 13\r\n\r\n<html></html>")
+}
+
+func (suite *WarcSuite) Test_02_RotateFiles() {
+	w := NewWriter(suite.store, "batch", 1)
+	first, err := w.WriteExchange(Exchange{URL: "https://www.example.com/1", Status: 200, Content: "a"})
+	suite.Nil(err)
+	second, err := w.WriteExchange(Exchange{URL: "https://www.example.com/2", Status: 404})
+	suite.Nil(err)
+	suite.Nil(w.Close())
+
+	suite.Equal("batch-00001.warc.gz", first.File)
+	suite.Equal("batch-00002.warc.gz", second.File)
+	suite.Len(suite.readRecords(first.File), 3)
+	suite.Len(suite.readRecords(second.File), 3)
+}
+
+func (suite *WarcSuite) Test_03_ReadArchive() {
+	w := NewWriter(suite.store, "batch", 0)
+	record, _ := w.WriteExchange(Exchange{URL: "https://www.example.com/1", Status: 200, ResponseHeaders: map[string]string{"X-Node-Pool": "us"}, Content: "<html>old</html>"})
+	w.WriteExchange(Exchange{URL: "https://www.example.com/1", Status: 200, Content: "<html>new</html>"})
+	w.WriteExchange(Exchange{URL: "https://www.example.com/2", Status: 404})
+	suite.Nil(w.Close())
+
+	data, _ := suite.store.Get(record.File)
+	archive := NewArchive()
+	suite.Nil(archive.Read(bytes.NewReader(data)))
+	suite.Equal([]string{"https://www.example.com/1", "https://www.example.com/2"}, archive.URLs())
+
+	// Later records of a url win
+	response, ok := archive.Get("https://www.example.com/1")
+	suite.True(ok)
+	suite.Equal(200, response.Status)
+	suite.Equal("<html>new</html>", response.Content)
+	suite.NotEqual(record.ID, response.RecordID)
+
+	response, ok = archive.Get("https://www.example.com/2/")
+	suite.True(ok)
+	suite.Equal(404, response.Status)
+
+	_, ok = archive.Get("https://www.example.com/3")
+	suite.False(ok)
+}
+
+func TestWarcTestSuite(t *testing.T) {
+	suite.Run(t, new(WarcSuite))
+}